@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"anek-bot/internal/config"
+	"anek-bot/internal/database"
+	"anek-bot/pkg/logger"
+)
+
+var (
+	batchSize = flag.Int("batch", 500, "number of jokes to fingerprint per batch")
+)
+
+func main() {
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	logger.Init(cfg.App.LogLevel, nil)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	db, err := database.New(ctx, cfg.Database)
+	if err != nil {
+		logger.Error("Failed to connect to database", logger.Err(err))
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	jokeRepo := database.NewJokeRepository(db, database.WithDedup(cfg.Dedup))
+
+	var processed, linked int
+	for {
+		n, l, err := jokeRepo.BackfillFingerprints(ctx, *batchSize)
+		processed += n
+		linked += l
+		if err != nil {
+			logger.Error("Backfill failed",
+				logger.Err(err),
+				logger.Int("processed", processed),
+				logger.Int("linked", linked),
+			)
+			os.Exit(1)
+		}
+		if n == 0 {
+			break
+		}
+		logger.Info("Backfill batch done",
+			logger.Int("processed", processed),
+			logger.Int("linked", linked),
+		)
+	}
+
+	logger.Info("Backfill completed",
+		logger.Int("processed", processed),
+		logger.Int("linked", linked),
+	)
+}
@@ -3,18 +3,26 @@ package main
 import (
 	"context"
 	"errors"
+	"expvar"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	// The runtime image has no zoneinfo, subscriptions need it.
+	_ "time/tzdata"
 
 	"anek-bot/internal/bot"
 	"anek-bot/internal/config"
 	"anek-bot/internal/database"
+	"anek-bot/internal/metrics"
 	"anek-bot/internal/models"
 	"anek-bot/internal/parser"
+	_ "anek-bot/internal/parser/anekdot"
+	_ "anek-bot/internal/parser/feed"
+	_ "anek-bot/internal/parser/reddit"
+	_ "anek-bot/internal/parser/scraper"
 	"anek-bot/internal/queue"
 	"anek-bot/pkg/logger"
 )
@@ -60,16 +68,15 @@ func main() {
 	defer db.Close()
 	logger.Info("Connected to database")
 
-	q, err := queue.New(cfg.NATS)
+	q, err := openQueue(cfg)
 	if err != nil {
-		logger.Error("Failed to connect to NATS", logger.Err(err))
+		logger.Error("Failed to open queue", logger.Err(err))
 		os.Exit(1)
 	}
 	defer q.Close()
-	logger.Info("Connected to NATS", logger.String("url", cfg.NATS.URL))
 
-	jokeRepo := database.NewJokeRepository(db)
-	userRepo := database.NewUserRepository(db)
+	jokeRepo := database.NewJokeRepository(db, database.WithDedup(cfg.Dedup))
+	channelRepo := database.NewChannelRepository(db)
 
 	go func() {
 		logger.Info("Starting joke consumer...")
@@ -80,14 +87,29 @@ func main() {
 				Source:    string(joke.Source),
 				SourceURL: joke.SourceURL,
 				Hash:      joke.Hash,
+				NSFW:      joke.NSFW,
 			}
 			if err := jokeRepo.Create(ctx, m); err != nil {
+				var dupErr *database.DuplicateError
+				if errors.As(err, &dupErr) {
+					metrics.DuplicateDropped(metrics.LayerSimilarity)
+					logger.Debug("Skipped near-duplicate joke",
+						logger.String("hash", joke.Hash),
+						logger.Int64("original_id", dupErr.OriginalID),
+					)
+					return nil
+				}
 				logger.Error("Failed to save joke to database",
 					logger.Err(err),
 					logger.String("hash", joke.Hash),
 				)
 				return err
 			}
+			if m.ID == 0 {
+				metrics.DuplicateDropped(metrics.LayerDatabase)
+				logger.Debug("Joke already in database", logger.String("hash", joke.Hash))
+				return nil
+			}
 			logger.Debug("Joke saved to database", logger.String("hash", joke.Hash))
 			return nil
 		}); err != nil && !errors.Is(err, context.Canceled) {
@@ -95,7 +117,21 @@ func main() {
 		}
 	}()
 
-	telegramBot, err := bot.New(cfg.Bot, jokeRepo, userRepo, q)
+	p, err := parser.New(cfg.Parser, q)
+	if err != nil {
+		logger.Error("Failed to create parser", logger.Err(err))
+		os.Exit(1)
+	}
+
+	telegramBot, err := bot.New(cfg.Bot, bot.Repositories{
+		Jokes:         jokeRepo,
+		Users:         database.NewUserRepository(db),
+		Subscriptions: database.NewSubscriptionRepository(db),
+		Chats:         database.NewChatRepository(db),
+		Submissions:   database.NewSubmissionRepository(db),
+		Roles:         database.NewRoleRepository(db),
+		Broadcasts:    database.NewBroadcastRepository(db),
+	}, q, bot.WithReparser(p))
 	if err != nil {
 		logger.Error("Failed to create bot", logger.Err(err))
 		os.Exit(1)
@@ -108,9 +144,15 @@ func main() {
 	}
 	logger.Info("Telegram bot started")
 
+	autoposter, err := bot.NewAutoposter(telegramBot, channelRepo, cfg.Channels)
+	if err != nil {
+		logger.Error("Failed to configure channel autoposting", logger.Err(err))
+		os.Exit(1)
+	}
+	go autoposter.Run(ctx)
+
 	go func() {
 		logger.Info("Starting parser...")
-		p := parser.New(cfg.Parser, q)
 		if err := p.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
 			logger.Error("Parser error", logger.Err(err))
 		}
@@ -121,6 +163,10 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
+	if cfg.Health.MetricsEndpoint != "" {
+		healthMux.Handle(cfg.Health.MetricsEndpoint, expvar.Handler())
+	}
+	telegramBot.RegisterWebhook(healthMux)
 
 	healthServer := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.Health.Port),
@@ -155,3 +201,20 @@ func main() {
 
 	logger.Info("Bot stopped gracefully")
 }
+
+func openQueue(cfg *config.Config) (queue.Queue, error) {
+	switch cfg.Queue.Driver {
+	case config.QueueDriverMemory:
+		logger.Warn("Using the in-memory queue, queued messages are lost on restart")
+		return queue.NewMemory(), nil
+	case config.QueueDriverNATS, "":
+		q, err := queue.New(cfg.NATS)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Connected to NATS", logger.String("url", cfg.NATS.URL))
+		return q, nil
+	default:
+		return nil, fmt.Errorf("unknown queue driver %q", cfg.Queue.Driver)
+	}
+}
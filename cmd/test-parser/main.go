@@ -7,6 +7,10 @@ import (
 
 	"anek-bot/internal/config"
 	"anek-bot/internal/parser"
+	_ "anek-bot/internal/parser/anekdot"
+	_ "anek-bot/internal/parser/feed"
+	_ "anek-bot/internal/parser/reddit"
+	_ "anek-bot/internal/parser/scraper"
 	"anek-bot/internal/queue"
 	"anek-bot/pkg/logger"
 )
@@ -33,13 +37,17 @@ func main() {
 	cfg := config.ParserConfig{
 		Enabled:      true,
 		IntervalMins: 30 * time.Minute,
-		Sources: config.SourcesConfig{
-			Reddit: config.RedditConfig{
+		Sources: []config.SourceConfig{
+			{
+				Name:       "reddit",
+				Type:       "reddit",
 				Enabled:    true,
 				Subreddits: []string{"Jokes"},
 				Limit:      5,
 			},
-			Anekdot: config.AnekdotConfig{
+			{
+				Name:    "anekdot",
+				Type:    "anekdot",
 				Enabled: true,
 				Limit:   5,
 			},
@@ -48,19 +56,25 @@ func main() {
 
 	tq := &testQueue{}
 
-	p := parser.New(cfg, tq)
+	p, err := parser.New(cfg, tq)
+	if err != nil {
+		logger.Error("Failed to create parser", logger.Err(err))
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	fmt.Println("Testing Reddit parsing...")
-	if err := p.ParseAll(ctx); err != nil {
-		logger.Error("Parse error", logger.Err(err))
-	} else {
-		fmt.Printf("✓ Reddit: Parsed %d jokes\n", len(tq.jokes))
-		for i, joke := range tq.jokes {
-			fmt.Printf("  %d: %s\n", i+1, joke)
+	fmt.Println("Testing sources...")
+	for _, res := range p.ParseAll(ctx) {
+		if res.Err != nil {
+			fmt.Printf("✗ %s: %v\n", res.Source, res.Err)
+			continue
 		}
+		fmt.Printf("✓ %s: Parsed %d jokes\n", res.Source, res.Published)
+	}
+	for i, joke := range tq.jokes {
+		fmt.Printf("  %d: %s\n", i+1, joke)
 	}
 
 	fmt.Println()
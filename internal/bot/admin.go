@@ -0,0 +1,444 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"anek-bot/internal/database"
+	"anek-bot/internal/metrics"
+	"anek-bot/internal/models"
+	"anek-bot/internal/parser"
+	"anek-bot/internal/queue"
+	"anek-bot/pkg/logger"
+
+	"gopkg.in/telebot.v4"
+)
+
+const reparseTimeout = 5 * time.Minute
+
+var errInvalidUserRef = errors.New("invalid user reference")
+
+// Reparser runs joke sources on demand.
+type Reparser interface {
+	Reparse(ctx context.Context, source string) ([]parser.Result, error)
+}
+
+// setupAdminHandlers registers every admin-only endpoint on a group guarded
+// by adminOnly. Admin handlers must not be registered anywhere else.
+func (b *Bot) setupAdminHandlers(bot *telebot.Bot) {
+	admin := bot.Group()
+	admin.Use(b.adminOnly)
+
+	admin.Handle("/admin", b.handleAdminHelp)
+	admin.Handle("/addjoke", b.handleAddJoke)
+	admin.Handle("/deljoke", b.handleDeleteJoke)
+	admin.Handle("/joke_info", b.handleJokeInfo)
+	admin.Handle("/ban", b.handleBan)
+	admin.Handle("/unban", b.handleUnban)
+	admin.Handle("/reparse", b.handleReparse)
+	admin.Handle("/queue_status", b.handleQueueStatus)
+	admin.Handle("/deadletters", b.handleDeadLetters)
+	admin.Handle("/deadletter_replay", b.handleDeadLetterReplay)
+	admin.Handle("/deadletter_purge", b.handleDeadLetterPurge)
+	admin.Handle("/promote", b.handlePromote)
+	admin.Handle("/demote", b.handleDemote)
+	admin.Handle("/broadcast", b.handleBroadcast)
+	admin.Handle("/broadcast_cancel", b.handleBroadcastCancel)
+	admin.Handle(&telebot.InlineButton{Unique: submissionUnique}, b.handleSubmissionReview)
+	admin.Handle(&telebot.InlineButton{Unique: broadcastUnique}, b.handleBroadcastButton)
+}
+
+// adminOnly lets only admins through. Everyone else gets a short refusal so
+// that admin commands do not look broken.
+func (b *Bot) adminOnly(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(c telebot.Context) error {
+		if c.Sender() != nil && b.isAdmin(context.Background(), c.Sender().ID) {
+			return next(c)
+		}
+
+		logger.Warn("Rejected admin request",
+			logger.Int64("user_id", senderID(c)),
+			logger.String("text", c.Text()),
+		)
+		if c.Callback() != nil {
+			return c.Respond(&telebot.CallbackResponse{Text: "Only admins can do that"})
+		}
+		return b.queueOrSend(c.Chat().ID, "This command is for admins only.")
+	}
+}
+
+// skipBanned drops updates from banned users before any handler runs.
+// Admins cannot be locked out this way.
+func (b *Bot) skipBanned(next telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(c telebot.Context) error {
+		sender := c.Sender()
+		if sender == nil || b.userDB == nil {
+			return next(c)
+		}
+
+		banned, err := b.userDB.IsBanned(context.Background(), sender.ID)
+		if err != nil {
+			logger.Error("Failed to check ban", logger.Err(err), logger.Int64("user_id", sender.ID))
+			return next(c)
+		}
+		if !banned || b.isOwner(sender.ID) {
+			return next(c)
+		}
+
+		logger.Debug("Ignored update from banned user", logger.Int64("user_id", sender.ID))
+		if c.Callback() != nil {
+			return c.Respond()
+		}
+		return nil
+	}
+}
+
+// isOwner reports whether the user is an admin from the config. Owners
+// manage the admins stored in the database.
+func (b *Bot) isOwner(userID int64) bool {
+	return slices.Contains(b.cfg.AdminIDs, userID)
+}
+
+// isAdmin reports whether the Telegram user may moderate the bot.
+func (b *Bot) isAdmin(ctx context.Context, userID int64) bool {
+	if b.isOwner(userID) {
+		return true
+	}
+	if b.roleDB == nil {
+		return false
+	}
+
+	ok, err := b.roleDB.Has(ctx, userID, models.RoleAdmin)
+	if err != nil {
+		logger.Error("Failed to check admin role", logger.Err(err), logger.Int64("user_id", userID))
+		return false
+	}
+	return ok
+}
+
+// adminIDs returns owners and database admins without duplicates.
+func (b *Bot) adminIDs(ctx context.Context) []int64 {
+	ids := slices.Clone(b.cfg.AdminIDs)
+	if b.roleDB == nil {
+		return ids
+	}
+
+	extra, err := b.roleDB.List(ctx, models.RoleAdmin)
+	if err != nil {
+		logger.Error("Failed to list admins", logger.Err(err))
+		return ids
+	}
+	for _, id := range extra {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// notifyAdmins sends msg to every admin. ChatID in msg is overwritten.
+func (b *Bot) notifyAdmins(ctx context.Context, msg queue.TelegramMessage) {
+	for _, id := range b.adminIDs(ctx) {
+		msg.ChatID = id
+		if err := b.enqueue(&msg); err != nil {
+			logger.Error("Failed to notify admin", logger.Err(err), logger.Int64("admin_id", id))
+		}
+	}
+}
+
+func (b *Bot) handleAdminHelp(c telebot.Context) error {
+	return b.sendTemplate(c.Chat().ID, "admin_help", nil)
+}
+
+func (b *Bot) handleAddJoke(c telebot.Context) error {
+	chatID := c.Chat().ID
+
+	content := commandPayload(c.Text())
+	if content == "" {
+		return b.queueOrSend(chatID, "Usage: /addjoke <text>")
+	}
+
+	joke := &models.Joke{Content: content, Source: string(models.SourceManual)}
+	err := b.jokeDB.Create(context.Background(), joke)
+
+	var dupErr *database.DuplicateError
+	switch {
+	case errors.As(err, &dupErr):
+		return b.queueOrSend(chatID, fmt.Sprintf("Not added: %.0f%% similar to joke #%d.", dupErr.Similarity*100, dupErr.OriginalID))
+	case err != nil:
+		logger.Error("Failed to add joke", logger.Err(err))
+		return b.queueOrSend(chatID, "Failed to add the joke")
+	case joke.ID == 0:
+		return b.queueOrSend(chatID, "Not added: this exact joke already exists.")
+	}
+
+	logger.Info("Joke added by admin", logger.Int64("joke_id", joke.ID), logger.Int64("admin_id", c.Sender().ID))
+	return b.queueOrSend(chatID, fmt.Sprintf("Added joke #%d.", joke.ID))
+}
+
+func (b *Bot) handleDeleteJoke(c telebot.Context) error {
+	chatID := c.Chat().ID
+
+	id, ok := parseIDArg(c.Args())
+	if !ok {
+		return b.queueOrSend(chatID, "Usage: /deljoke <id>")
+	}
+
+	err := b.jokeDB.Delete(context.Background(), id)
+	if errors.Is(err, database.ErrNoJokesFound) {
+		return b.queueOrSend(chatID, fmt.Sprintf("Joke #%d does not exist.", id))
+	}
+	if err != nil {
+		logger.Error("Failed to delete joke", logger.Err(err), logger.Int64("joke_id", id))
+		return b.queueOrSend(chatID, "Failed to delete the joke")
+	}
+
+	logger.Info("Joke deleted by admin", logger.Int64("joke_id", id), logger.Int64("admin_id", c.Sender().ID))
+	return b.queueOrSend(chatID, fmt.Sprintf("Deleted joke #%d.", id))
+}
+
+func (b *Bot) handleJokeInfo(c telebot.Context) error {
+	chatID := c.Chat().ID
+
+	id, ok := parseIDArg(c.Args())
+	if !ok {
+		return b.queueOrSend(chatID, "Usage: /joke_info <id>")
+	}
+
+	joke, err := b.jokeDB.GetByID(context.Background(), id)
+	if errors.Is(err, database.ErrNoJokesFound) {
+		return b.queueOrSend(chatID, fmt.Sprintf("Joke #%d does not exist.", id))
+	}
+	if err != nil {
+		logger.Error("Failed to load joke", logger.Err(err), logger.Int64("joke_id", id))
+		return b.queueOrSend(chatID, "Failed to load the joke")
+	}
+
+	return b.sendTemplate(chatID, "joke_info", joke)
+}
+
+func (b *Bot) handleBan(c telebot.Context) error {
+	ctx := context.Background()
+	chatID := c.Chat().ID
+
+	target, reason, err := b.resolveTarget(ctx, c)
+	if err != nil {
+		return b.queueOrSend(chatID, "Usage: /ban <id|@username> [reason], or reply to a message with /ban [reason]")
+	}
+	if b.isAdmin(ctx, target) {
+		return b.queueOrSend(chatID, "Admins cannot be banned.")
+	}
+
+	if err := b.userDB.Ban(ctx, target, c.Sender().ID, reason); err != nil {
+		logger.Error("Failed to ban user", logger.Err(err), logger.Int64("user_id", target))
+		return b.queueOrSend(chatID, "Failed to ban the user")
+	}
+
+	logger.Info("User banned",
+		logger.Int64("user_id", target),
+		logger.Int64("admin_id", c.Sender().ID),
+		logger.String("reason", reason),
+	)
+	return b.queueOrSend(chatID, fmt.Sprintf("Banned user %d.", target))
+}
+
+func (b *Bot) handleUnban(c telebot.Context) error {
+	ctx := context.Background()
+	chatID := c.Chat().ID
+
+	target, _, err := b.resolveTarget(ctx, c)
+	if err != nil {
+		return b.queueOrSend(chatID, "Usage: /unban <id|@username>")
+	}
+
+	lifted, err := b.userDB.Unban(ctx, target)
+	if err != nil {
+		logger.Error("Failed to unban user", logger.Err(err), logger.Int64("user_id", target))
+		return b.queueOrSend(chatID, "Failed to unban the user")
+	}
+	if !lifted {
+		return b.queueOrSend(chatID, fmt.Sprintf("User %d is not banned.", target))
+	}
+
+	logger.Info("User unbanned", logger.Int64("user_id", target), logger.Int64("admin_id", c.Sender().ID))
+	return b.queueOrSend(chatID, fmt.Sprintf("Unbanned user %d.", target))
+}
+
+func (b *Bot) handlePromote(c telebot.Context) error {
+	return b.changeAdminRole(c, true)
+}
+
+func (b *Bot) handleDemote(c telebot.Context) error {
+	return b.changeAdminRole(c, false)
+}
+
+func (b *Bot) changeAdminRole(c telebot.Context, grant bool) error {
+	ctx := context.Background()
+	chatID := c.Chat().ID
+
+	if !b.isOwner(c.Sender().ID) {
+		return b.queueOrSend(chatID, "Only owners can manage admins.")
+	}
+
+	target, _, err := b.resolveTarget(ctx, c)
+	if err != nil {
+		return b.queueOrSend(chatID, "Usage: /promote <id|@username> or /demote <id|@username>")
+	}
+	if b.isOwner(target) {
+		return b.queueOrSend(chatID, "Owners are set in the config.")
+	}
+
+	if grant {
+		err = b.roleDB.Grant(ctx, target, models.RoleAdmin, c.Sender().ID)
+	} else {
+		var had bool
+		had, err = b.roleDB.Revoke(ctx, target, models.RoleAdmin)
+		if err == nil && !had {
+			return b.queueOrSend(chatID, fmt.Sprintf("User %d is not an admin.", target))
+		}
+	}
+	if err != nil {
+		logger.Error("Failed to change admin role", logger.Err(err), logger.Int64("user_id", target))
+		return b.queueOrSend(chatID, "Failed to change the role")
+	}
+
+	logger.Info("Admin role changed",
+		logger.Int64("user_id", target),
+		logger.Int64("owner_id", c.Sender().ID),
+		logger.Any("granted", grant),
+	)
+	if grant {
+		return b.queueOrSend(chatID, fmt.Sprintf("User %d is now an admin.", target))
+	}
+	return b.queueOrSend(chatID, fmt.Sprintf("User %d is no longer an admin.", target))
+}
+
+func (b *Bot) handleReparse(c telebot.Context) error {
+	chatID := c.Chat().ID
+	if b.reparser == nil {
+		return b.queueOrSend(chatID, "The parser is not running in this process.")
+	}
+
+	source := ""
+	if args := c.Args(); len(args) > 0 {
+		source = args[0]
+	}
+
+	logger.Info("Reparse requested", logger.String("source", source), logger.Int64("admin_id", c.Sender().ID))
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), reparseTimeout)
+		defer cancel()
+
+		results, err := b.reparser.Reparse(ctx, source)
+		if errors.Is(err, parser.ErrUnknownSource) {
+			b.queueOrSend(chatID, fmt.Sprintf("No enabled source named %q.", source))
+			return
+		}
+		if err != nil {
+			logger.Error("Reparse failed", logger.Err(err))
+			b.queueOrSend(chatID, "Reparse failed")
+			return
+		}
+		if len(results) == 0 {
+			b.queueOrSend(chatID, "No sources are enabled.")
+			return
+		}
+		b.sendTemplate(chatID, "reparse", results)
+	}()
+
+	return b.queueOrSend(chatID, "Parsing started, I will report back when it is done.")
+}
+
+func (b *Bot) handleQueueStatus(c telebot.Context) error {
+	chatID := c.Chat().ID
+	if b.q == nil {
+		return b.queueOrSend(chatID, "The queue is not configured, messages are sent directly.")
+	}
+
+	status, err := b.q.Status()
+	if err != nil {
+		logger.Error("Failed to get queue status", logger.Err(err))
+		return b.queueOrSend(chatID, "Failed to get the queue status")
+	}
+
+	return b.sendTemplate(chatID, "queue_status", queueStatusView{
+		Status:     status,
+		KB:         status.Bytes / 1024,
+		Duplicates: metrics.DuplicatesDropped(),
+	})
+}
+
+// queueStatusView is the data of the queue_status template.
+type queueStatusView struct {
+	*queue.Status
+	KB uint64
+	// Duplicates are the jokes each layer dropped since start.
+	Duplicates []metrics.LayerCount
+}
+
+// resolveTarget finds the user an admin command is about: the author of the
+// replied-to message, or the first argument as an ID or @username. The
+// remaining arguments are returned as free text.
+func (b *Bot) resolveTarget(ctx context.Context, c telebot.Context) (int64, string, error) {
+	args := c.Args()
+
+	if msg := c.Message(); msg != nil && msg.ReplyTo != nil && msg.ReplyTo.Sender != nil {
+		return msg.ReplyTo.Sender.ID, strings.Join(args, " "), nil
+	}
+	if len(args) == 0 {
+		return 0, "", errInvalidUserRef
+	}
+
+	id, username, err := parseUserRef(args[0])
+	if err != nil {
+		return 0, "", err
+	}
+	if username != "" {
+		user, err := b.userDB.FindByUsername(ctx, username)
+		if err != nil {
+			return 0, "", err
+		}
+		id = user.TelegramID
+	}
+	return id, strings.Join(args[1:], " "), nil
+}
+
+// parseUserRef accepts a numeric Telegram ID or an @username.
+func parseUserRef(ref string) (int64, string, error) {
+	if name, ok := strings.CutPrefix(ref, "@"); ok {
+		if name == "" {
+			return 0, "", errInvalidUserRef
+		}
+		return 0, name, nil
+	}
+
+	id, err := strconv.ParseInt(ref, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, "", errInvalidUserRef
+	}
+	return id, "", nil
+}
+
+func parseIDArg(args []string) (int64, bool) {
+	if len(args) != 1 {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+func senderID(c telebot.Context) int64 {
+	if c.Sender() == nil {
+		return 0
+	}
+	return c.Sender().ID
+}
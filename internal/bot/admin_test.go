@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"anek-bot/internal/config"
+	"anek-bot/internal/metrics"
+	"anek-bot/internal/parser"
+	"anek-bot/internal/queue"
+)
+
+func TestParseUserRef(t *testing.T) {
+	tests := []struct {
+		in       string
+		wantID   int64
+		wantName string
+		wantErr  bool
+	}{
+		{in: "12345", wantID: 12345},
+		{in: "@AnekFan", wantName: "AnekFan"},
+		{in: "@", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "-5", wantErr: true},
+		{in: "anekfan", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		id, name, err := parseUserRef(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, errInvalidUserRef) {
+				t.Errorf("parseUserRef(%q) error = %v, want errInvalidUserRef", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || id != tt.wantID || name != tt.wantName {
+			t.Errorf("parseUserRef(%q) = %d, %q, %v, want %d, %q", tt.in, id, name, err, tt.wantID, tt.wantName)
+		}
+	}
+}
+
+func TestParseIDArg(t *testing.T) {
+	tests := []struct {
+		args   []string
+		want   int64
+		wantOK bool
+	}{
+		{args: []string{"42"}, want: 42, wantOK: true},
+		{args: []string{"#42"}, want: 42, wantOK: true},
+		{args: nil},
+		{args: []string{"42", "43"}},
+		{args: []string{"abc"}},
+		{args: []string{"0"}},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseIDArg(tt.args)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseIDArg(%q) = %d, %v, want %d, %v", tt.args, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestIsAdminWithoutRoleStore(t *testing.T) {
+	b := &Bot{cfg: config.BotConfig{AdminIDs: []int64{1, 2}}}
+	ctx := context.Background()
+
+	if !b.isAdmin(ctx, 2) {
+		t.Error("config admin is not an admin")
+	}
+	if b.isAdmin(ctx, 3) {
+		t.Error("unknown user is an admin")
+	}
+	if got := b.adminIDs(ctx); len(got) != 2 {
+		t.Errorf("adminIDs() = %v, want config admins", got)
+	}
+}
+
+func TestRenderQueueStatus(t *testing.T) {
+	f, err := NewFormatter("Markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := &queue.Status{
+		Stream:   "ANEK",
+		Messages: 10,
+		Bytes:    4096,
+		Consumers: []queue.ConsumerStatus{
+			{Name: "telegram_consumer", Pending: 3, AckPending: 1, Redelivered: 2},
+		},
+	}
+
+	got, err := f.Render("queue_status", queueStatusView{
+		Status:     status,
+		KB:         status.Bytes / 1024,
+		Duplicates: []metrics.LayerCount{{Layer: metrics.LayerParser, Count: 7}, {Layer: metrics.LayerQueue}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"`ANEK`: 10 messages, 4 KB", "`telegram_consumer`: 3 pending, 1 in flight, 2 redelivered", "parser: 7\nqueue: 0"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("queue_status = %q, missing %q", got, want)
+		}
+	}
+}
+
+func TestRenderReparseResults(t *testing.T) {
+	f, err := NewFormatter("Markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := f.Render("reparse", []parser.Result{
+		{Source: "anekdot", Fetched: 20, Published: 5, Skipped: 15},
+		{Source: "reddit", Err: errors.New("boom")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "`anekdot`: fetched 20, queued 5, 15 seen before") {
+		t.Errorf("missing anekdot line in %q", got)
+	}
+	if !strings.Contains(got, "`reddit`: fetched 0, queued 0 (with errors)") {
+		t.Errorf("missing reddit error in %q", got)
+	}
+}
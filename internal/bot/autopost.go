@@ -0,0 +1,205 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"anek-bot/internal/config"
+	"anek-bot/internal/database"
+	"anek-bot/internal/models"
+	"anek-bot/pkg/logger"
+)
+
+var errInvalidSchedule = errors.New("invalid channel schedule")
+
+// Autoposter posts jokes to the configured channels on their schedules.
+// Schedules and posted jokes live in the database, so restarts resume where
+// they left off and replicas do not post twice.
+type Autoposter struct {
+	bot      *Bot
+	db       *database.ChannelRepository
+	channels []*channel
+}
+
+type channel struct {
+	cfg   config.ChannelConfig
+	sched *channelSchedule
+}
+
+// channelSchedule is either a set of daily wall-clock times or a fixed
+// interval.
+type channelSchedule struct {
+	times    [][2]int
+	loc      *time.Location
+	interval time.Duration
+}
+
+func NewAutoposter(b *Bot, db *database.ChannelRepository, channels []config.ChannelConfig) (*Autoposter, error) {
+	a := &Autoposter{bot: b, db: db}
+
+	for _, cfg := range channels {
+		if !cfg.Enabled {
+			continue
+		}
+		if cfg.ChatID == 0 {
+			return nil, fmt.Errorf("channel %q: chat_id is required", cfg.Name)
+		}
+
+		sched, err := parseChannelSchedule(cfg, b.defaultTZ)
+		if err != nil {
+			return nil, fmt.Errorf("channel %q: %w", cfg.Name, err)
+		}
+		a.channels = append(a.channels, &channel{cfg: cfg, sched: sched})
+	}
+
+	return a, nil
+}
+
+func parseChannelSchedule(cfg config.ChannelConfig, defaultTZ *time.Location) (*channelSchedule, error) {
+	if len(cfg.Times) == 0 {
+		if cfg.Interval < time.Minute {
+			return nil, fmt.Errorf("%w: set times or an interval of at least a minute", errInvalidSchedule)
+		}
+		return &channelSchedule{interval: cfg.Interval}, nil
+	}
+
+	sched := &channelSchedule{loc: defaultTZ}
+	if cfg.Timezone != "" {
+		loc, err := parseTimezone(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("%w: timezone %q", errInvalidSchedule, cfg.Timezone)
+		}
+		sched.loc = loc
+	}
+
+	for _, t := range cfg.Times {
+		hour, minute, err := parseClock(t)
+		if err != nil {
+			return nil, fmt.Errorf("%w: time %q", errInvalidSchedule, t)
+		}
+		sched.times = append(sched.times, [2]int{hour, minute})
+	}
+	slices.SortFunc(sched.times, func(a, b [2]int) int {
+		return (a[0]*60 + a[1]) - (b[0]*60 + b[1])
+	})
+	sched.times = slices.Compact(sched.times)
+
+	return sched, nil
+}
+
+func (s *channelSchedule) next(after time.Time) time.Time {
+	if s.interval > 0 {
+		return after.Add(s.interval)
+	}
+
+	var next time.Time
+	for _, t := range s.times {
+		candidate := nextRun(t[0], t[1], s.loc, after)
+		if next.IsZero() || candidate.Before(next) {
+			next = candidate
+		}
+	}
+	return next
+}
+
+// String describes the schedule for change detection in the database.
+func (s *channelSchedule) String() string {
+	if s.interval > 0 {
+		return "every " + s.interval.String()
+	}
+
+	parts := make([]string, len(s.times))
+	for i, t := range s.times {
+		parts[i] = fmt.Sprintf("%02d:%02d", t[0], t[1])
+	}
+	return "daily " + strings.Join(parts, ",") + " " + s.loc.String()
+}
+
+// Run posts to due channels until ctx is done.
+func (a *Autoposter) Run(ctx context.Context) {
+	if len(a.channels) == 0 {
+		return
+	}
+
+	for _, ch := range a.channels {
+		if err := a.db.SyncSchedule(ctx, ch.cfg.ChatID, ch.sched.String(), ch.sched.next(time.Now())); err != nil {
+			logger.Error("Failed to register channel schedule",
+				logger.Err(err),
+				logger.String("channel", ch.cfg.Name),
+			)
+		}
+	}
+	logger.Info("Channel autoposter started", logger.Int("channels", len(a.channels)))
+
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, ch := range a.channels {
+			a.postDue(ctx, ch)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// channelMessageID is the queue message ID of the post for one scheduled
+// run of a channel.
+func channelMessageID(chatID int64, slot time.Time) string {
+	return "channel:" + strconv.FormatInt(chatID, 10) + ":" + strconv.FormatInt(slot.Unix(), 10)
+}
+
+func (a *Autoposter) postDue(ctx context.Context, ch *channel) {
+	filter := database.ChannelFilter{
+		Source:    ch.cfg.Source,
+		TopRated:  ch.cfg.TopRated,
+		MinRating: ch.cfg.MinRating,
+		NSFW:      ch.cfg.NSFW,
+	}
+
+	now := time.Now()
+	var posted *models.Joke
+	_, err := a.db.PostDue(ctx, ch.cfg.ChatID, now, filter, func(joke *models.Joke, slot time.Time) (time.Time, error) {
+		if joke == nil {
+			logger.Warn("No unposted jokes left for channel", logger.String("channel", ch.cfg.Name))
+			return ch.sched.next(now), nil
+		}
+
+		msg, err := a.bot.jokeMessage(ch.cfg.ChatID, "", joke, false)
+		if err != nil {
+			return time.Time{}, err
+		}
+		// The ID is the slot rather than the joke: if the post is queued but
+		// recording it fails, the retry picks another joke, and the queue
+		// has to drop it as a second post for the same run.
+		msg.ID = channelMessageID(ch.cfg.ChatID, slot)
+		if err := a.bot.deliver(ctx, msg); err != nil {
+			return time.Time{}, err
+		}
+		posted = joke
+		return ch.sched.next(now), nil
+	})
+	if err != nil {
+		logger.Error("Failed to post to channel",
+			logger.Err(err),
+			logger.String("channel", ch.cfg.Name),
+		)
+		return
+	}
+
+	if posted != nil {
+		logger.Info("Posted joke to channel",
+			logger.String("channel", ch.cfg.Name),
+			logger.Int64("joke_id", posted.ID),
+		)
+	}
+}
@@ -0,0 +1,153 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"anek-bot/internal/config"
+)
+
+func TestParseChannelSchedule(t *testing.T) {
+	moscow := mustLocation(t, "Europe/Moscow")
+
+	tests := []struct {
+		name    string
+		cfg     config.ChannelConfig
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "times in default zone",
+			cfg:  config.ChannelConfig{Times: []string{"18:00", "09:00", "9:00"}},
+			want: "daily 09:00,18:00 Europe/Moscow",
+		},
+		{
+			name: "times in own zone",
+			cfg:  config.ChannelConfig{Times: []string{"12:30"}, Timezone: "+2"},
+			want: "daily 12:30 UTC+02:00",
+		},
+		{
+			name: "interval",
+			cfg:  config.ChannelConfig{Interval: 3 * time.Hour},
+			want: "every 3h0m0s",
+		},
+		{
+			name: "times win over interval",
+			cfg:  config.ChannelConfig{Times: []string{"07:00"}, Interval: time.Hour},
+			want: "daily 07:00 Europe/Moscow",
+		},
+		{name: "nothing set", cfg: config.ChannelConfig{}, wantErr: true},
+		{name: "interval too short", cfg: config.ChannelConfig{Interval: time.Second}, wantErr: true},
+		{name: "bad time", cfg: config.ChannelConfig{Times: []string{"25:00"}}, wantErr: true},
+		{name: "bad zone", cfg: config.ChannelConfig{Times: []string{"10:00"}, Timezone: "Nowhere/City"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sched, err := parseChannelSchedule(tt.cfg, moscow)
+			if tt.wantErr {
+				if !errors.Is(err, errInvalidSchedule) {
+					t.Fatalf("parseChannelSchedule() error = %v, want errInvalidSchedule", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseChannelSchedule() error = %v", err)
+			}
+			if got := sched.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelScheduleNext(t *testing.T) {
+	moscow := mustLocation(t, "Europe/Moscow")
+
+	daily, err := parseChannelSchedule(config.ChannelConfig{Times: []string{"09:00", "18:00"}}, moscow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	every, err := parseChannelSchedule(config.ChannelConfig{Interval: 90 * time.Minute}, moscow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		sched *channelSchedule
+		after time.Time
+		want  time.Time
+	}{
+		{
+			name:  "morning slot",
+			sched: daily,
+			after: time.Date(2026, 6, 1, 7, 0, 0, 0, moscow),
+			want:  time.Date(2026, 6, 1, 9, 0, 0, 0, moscow),
+		},
+		{
+			name:  "evening slot",
+			sched: daily,
+			after: time.Date(2026, 6, 1, 9, 0, 0, 0, moscow),
+			want:  time.Date(2026, 6, 1, 18, 0, 0, 0, moscow),
+		},
+		{
+			name:  "next morning",
+			sched: daily,
+			after: time.Date(2026, 6, 1, 20, 0, 0, 0, moscow),
+			want:  time.Date(2026, 6, 2, 9, 0, 0, 0, moscow),
+		},
+		{
+			name:  "interval",
+			sched: every,
+			after: time.Date(2026, 6, 1, 20, 0, 0, 0, moscow),
+			want:  time.Date(2026, 6, 1, 21, 30, 0, 0, moscow),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sched.next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAutoposterSkipsDisabled(t *testing.T) {
+	b := &Bot{defaultTZ: time.UTC}
+
+	a, err := NewAutoposter(b, nil, []config.ChannelConfig{
+		{Name: "off", Enabled: false},
+		{Name: "on", Enabled: true, ChatID: -100, Interval: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("NewAutoposter() error = %v", err)
+	}
+	if len(a.channels) != 1 || a.channels[0].cfg.Name != "on" {
+		t.Errorf("channels = %+v, want only the enabled one", a.channels)
+	}
+
+	if _, err := NewAutoposter(b, nil, []config.ChannelConfig{{Name: "no id", Enabled: true, Interval: time.Hour}}); err == nil {
+		t.Error("NewAutoposter() without chat_id should fail")
+	}
+}
+
+func TestChannelMessageIDIsPerSlot(t *testing.T) {
+	slot := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if channelMessageID(-100, slot) != channelMessageID(-100, slot.In(kyiv)) {
+		t.Error("the same slot in another zone got another ID")
+	}
+	if channelMessageID(-100, slot) == channelMessageID(-100, slot.Add(time.Hour)) {
+		t.Error("two slots got the same ID")
+	}
+	if channelMessageID(-100, slot) == channelMessageID(-200, slot) {
+		t.Error("two channels got the same ID")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -18,30 +19,108 @@ import (
 
 var ErrRateLimited = errors.New("telegram rate limited")
 
+// defaultFloodDelay is used when Telegram rate limits without retry_after.
+const defaultFloodDelay = 5 * time.Second
+
+// Queue is the part of the message queue the bot works with.
+type Queue interface {
+	queue.TelegramPublisher
+	queue.TelegramConsumer
+	queue.DeadLetterStore
+	Status() (*queue.Status, error)
+}
+
+// Repositories groups the storage the bot works with.
+type Repositories struct {
+	Jokes         *database.JokeRepository
+	Users         *database.UserRepository
+	Subscriptions *database.SubscriptionRepository
+	Chats         *database.ChatRepository
+	Submissions   *database.SubmissionRepository
+	Roles         *database.RoleRepository
+	Broadcasts    *database.BroadcastRepository
+}
+
 type Bot struct {
-	settings telebot.Settings
-	jokeDB   *database.JokeRepository
-	userDB   *database.UserRepository
-	q        *queue.NATS
-	tbot     *telebot.Bot
-	cfg      config.BotConfig
+	settings     telebot.Settings
+	jokeDB       *database.JokeRepository
+	userDB       *database.UserRepository
+	subDB        *database.SubscriptionRepository
+	chatDB       *database.ChatRepository
+	submissionDB *database.SubmissionRepository
+	roleDB       *database.RoleRepository
+	broadcastDB  *database.BroadcastRepository
+	reparser     Reparser
+	q            Queue
+	limiter      *SendLimiter
+	format       *Formatter
+	tbot         *telebot.Bot
+	webhook      *Webhook
+	cfg          config.BotConfig
+	defaultTZ    *time.Location
+	topPager     *Paginator
+	searchPager  *Paginator
 }
 
-func New(cfg config.BotConfig, jokeDB *database.JokeRepository, userDB *database.UserRepository, q *queue.NATS) (*Bot, error) {
+type Option func(*Bot)
+
+// WithReparser enables /reparse.
+func WithReparser(r Reparser) Option {
+	return func(b *Bot) {
+		b.reparser = r
+	}
+}
+
+// New creates the bot. With a nil q messages are sent right away instead
+// of going through the queue.
+func New(cfg config.BotConfig, repos Repositories, q Queue, opts ...Option) (*Bot, error) {
 	if cfg.Token == "" {
 		return nil, fmt.Errorf("telegram bot token is required")
 	}
 
-	return &Bot{
-		cfg:    cfg,
-		jokeDB: jokeDB,
-		userDB: userDB,
-		q:      q,
+	defaultTZ, err := time.LoadLocation(cfg.DefaultTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default timezone %q: %w", cfg.DefaultTimezone, err)
+	}
+
+	format, err := NewFormatter(cfg.ParseMode)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bot{
+		cfg:          cfg,
+		jokeDB:       repos.Jokes,
+		userDB:       repos.Users,
+		subDB:        repos.Subscriptions,
+		chatDB:       repos.Chats,
+		submissionDB: repos.Submissions,
+		roleDB:       repos.Roles,
+		broadcastDB:  repos.Broadcasts,
+		q:            q,
+		limiter:      NewSendLimiter(cfg.RateLimit),
+		format:       format,
+		defaultTZ:    defaultTZ,
 		settings: telebot.Settings{
 			Token:  cfg.Token,
 			Poller: &telebot.LongPoller{Timeout: 10},
 		},
-	}, nil
+	}
+	if cfg.Webhook.Enabled() {
+		webhook, err := NewWebhook(cfg.Webhook)
+		if err != nil {
+			return nil, err
+		}
+		b.webhook = webhook
+		b.settings.Poller = webhook
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.topPager = NewPaginator(topUnique, format.ParseMode(), b.renderTopPage)
+	b.searchPager = NewPaginator(searchUnique, format.ParseMode(), b.renderSearchPage)
+
+	return b, nil
 }
 
 func (b *Bot) Start() (*telebot.Bot, error) {
@@ -53,14 +132,34 @@ func (b *Bot) Start() (*telebot.Bot, error) {
 	b.tbot = tbot
 	b.setupHandlers(tbot)
 
+	if b.webhook == nil {
+		// Telegram refuses to long poll while a webhook is set, which is
+		// the case after switching back from webhook mode.
+		if err := tbot.RemoveWebhook(); err != nil {
+			logger.Warn("Failed to remove webhook", logger.Err(err))
+		}
+	}
+
 	go b.startTelegramConsumer(context.Background())
+	go b.runScheduler(context.Background())
+	go b.runBroadcasts(context.Background())
 
 	go tbot.Start()
 
 	return tbot, nil
 }
 
+// RegisterWebhook serves the webhook on mux when it has no listener of its
+// own. It does nothing when the bot long polls.
+func (b *Bot) RegisterWebhook(mux *http.ServeMux) {
+	if b.webhook != nil {
+		b.webhook.Register(mux)
+	}
+}
+
 func (b *Bot) setupHandlers(bot *telebot.Bot) {
+	bot.Use(b.skipBanned)
+
 	bot.Handle(telebot.OnText, func(c telebot.Context) error {
 		logger.Info("Incoming text message",
 			logger.Int64("user_id", c.Sender().ID),
@@ -83,7 +182,7 @@ func (b *Bot) setupHandlers(bot *telebot.Bot) {
 			logger.Int64("user_id", c.Sender().ID),
 			logger.String("callback_data", c.Callback().Data),
 		)
-		return nil
+		return c.Respond()
 	})
 
 	bot.Handle(telebot.OnChatJoinRequest, func(c telebot.Context) error {
@@ -97,7 +196,24 @@ func (b *Bot) setupHandlers(bot *telebot.Bot) {
 	bot.Handle("/start", b.handleStart)
 	bot.Handle("/joke", b.handleJoke)
 	bot.Handle("/stats", b.handleStats)
+	bot.Handle("/top", b.handleTop)
+	bot.Handle("/search", b.handleSearch)
+	bot.Handle("/subscribe", b.handleSubscribe)
+	bot.Handle("/unsubscribe", b.handleUnsubscribe)
 	bot.Handle("/help", b.handleHelp)
+	bot.Handle("/settings", b.handleSettings)
+	bot.Handle("/submit", b.handleSubmit)
+	bot.Handle(telebot.OnAddedToGroup, b.handleAddedToGroup)
+	bot.Handle(telebot.OnMigration, b.handleMigration)
+
+	bot.Handle(&telebot.InlineButton{Unique: voteUnique}, b.handleVote)
+	b.topPager.Register(bot)
+	b.searchPager.Register(bot)
+
+	bot.Handle(telebot.OnQuery, b.handleInlineQuery)
+	bot.Handle(telebot.OnInlineResult, b.handleInlineResult)
+
+	b.setupAdminHandlers(bot)
 }
 
 func (b *Bot) startTelegramConsumer(ctx context.Context) {
@@ -107,7 +223,7 @@ func (b *Bot) startTelegramConsumer(ctx context.Context) {
 
 	go func() {
 		err := b.q.ConsumeTelegramMessages(ctx, func(msg *queue.TelegramMessage) error {
-			return b.sendMessageWithRetry(msg.ChatID, msg.Text)
+			return b.handleTelegramMessage(ctx, msg)
 		})
 		if err != nil && !errors.Is(err, context.Canceled) {
 			logger.Error("Telegram consumer error", logger.Err(err))
@@ -115,32 +231,190 @@ func (b *Bot) startTelegramConsumer(ctx context.Context) {
 	}()
 }
 
-func (b *Bot) sendMessageWithRetry(chatID int64, text string) error {
-	maxRetries := 3
-	retryDelay := time.Second
-
-	for i := 0; i < maxRetries; i++ {
-		_, err := b.tbot.Send(&telebot.Chat{ID: chatID}, text, &telebot.SendOptions{
-			ParseMode: telebot.ModeMarkdown,
-		})
+// handleTelegramMessage sends a queued message. Messages the rate limits
+// or Telegram hold back are put back with a delay, failures that retrying
+// cannot fix are recorded and acked, everything else is returned for
+// redelivery.
+//
+// Once the first part of a long message is out, the rest are sent here
+// too for as long as the chat's limits allow, so that nothing else queued
+// for the chat lands between them.
+func (b *Bot) handleTelegramMessage(ctx context.Context, msg *queue.TelegramMessage) error {
+	if delay := b.limiter.ChatDelay(msg.ChatID, time.Now()); delay > 0 {
+		b.postponeBroadcastDelivery(ctx, msg)
+		return queue.RetryAfter(ErrRateLimited, delay)
+	}
+	if err := b.limiter.Wait(ctx); err != nil {
+		return err
+	}
 
-		if err != nil {
-			errStr := err.Error()
-			if strings.Contains(errStr, "Too Many Requests") || strings.Contains(errStr, "rate") {
-				logger.Warn("Rate limited, retrying...",
-					logger.Int("retry", i+1),
-					logger.Int("max_retries", maxRetries),
-				)
-				time.Sleep(retryDelay)
-				retryDelay *= 2
-				continue
-			}
-			return fmt.Errorf("failed to send message: %w", err)
+	err := b.send(msg)
+	if delay := b.floodDelay(msg.ChatID, err); delay > 0 {
+		b.postponeBroadcastDelivery(ctx, msg)
+		return queue.RetryAfter(err, delay)
+	}
+	if to, ok := b.chatMigrated(ctx, err); ok {
+		return b.q.PublishTelegramMessage(ctx, movedMessage(msg, to))
+	}
+	if err == nil {
+		var requeued bool
+		requeued, err = b.sendRest(ctx, msg)
+		if requeued {
+			return nil
 		}
+	}
+	b.noteSendFailure(ctx, err)
+
+	if msg.BroadcastID != 0 {
+		return b.recordBroadcastDelivery(ctx, msg, err)
+	}
+	if isPermanentSendError(err) {
 		return nil
 	}
+	return err
+}
+
+// sendRest sends the parts after msg while the chat's limits allow it. The
+// handler does not wait: a part the limits or a flood wait hold back, or
+// that fails for a reason retrying could fix, is queued again with the
+// parts after it, rather than failing msg and sending the parts already
+// out a second time; requeued reports that. The chat is paused until the
+// part may go, so nothing queued for the chat is sent before then.
+func (b *Bot) sendRest(ctx context.Context, msg *queue.TelegramMessage) (requeued bool, err error) {
+	for part := msg.Next(); part != nil; part = part.Next() {
+		now := time.Now()
+		if delay := b.limiter.ChatDelay(part.ChatID, now); delay > 0 {
+			b.limiter.Pause(part.ChatID, now.Add(delay))
+			return b.requeuePart(ctx, part)
+		}
+		if err := b.limiter.Wait(ctx); err != nil {
+			return b.requeuePart(ctx, part)
+		}
+
+		err := b.send(part)
+		if err == nil {
+			continue
+		}
+		if to, ok := b.chatMigrated(ctx, err); ok {
+			return b.requeuePart(ctx, movedMessage(part, to))
+		}
+		if isPermanentSendError(err) {
+			return false, err
+		}
+
+		if b.floodDelay(part.ChatID, err) == 0 {
+			logger.Warn("Failed to send message part, queueing the rest",
+				logger.Err(err),
+				logger.Int64("chat_id", part.ChatID),
+				logger.Int("part", part.Part),
+			)
+		}
+		return b.requeuePart(ctx, part)
+	}
+	return false, nil
+}
+
+// requeuePart queues part and the parts after it again. The consumer may
+// be stopping, the rest still has to be queued.
+func (b *Bot) requeuePart(ctx context.Context, part *queue.TelegramMessage) (bool, error) {
+	if err := b.q.PublishTelegramMessage(context.WithoutCancel(ctx), part); err != nil {
+		return false, err
+	}
+	b.postponeBroadcastDelivery(ctx, part)
+	return true, nil
+}
+
+// sendPart sends one part once the rate limits allow it, waiting out any
+// flood wait Telegram asks for. It is for senders without a queue.
+func (b *Bot) sendPart(ctx context.Context, part *queue.TelegramMessage) error {
+	for {
+		if err := b.limiter.WaitChat(ctx, part.ChatID); err != nil {
+			return err
+		}
+		if err := b.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		err := b.send(part)
+		if b.floodDelay(part.ChatID, err) == 0 {
+			return err
+		}
+	}
+}
+
+// chatMigrated moves the stored state of a group that Telegram reports as
+// upgraded to a supergroup, and returns the new chat ID, or false when err
+// is not about a migration.
+func (b *Bot) chatMigrated(ctx context.Context, err error) (int64, bool) {
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) || sendErr.Kind != SendErrorMigrated || sendErr.MigratedTo == 0 {
+		return 0, false
+	}
+
+	b.migrateChat(ctx, sendErr.ChatID, sendErr.MigratedTo)
+	return sendErr.MigratedTo, true
+}
+
+// movedMessage is msg addressed to the chat it moved to. It gets an ID of
+// its own, or the queue would drop it as a copy of msg.
+func movedMessage(msg *queue.TelegramMessage, to int64) *queue.TelegramMessage {
+	moved := *msg
+	moved.ChatID = to
+	if moved.ID != "" {
+		moved.ID = fmt.Sprintf("%s@%d", msg.ID, to)
+	}
+	return &moved
+}
+
+// floodDelay returns how long Telegram asked to back off, pausing the chat
+// for that long, or 0 when err is not a flood error.
+func (b *Bot) floodDelay(chatID int64, err error) time.Duration {
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) || sendErr.Kind != SendErrorFloodWait {
+		return 0
+	}
+
+	delay := sendErr.RetryAfter
+	if delay <= 0 {
+		delay = defaultFloodDelay
+	}
+	b.limiter.Pause(chatID, time.Now().Add(delay))
+
+	logger.Warn("Rate limited by Telegram",
+		logger.Int64("chat_id", chatID),
+		logger.Duration("retry_after", delay),
+	)
+	return delay
+}
+
+// noteSendFailure logs permanent send failures and marks users who can no
+// longer be reached as inactive.
+func (b *Bot) noteSendFailure(ctx context.Context, err error) {
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) || !sendErr.Permanent() {
+		return
+	}
+
+	if !sendErr.Unreachable() {
+		logger.Error("Telegram rejected message",
+			logger.Err(sendErr.Err),
+			logger.Int64("chat_id", sendErr.ChatID),
+			logger.String("kind", string(sendErr.Kind)),
+		)
+		return
+	}
 
-	return ErrRateLimited
+	logger.Info("Chat is unreachable",
+		logger.Int64("chat_id", sendErr.ChatID),
+		logger.String("kind", string(sendErr.Kind)),
+	)
+	// Private chats share their ID with the user, groups have negative IDs.
+	if sendErr.ChatID <= 0 || b.userDB == nil {
+		return
+	}
+	if err := b.userDB.MarkInactive(ctx, sendErr.ChatID, time.Now()); err != nil {
+		logger.Error("Failed to mark user inactive", logger.Err(err), logger.Int64("user_id", sendErr.ChatID))
+	}
 }
 
 func (b *Bot) handleStart(c telebot.Context) error {
@@ -156,107 +430,236 @@ func (b *Bot) handleStart(c telebot.Context) error {
 		logger.Error("Failed to save user", logger.Err(err))
 	}
 
-	welcome := "*Welcome to Anek Bot!*\n\n" +
-		"I'll send you random jokes from Reddit and anekdot.ru.\n\n" +
-		"Commands:\n" +
-		"- /joke - Get a random joke\n" +
-		"- /joke reddit - Get a joke from Reddit\n" +
-		"- /joke anekdot - Get a joke from anekdot.ru\n" +
-		"- /stats - Bot statistics\n" +
-		"- /help - Show this help message"
-
-	return b.queueOrSend(c.Sender().ID, welcome)
+	return b.sendTemplate(c.Chat().ID, "welcome", nil)
 }
 
 func (b *Bot) handleJoke(c telebot.Context) error {
-	args := c.Args()
-	var joke *models.Joke
-	var err error
+	chatID := c.Chat().ID
+
+	source, ok := parseSourceArg(c.Args())
+	if !ok {
+		return b.queueOrSend(chatID, "Unknown source. Use: /joke, or /joke <source> such as /joke reddit")
+	}
+	found, err := b.hasJokesFrom(context.Background(), source)
+	if err != nil {
+		logger.Error("Failed to check source", logger.Err(err), logger.String("source", string(source)))
+		return b.queueOrSend(chatID, "Sorry, no jokes available right now. Try again later!")
+	}
+	if !found {
+		return b.queueOrSend(chatID, fmt.Sprintf("There are no jokes from %q.", source))
+	}
+
+	return b.sendJoke(c, source)
+}
+
+// sendJoke sends a joke to the chat the update came from, honoring the
+// chat's settings. In groups the whole chat shares one seen-jokes history.
+func (b *Bot) sendJoke(c telebot.Context, source models.JokeSource) error {
 	ctx := context.Background()
+	chatID := c.Chat().ID
 
-	if len(args) > 0 {
-		source := strings.ToLower(args[0])
-		switch source {
-		case "reddit":
-			joke, err = b.jokeDB.GetRandomBySource(ctx, models.SourceReddit)
-		case "anekdot":
-			joke, err = b.jokeDB.GetRandomBySource(ctx, models.SourceAnekdot)
-		default:
-			return b.queueOrSend(c.Sender().ID, "Unknown source. Use: /joke, /joke reddit, or /joke anekdot")
-		}
-	} else {
-		joke, err = b.jokeDB.GetRandom(ctx)
+	filter, ok := jokeFilter(b.loadChat(ctx, chatID), source)
+	if !ok {
+		return b.queueOrSend(chatID, "That source is turned off in this chat. See /settings.")
 	}
 
+	joke, repeat, err := b.jokeFor(ctx, chatID, filter)
 	if err != nil {
 		logger.Error("Failed to get joke", logger.Err(err))
-		return b.queueOrSend(c.Sender().ID, "Sorry, no jokes available right now. Try again later!")
+		return b.queueOrSend(chatID, "Sorry, no jokes available right now. Try again later!")
+	}
+
+	msg, err := b.jokeMessage(chatID, "Joke", joke, repeat)
+	if err != nil {
+		logger.Error("Failed to render joke", logger.Err(err), logger.Int64("joke_id", joke.ID))
+		return b.queueOrSend(chatID, "Sorry, no jokes available right now. Try again later!")
+	}
+	if err := b.enqueue(msg); err != nil {
+		return err
+	}
+	b.markSeen(ctx, chatID, joke.ID)
+	return nil
+}
+
+// jokeFor picks a joke the chat has not seen recently. repeat is set when
+// every joke was already seen. Callers mark the joke seen with markSeen once
+// it is on its way.
+func (b *Bot) jokeFor(ctx context.Context, chatID int64, filter database.JokeFilter) (joke *models.Joke, repeat bool, err error) {
+	joke, err = b.jokeDB.GetRandomUnseen(ctx, chatID, filter, b.cfg.RepeatWindow)
+	if errors.Is(err, database.ErrAllJokesSeen) {
+		repeat = true
+		joke, err = b.jokeDB.GetRandomMatching(ctx, filter)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return joke, repeat, nil
+}
+
+// markSeen records that the chat got the joke. A failure is only logged:
+// the joke may come up again sooner, which is not worth failing a send.
+func (b *Bot) markSeen(ctx context.Context, chatID, jokeID int64) {
+	if err := b.jokeDB.MarkSeen(ctx, chatID, jokeID); err != nil {
+		logger.Error("Failed to record joke delivery",
+			logger.Err(err),
+			logger.Int64("chat_id", chatID),
+			logger.Int64("joke_id", jokeID),
+		)
 	}
+}
 
-	sourceLabel := "[anekdot]"
-	if joke.Source == string(models.SourceReddit) {
-		sourceLabel = "[reddit]"
+// jokeMessage renders a joke with its vote buttons. An empty title leaves
+// the header out.
+func (b *Bot) jokeMessage(chatID int64, title string, joke *models.Joke, repeat bool) (*queue.TelegramMessage, error) {
+	label := joke.Source
+	if joke.Author != "" {
+		label = "from " + joke.Author
 	}
 
-	msg := fmt.Sprintf("*Joke*\n\n%s\n\n%s", joke.Content, sourceLabel)
+	msg, err := b.message(chatID, "joke", jokeView{
+		Title:   title,
+		Content: joke.Content,
+		Label:   label,
+		Repeat:  repeat,
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg.Buttons = voteButtons(joke.ID, joke.Upvotes, joke.Downvotes)
+	return msg, nil
+}
 
-	return b.queueOrSend(c.Sender().ID, msg)
+// jokeView is the data of the joke template.
+type jokeView struct {
+	Title, Content, Label string
+	Repeat                bool
 }
 
+// parseSourceArg reads an optional source name, spelled the way /settings
+// sources takes it. Whether any joke came from it is left to hasJokesFrom.
+func parseSourceArg(args []string) (models.JokeSource, bool) {
+	if len(args) == 0 {
+		return "", true
+	}
+
+	name := strings.ToLower(args[0])
+	if !sourceNamePattern.MatchString(name) || name == "all" {
+		return "", false
+	}
+	return models.JokeSource(name), true
+}
+
+// hasJokesFrom reports whether any joke came from source. No source stands
+// for all of them.
+func (b *Bot) hasJokesFrom(ctx context.Context, source models.JokeSource) (bool, error) {
+	if source == "" {
+		return true, nil
+	}
+	count, err := b.jokeDB.CountBySource(ctx, source)
+	return count > 0, err
+}
+
+// queueOrSend sends text as is, without any formatting.
 func (b *Bot) queueOrSend(chatID int64, text string) error {
+	return b.enqueue(&queue.TelegramMessage{
+		ChatID: chatID,
+		Text:   text,
+	})
+}
+
+// message renders the named template into a formatted message.
+func (b *Bot) message(chatID int64, name string, data any) (*queue.TelegramMessage, error) {
+	text, err := b.format.Render(name, data)
+	if err != nil {
+		return nil, err
+	}
+	return &queue.TelegramMessage{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: string(b.format.ParseMode()),
+	}, nil
+}
+
+// sendTemplate renders and queues the named template. Templates are fixed,
+// so a failure is a bug; the user gets a plain apology instead.
+func (b *Bot) sendTemplate(chatID int64, name string, data any) error {
+	msg, err := b.message(chatID, name, data)
+	if err != nil {
+		logger.Error("Failed to render message", logger.Err(err), logger.String("template", name))
+		return b.queueOrSend(chatID, "Something went wrong, try again later")
+	}
+	return b.enqueue(msg)
+}
+
+func (b *Bot) enqueue(msg *queue.TelegramMessage) error {
+	splitLong(msg)
 	if b.q != nil {
-		msg := &queue.TelegramMessage{
-			ChatID: chatID,
-			Text:   text,
-		}
 		if err := b.q.PublishTelegramMessage(context.Background(), msg); err != nil {
 			logger.Error("Failed to queue telegram message", logger.Err(err))
 		}
 		return nil
 	}
 
-	_, err := b.tbot.Send(&telebot.Chat{ID: chatID}, text, &telebot.SendOptions{
-		ParseMode: telebot.ModeMarkdown,
+	return b.sendNow(context.Background(), msg)
+}
+
+// deliver is enqueue for background jobs, which need to know whether the
+// message was accepted.
+func (b *Bot) deliver(ctx context.Context, msg *queue.TelegramMessage) error {
+	splitLong(msg)
+	if b.q != nil {
+		return b.q.PublishTelegramMessage(ctx, msg)
+	}
+	return b.sendNow(ctx, msg)
+}
+
+// sendNow sends msg and the parts after it without the queue, waiting for
+// the rate limits.
+func (b *Bot) sendNow(ctx context.Context, msg *queue.TelegramMessage) error {
+	for part := msg; part != nil; part = part.Next() {
+		if err := b.sendPart(ctx, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bot) send(msg *queue.TelegramMessage) error {
+	var buttons [][]queue.Button
+	if len(msg.More) == 0 {
+		buttons = msg.Buttons
+	}
+	_, err := b.tbot.Send(&telebot.Chat{ID: msg.ChatID}, msg.Text, &telebot.SendOptions{
+		ParseMode:   telebot.ParseMode(msg.ParseMode),
+		ReplyMarkup: replyMarkup(buttons),
 	})
-	return err
+	return newSendError(msg.ChatID, err)
 }
 
 func (b *Bot) handleStats(c telebot.Context) error {
 	ctx := context.Background()
 	totalJokes, err := b.jokeDB.Count(ctx)
 	if err != nil {
-		return b.queueOrSend(c.Sender().ID, "Failed to get statistics")
+		return b.queueOrSend(c.Chat().ID, "Failed to get statistics")
 	}
 
 	redditJokes, _ := b.jokeDB.CountBySource(ctx, models.SourceReddit)
 	anekdotJokes, _ := b.jokeDB.CountBySource(ctx, models.SourceAnekdot)
 	totalUsers, _ := b.userDB.Count(ctx)
 
-	stats := fmt.Sprintf(
-		"*Bot Statistics*\n\n"+
-			"Total jokes: %d\n"+
-			"Reddit jokes: %d\n"+
-			"Anekdot jokes: %d\n"+
-			"Total users: %d",
-		totalJokes, redditJokes, anekdotJokes, totalUsers,
-	)
-
-	return b.queueOrSend(c.Sender().ID, stats)
+	return b.sendTemplate(c.Chat().ID, "stats", statsView{
+		TotalJokes:   totalJokes,
+		RedditJokes:  redditJokes,
+		AnekdotJokes: anekdotJokes,
+		TotalUsers:   totalUsers,
+	})
 }
 
-func (b *Bot) handleHelp(c telebot.Context) error {
-	help := "*Help*\n\n" +
-		"Commands:\n" +
-		"- /start - Start the bot\n" +
-		"- /joke - Get a random joke\n" +
-		"- /joke reddit - Get a joke from Reddit\n" +
-		"- /joke anekdot - Get a joke from anekdot.ru\n" +
-		"- /stats - Show bot statistics\n" +
-		"- /help - Show this help message"
-
-	return b.queueOrSend(c.Sender().ID, help)
+// statsView is the data of the stats template.
+type statsView struct {
+	TotalJokes, RedditJokes, AnekdotJokes, TotalUsers int
 }
 
-func (b *Bot) handleText(c telebot.Context) error {
-	return b.queueOrSend(c.Sender().ID, "Use /joke to get a joke!")
+func (b *Bot) handleHelp(c telebot.Context) error {
+	return b.sendTemplate(c.Chat().ID, "help", nil)
 }
@@ -1,21 +1,29 @@
 package bot
 
 import (
+	"io"
+	"os"
 	"testing"
 
 	"anek-bot/internal/config"
 	"anek-bot/internal/database"
 	"anek-bot/internal/models"
 	"anek-bot/internal/queue"
+	"anek-bot/pkg/logger"
 )
 
+func TestMain(m *testing.M) {
+	logger.Init("error", io.Discard)
+	os.Exit(m.Run())
+}
+
 func TestNewBot(t *testing.T) {
 	cfg := config.BotConfig{
 		Token:     "test-token",
 		ParseMode: "Markdown",
 	}
 
-	_, err := New(cfg, nil, nil, nil)
+	_, err := New(cfg, Repositories{}, nil)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -27,15 +35,52 @@ func TestNewBotNoToken(t *testing.T) {
 		ParseMode: "Markdown",
 	}
 
-	_, err := New(cfg, nil, nil, nil)
+	_, err := New(cfg, Repositories{}, nil)
 	if err == nil {
 		t.Error("Expected error when token is empty")
 	}
 }
 
+func TestNewBotInvalidParseMode(t *testing.T) {
+	cfg := config.BotConfig{
+		Token:     "test-token",
+		ParseMode: "BBCode",
+	}
+
+	_, err := New(cfg, Repositories{}, nil)
+	if err == nil {
+		t.Error("Expected error for unknown parse mode")
+	}
+}
+
 func TestJokeRepository(t *testing.T) {
 	_ = models.SourceReddit
 	_ = models.SourceAnekdot
 	_ = queue.JokeMessage{}
 	_ = database.ErrNoJokesFound
 }
+
+func TestParseSourceArg(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		source models.JokeSource
+		ok     bool
+	}{
+		{"no args", nil, "", true},
+		{"reddit", []string{"reddit"}, models.SourceReddit, true},
+		{"anekdot uppercase", []string{"ANEKDOT"}, models.SourceAnekdot, true},
+		{"configured name", []string{"HTML"}, "html", true},
+		{"all", []string{"all"}, "", false},
+		{"invalid", []string{"r/jokes"}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, ok := parseSourceArg(tt.args)
+			if source != tt.source || ok != tt.ok {
+				t.Errorf("parseSourceArg(%v) = (%q, %v), want (%q, %v)", tt.args, source, ok, tt.source, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"anek-bot/internal/database"
+	"anek-bot/internal/models"
+	"anek-bot/internal/queue"
+	"anek-bot/pkg/logger"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	broadcastUnique = "broadcast"
+	broadcastSend   = "send"
+	broadcastCancel = "cancel"
+
+	// Telegram allows about 30 messages per second in total. Broadcasts
+	// queue a little less than that each tick, leaving room for replies.
+	broadcastBatchSize    = 25
+	broadcastTick         = time.Second
+	broadcastIdleInterval = 10 * time.Second
+
+	broadcastReportInterval = time.Minute
+	// broadcastStaleAfter is how long a queued message may go without a
+	// delivery report before it is counted as failed. Messages held back by
+	// the rate limits or a flood wait start over each time they are put
+	// back. RecordDelivery ignores reports that arrive after the broadcast
+	// finished, so an expired recipient cannot change the final counts.
+	broadcastStaleAfter = 30 * time.Minute
+)
+
+var errInvalidBroadcastData = errors.New("invalid broadcast data")
+
+func (b *Bot) handleBroadcast(c telebot.Context) error {
+	ctx := context.Background()
+	chatID := c.Chat().ID
+
+	text := commandPayload(c.Text())
+	if text == "" {
+		return b.queueOrSend(chatID, fmt.Sprintf("Usage: /broadcast <text>\n\nThe text is sent as is with the bot's parse mode (%s), so escape it yourself.", b.parseModeName()))
+	}
+
+	recipients, err := b.broadcastDB.CountRecipients(ctx)
+	if err != nil {
+		logger.Error("Failed to count broadcast recipients", logger.Err(err))
+		return b.queueOrSend(chatID, "Failed to prepare the broadcast")
+	}
+
+	br := &models.Broadcast{AdminID: c.Sender().ID, ChatID: chatID, Text: text}
+	if err := b.broadcastDB.CreateDraft(ctx, br); err != nil {
+		logger.Error("Failed to create broadcast", logger.Err(err))
+		return b.queueOrSend(chatID, "Failed to prepare the broadcast")
+	}
+
+	// The preview goes out exactly as recipients will see it, so broken
+	// markup shows up here first.
+	preview := &queue.TelegramMessage{ChatID: chatID, Text: text, ParseMode: string(b.format.ParseMode())}
+	if err := b.enqueue(preview); err != nil {
+		logger.Warn("Failed to send broadcast preview", logger.Err(err))
+	}
+
+	id := strconv.FormatInt(br.ID, 10)
+	return b.enqueue(&queue.TelegramMessage{
+		ChatID: chatID,
+		Text:   fmt.Sprintf("Send broadcast #%d above to %d users?", br.ID, recipients),
+		Buttons: [][]queue.Button{{
+			{Text: "📣 Send", Unique: broadcastUnique, Data: broadcastSend + "|" + id},
+			{Text: "✖️ Cancel", Unique: broadcastUnique, Data: broadcastCancel + "|" + id},
+		}},
+	})
+}
+
+func (b *Bot) handleBroadcastButton(c telebot.Context) error {
+	action, id, err := parseBroadcastData(c.Callback().Data)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Invalid broadcast"})
+	}
+
+	ctx := context.Background()
+	if action == broadcastCancel {
+		return b.cancelBroadcast(c, id)
+	}
+
+	br, err := b.broadcastDB.Start(ctx, id)
+	if errors.Is(err, database.ErrBroadcastNotFound) {
+		b.clearReviewButtons(c, "Already handled")
+		return c.Respond(&telebot.CallbackResponse{Text: "Already handled"})
+	}
+	if err != nil {
+		logger.Error("Failed to start broadcast", logger.Err(err), logger.Int64("broadcast_id", id))
+		return c.Respond(&telebot.CallbackResponse{Text: "Failed to start, try again later"})
+	}
+
+	logger.Info("Broadcast started",
+		logger.Int64("broadcast_id", br.ID),
+		logger.Int("recipients", br.Total),
+		logger.Int64("admin_id", c.Sender().ID),
+	)
+
+	b.clearReviewButtons(c, fmt.Sprintf("Sending to %d users, stop with /broadcast_cancel %d", br.Total, br.ID))
+	return c.Respond(&telebot.CallbackResponse{Text: "Broadcast started"})
+}
+
+func (b *Bot) cancelBroadcast(c telebot.Context, id int64) error {
+	cancelled, err := b.broadcastDB.Cancel(context.Background(), id)
+	if err != nil {
+		logger.Error("Failed to cancel broadcast", logger.Err(err), logger.Int64("broadcast_id", id))
+		return c.Respond(&telebot.CallbackResponse{Text: "Failed to cancel, try again later"})
+	}
+	if !cancelled {
+		b.clearReviewButtons(c, "Already handled")
+		return c.Respond(&telebot.CallbackResponse{Text: "Already handled"})
+	}
+
+	logger.Info("Broadcast cancelled", logger.Int64("broadcast_id", id), logger.Int64("admin_id", c.Sender().ID))
+	b.clearReviewButtons(c, "Cancelled")
+	return c.Respond(&telebot.CallbackResponse{Text: "Cancelled"})
+}
+
+func (b *Bot) handleBroadcastCancel(c telebot.Context) error {
+	chatID := c.Chat().ID
+
+	id, ok := parseIDArg(c.Args())
+	if !ok {
+		return b.queueOrSend(chatID, "Usage: /broadcast_cancel <id>")
+	}
+
+	cancelled, err := b.broadcastDB.Cancel(context.Background(), id)
+	if err != nil {
+		logger.Error("Failed to cancel broadcast", logger.Err(err), logger.Int64("broadcast_id", id))
+		return b.queueOrSend(chatID, "Failed to cancel the broadcast")
+	}
+	if !cancelled {
+		return b.queueOrSend(chatID, fmt.Sprintf("Broadcast #%d is not running.", id))
+	}
+
+	logger.Info("Broadcast cancelled", logger.Int64("broadcast_id", id), logger.Int64("admin_id", c.Sender().ID))
+	return b.queueOrSend(chatID, fmt.Sprintf("Broadcast #%d cancelled. Messages already queued will still arrive.", id))
+}
+
+func (b *Bot) parseModeName() string {
+	if mode := b.format.ParseMode(); mode != telebot.ModeDefault {
+		return string(mode)
+	}
+	return "plain text"
+}
+
+func parseBroadcastData(data string) (string, int64, error) {
+	action, idStr, ok := strings.Cut(data, "|")
+	if !ok || (action != broadcastSend && action != broadcastCancel) {
+		return "", 0, errInvalidBroadcastData
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		return "", 0, errInvalidBroadcastData
+	}
+	return action, id, nil
+}
+
+// runBroadcasts queues running broadcasts batch by batch until ctx is done.
+// Progress lives in the database, so a restarted bot picks up where it
+// stopped and replicas share the work.
+func (b *Bot) runBroadcasts(ctx context.Context) {
+	if b.broadcastDB == nil {
+		return
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if b.sendBroadcastBatch(ctx) {
+			timer.Reset(broadcastTick)
+		} else {
+			timer.Reset(broadcastIdleInterval)
+		}
+	}
+}
+
+// sendBroadcastBatch queues up to broadcastBatchSize messages across the
+// running broadcasts, oldest first, and reports on each. It returns false
+// when nothing is running.
+func (b *Bot) sendBroadcastBatch(ctx context.Context) bool {
+	running, err := b.broadcastDB.Running(ctx)
+	if err != nil {
+		logger.Error("Failed to list broadcasts", logger.Err(err))
+		return false
+	}
+
+	budget := broadcastBatchSize
+	for i := range running {
+		br := &running[i]
+		if budget > 0 {
+			budget -= b.queueBroadcast(ctx, br, budget)
+		}
+		b.reportBroadcast(ctx, br)
+	}
+	return len(running) > 0
+}
+
+func (b *Bot) queueBroadcast(ctx context.Context, br *models.Broadcast, limit int) int {
+	// Without a queue messages are sent right away. Their outcome can only
+	// be stored once the batch has released its row locks.
+	var direct []deliveryResult
+
+	queued, err := b.broadcastDB.ClaimBatch(ctx, br.ID, limit, func(telegramID int64) error {
+		msg := &queue.TelegramMessage{
+			ID:          fmt.Sprintf("broadcast:%d:%d", br.ID, telegramID),
+			ChatID:      telegramID,
+			Text:        br.Text,
+			ParseMode:   string(b.format.ParseMode()),
+			BroadcastID: br.ID,
+		}
+		splitLong(msg)
+		if b.q != nil {
+			return b.q.PublishTelegramMessage(ctx, msg)
+		}
+		direct = append(direct, deliveryResult{msg: msg, err: b.sendNow(ctx, msg)})
+		return nil
+	})
+	if err != nil {
+		logger.Error("Failed to queue broadcast batch", logger.Err(err), logger.Int64("broadcast_id", br.ID))
+	}
+
+	for _, res := range direct {
+		b.noteSendFailure(ctx, res.err)
+		b.recordBroadcastDelivery(ctx, res.msg, res.err)
+	}
+	return queued
+}
+
+type deliveryResult struct {
+	msg *queue.TelegramMessage
+	err error
+}
+
+func (b *Bot) reportBroadcast(ctx context.Context, br *models.Broadcast) {
+	if _, err := b.broadcastDB.ExpireQueued(ctx, br.ID, time.Now().Add(-broadcastStaleAfter)); err != nil {
+		logger.Error("Failed to expire broadcast deliveries", logger.Err(err), logger.Int64("broadcast_id", br.ID))
+	}
+
+	progress, err := b.broadcastDB.Progress(ctx, br.ID)
+	if err != nil {
+		logger.Error("Failed to get broadcast progress", logger.Err(err), logger.Int64("broadcast_id", br.ID))
+		return
+	}
+
+	if progress.Done() {
+		finished, err := b.broadcastDB.Finish(ctx, br.ID)
+		if err != nil {
+			logger.Error("Failed to finish broadcast", logger.Err(err), logger.Int64("broadcast_id", br.ID))
+			return
+		}
+		if finished {
+			logger.Info("Broadcast finished",
+				logger.Int64("broadcast_id", br.ID),
+				logger.Int("sent", progress.Sent),
+				logger.Int("blocked", progress.Blocked),
+				logger.Int("failed", progress.Failed),
+			)
+			b.queueOrSend(br.ChatID, formatBroadcastProgress(br.ID, progress))
+		}
+		return
+	}
+
+	due, err := b.broadcastDB.ClaimReport(ctx, br.ID, broadcastReportInterval)
+	if err != nil {
+		logger.Error("Failed to schedule broadcast report", logger.Err(err), logger.Int64("broadcast_id", br.ID))
+		return
+	}
+	if due {
+		b.queueOrSend(br.ChatID, formatBroadcastProgress(br.ID, progress))
+	}
+}
+
+func formatBroadcastProgress(id int64, p *models.BroadcastProgress) string {
+	done := p.Sent + p.Blocked + p.Failed
+
+	var sb strings.Builder
+	if p.Done() {
+		fmt.Fprintf(&sb, "Broadcast #%d finished.\n\n", id)
+	} else {
+		fmt.Fprintf(&sb, "Broadcast #%d: %d of %d done", id, done, p.Total)
+		if p.Total > 0 {
+			fmt.Fprintf(&sb, " (%d%%)", done*100/p.Total)
+		}
+		sb.WriteString(".\n\n")
+	}
+	fmt.Fprintf(&sb, "Sent: %d\nBlocked: %d\nFailed: %d", p.Sent, p.Blocked, p.Failed)
+	return sb.String()
+}
+
+// recordBroadcastDelivery stores the outcome of a broadcast message. The
+// message is acked whatever the outcome, so that a failed recipient does
+// not hold up the rest.
+func (b *Bot) recordBroadcastDelivery(ctx context.Context, msg *queue.TelegramMessage, sendErr error) error {
+	status := deliveryStatus(sendErr)
+	errText := ""
+	if sendErr != nil {
+		errText = sendErr.Error()
+	}
+
+	err := b.broadcastDB.RecordDelivery(ctx, msg.BroadcastID, msg.ChatID, status, errText)
+	if err != nil {
+		logger.Error("Failed to record broadcast delivery",
+			logger.Err(err),
+			logger.Int64("broadcast_id", msg.BroadcastID),
+			logger.Int64("chat_id", msg.ChatID),
+		)
+	}
+	return nil
+}
+
+// postponeBroadcastDelivery keeps a broadcast message that is put back to
+// wait for the limits from being expired in the meantime.
+func (b *Bot) postponeBroadcastDelivery(ctx context.Context, msg *queue.TelegramMessage) {
+	if msg.BroadcastID == 0 || b.broadcastDB == nil {
+		return
+	}
+	if err := b.broadcastDB.Postpone(ctx, msg.BroadcastID, msg.ChatID); err != nil {
+		logger.Error("Failed to postpone broadcast delivery",
+			logger.Err(err),
+			logger.Int64("broadcast_id", msg.BroadcastID),
+			logger.Int64("chat_id", msg.ChatID),
+		)
+	}
+}
+
+// deliveryStatus maps a send error to a delivery status. Users who blocked
+// the bot or cannot be reached at all count as blocked.
+func deliveryStatus(err error) models.DeliveryStatus {
+	if err == nil {
+		return models.DeliverySent
+	}
+	switch sendErrorKind(err) {
+	case SendErrorBlocked, SendErrorChatNotFound, SendErrorDeactivated:
+		return models.DeliveryBlocked
+	default:
+		return models.DeliveryFailed
+	}
+}
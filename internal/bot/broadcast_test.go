@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"anek-bot/internal/models"
+
+	"gopkg.in/telebot.v4"
+)
+
+func TestParseBroadcastData(t *testing.T) {
+	tests := []struct {
+		data       string
+		wantAction string
+		wantID     int64
+		wantErr    bool
+	}{
+		{data: "send|7", wantAction: broadcastSend, wantID: 7},
+		{data: "cancel|7", wantAction: broadcastCancel, wantID: 7},
+		{data: "approve|7", wantErr: true},
+		{data: "send|", wantErr: true},
+		{data: "send|-1", wantErr: true},
+		{data: "send", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		action, id, err := parseBroadcastData(tt.data)
+		if tt.wantErr {
+			if !errors.Is(err, errInvalidBroadcastData) {
+				t.Errorf("parseBroadcastData(%q) error = %v, want errInvalidBroadcastData", tt.data, err)
+			}
+			continue
+		}
+		if err != nil || action != tt.wantAction || id != tt.wantID {
+			t.Errorf("parseBroadcastData(%q) = %q, %d, %v", tt.data, action, id, err)
+		}
+	}
+}
+
+func TestDeliveryStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want models.DeliveryStatus
+	}{
+		{name: "sent", err: nil, want: models.DeliverySent},
+		{name: "blocked", err: fmt.Errorf("failed to send message: %w", telebot.ErrBlockedByUser), want: models.DeliveryBlocked},
+		{name: "deactivated", err: telebot.ErrUserIsDeactivated, want: models.DeliveryBlocked},
+		{name: "chat not found", err: telebot.ErrChatNotFound, want: models.DeliveryBlocked},
+		{name: "bad markup", err: errors.New("Bad Request: can't parse entities"), want: models.DeliveryFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deliveryStatus(tt.err); got != tt.want {
+				t.Errorf("deliveryStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatBroadcastProgress(t *testing.T) {
+	tests := []struct {
+		name string
+		p    models.BroadcastProgress
+		want string
+	}{
+		{
+			name: "running",
+			p:    models.BroadcastProgress{Total: 200, Pending: 100, Queued: 20, Sent: 70, Blocked: 8, Failed: 2},
+			want: "Broadcast #3: 80 of 200 done (40%).\n\nSent: 70\nBlocked: 8\nFailed: 2",
+		},
+		{
+			name: "finished",
+			p:    models.BroadcastProgress{Total: 10, Sent: 9, Blocked: 1},
+			want: "Broadcast #3 finished.\n\nSent: 9\nBlocked: 1\nFailed: 0",
+		},
+		{
+			name: "no recipients",
+			p:    models.BroadcastProgress{},
+			want: "Broadcast #3 finished.\n\nSent: 0\nBlocked: 0\nFailed: 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatBroadcastProgress(3, &tt.p); got != tt.want {
+				t.Errorf("formatBroadcastProgress() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"anek-bot/internal/database"
+	"anek-bot/internal/models"
+	"anek-bot/pkg/logger"
+
+	"gopkg.in/telebot.v4"
+)
+
+const settingsUsage = "Change settings with:\n" +
+	"- /settings sources all\n" +
+	"- /settings sources reddit anekdot\n" +
+	"- /settings nsfw on|off\n" +
+	"- /subscribe HH:MM [timezone] and /unsubscribe for the daily joke"
+
+var (
+	errInvalidSources = errors.New("invalid source list")
+
+	sourceNamePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+)
+
+func isGroup(chat *telebot.Chat) bool {
+	return chat.Type == telebot.ChatGroup || chat.Type == telebot.ChatSuperGroup
+}
+
+// addressedTo reports whether a group message is meant for the bot: it
+// mentions the bot or replies to one of its messages.
+func addressedTo(msg *telebot.Message, me *telebot.User) bool {
+	if msg == nil || me == nil {
+		return false
+	}
+
+	if msg.ReplyTo != nil && msg.ReplyTo.Sender != nil && msg.ReplyTo.Sender.ID == me.ID {
+		return true
+	}
+
+	entities := msg.Entities
+	if len(entities) == 0 {
+		entities = msg.CaptionEntities
+	}
+	for _, e := range entities {
+		switch e.Type {
+		case telebot.EntityMention:
+			if strings.EqualFold(strings.TrimPrefix(msg.EntityText(e), "@"), me.Username) {
+				return true
+			}
+		case telebot.EntityTMention:
+			if e.User != nil && e.User.ID == me.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// canChangeSettings allows anyone in a private chat and only administrators
+// in groups. Anonymous administrators post on behalf of the group itself.
+func canChangeSettings(c telebot.Context) (bool, error) {
+	chat := c.Chat()
+	if !isGroup(chat) {
+		return true, nil
+	}
+
+	if msg := c.Message(); msg != nil && msg.SenderChat != nil && msg.SenderChat.ID == chat.ID {
+		return true, nil
+	}
+
+	member, err := c.Bot().ChatMemberOf(chat, c.Sender())
+	if err != nil {
+		return false, fmt.Errorf("failed to get chat member: %w", err)
+	}
+	return member.Role == telebot.Creator || member.Role == telebot.Administrator, nil
+}
+
+// requireSettingsAccess checks canChangeSettings and tells the user when
+// they may not proceed.
+func (b *Bot) requireSettingsAccess(c telebot.Context) bool {
+	chatID := c.Chat().ID
+
+	allowed, err := canChangeSettings(c)
+	if err != nil {
+		logger.Error("Failed to check chat admin", logger.Err(err), logger.Int64("chat_id", chatID))
+		b.queueOrSend(chatID, "Could not check your permissions, try again later")
+		return false
+	}
+	if !allowed {
+		b.queueOrSend(chatID, "Only chat admins can change settings.")
+		return false
+	}
+	return true
+}
+
+// loadChat returns the chat's settings, falling back to the defaults when
+// they cannot be loaded so that jokes keep flowing.
+func (b *Bot) loadChat(ctx context.Context, chatID int64) *models.Chat {
+	if b.chatDB == nil {
+		return &models.Chat{ChatID: chatID}
+	}
+
+	chat, err := b.chatDB.Get(ctx, chatID)
+	if err != nil {
+		logger.Error("Failed to load chat settings", logger.Err(err), logger.Int64("chat_id", chatID))
+		return &models.Chat{ChatID: chatID}
+	}
+	return chat
+}
+
+// jokeFilter combines the chat's settings with a requested source. It
+// reports false when the source is not allowed in the chat.
+func jokeFilter(chat *models.Chat, source models.JokeSource) (database.JokeFilter, bool) {
+	filter := database.JokeFilter{Sources: chat.Sources, NSFW: chat.NSFW}
+	if source == "" {
+		return filter, true
+	}
+	if len(chat.Sources) > 0 && !slices.Contains(chat.Sources, string(source)) {
+		return filter, false
+	}
+	filter.Sources = []string{string(source)}
+	return filter, true
+}
+
+func (b *Bot) handleText(c telebot.Context) error {
+	if isGroup(c.Chat()) {
+		if !addressedTo(c.Message(), b.tbot.Me) {
+			return nil
+		}
+		return b.sendJoke(c, "")
+	}
+
+	return b.queueOrSend(c.Chat().ID, "Use /joke to get a joke!")
+}
+
+func (b *Bot) handleAddedToGroup(c telebot.Context) error {
+	chat := c.Chat()
+	logger.Info("Added to group",
+		logger.Int64("chat_id", chat.ID),
+		logger.String("title", chat.Title),
+	)
+
+	return b.sendTemplate(chat.ID, "group_welcome", nil)
+}
+
+// handleMigration follows a group that was upgraded to a supergroup, which
+// gets a new chat ID.
+func (b *Bot) handleMigration(c telebot.Context) error {
+	from, to := c.Migration()
+	b.migrateChat(context.Background(), from, to)
+	return nil
+}
+
+// migrateChat moves the group's settings, subscription and seen jokes to
+// its new chat ID. Doing it twice is harmless, as both the migration update
+// and a send to the old ID trigger it.
+func (b *Bot) migrateChat(ctx context.Context, from, to int64) {
+	logger.Info("Group migrated to a supergroup",
+		logger.Int64("chat_id", from),
+		logger.Int64("new_chat_id", to),
+	)
+	if b.chatDB == nil {
+		return
+	}
+	if err := b.chatDB.Migrate(ctx, from, to); err != nil {
+		logger.Error("Failed to migrate chat", logger.Err(err), logger.Int64("chat_id", from), logger.Int64("new_chat_id", to))
+	}
+}
+
+func (b *Bot) handleSettings(c telebot.Context) error {
+	ctx := context.Background()
+	chatID := c.Chat().ID
+	args := c.Args()
+
+	settings := b.loadChat(ctx, chatID)
+	if len(args) == 0 {
+		view := b.settingsView(ctx, settings)
+		view.Usage = settingsUsage
+		return b.sendTemplate(chatID, "settings", view)
+	}
+
+	if !b.requireSettingsAccess(c) {
+		return nil
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "sources":
+		sources, err := parseSourceList(args[1:])
+		if err != nil {
+			return b.queueOrSend(chatID, "Usage: /settings sources all, or a list such as /settings sources reddit anekdot")
+		}
+		for _, source := range sources {
+			found, err := b.hasJokesFrom(ctx, models.JokeSource(source))
+			if err != nil {
+				logger.Error("Failed to check source", logger.Err(err), logger.String("source", source))
+				return b.queueOrSend(chatID, "Failed to save settings, try again later")
+			}
+			if !found {
+				return b.queueOrSend(chatID, fmt.Sprintf("There are no jokes from %q.", source))
+			}
+		}
+		settings.Sources = sources
+	case "nsfw":
+		on, ok := parseSwitch(args[1:])
+		if !ok {
+			return b.queueOrSend(chatID, "Usage: /settings nsfw on|off")
+		}
+		settings.NSFW = on
+	default:
+		return b.queueOrSend(chatID, settingsUsage)
+	}
+
+	settings.Type = string(c.Chat().Type)
+	settings.Title = c.Chat().Title
+	if err := b.chatDB.Save(ctx, settings); err != nil {
+		logger.Error("Failed to save chat settings", logger.Err(err), logger.Int64("chat_id", chatID))
+		return b.queueOrSend(chatID, "Failed to save settings, try again later")
+	}
+
+	logger.Info("Chat settings changed",
+		logger.Int64("chat_id", chatID),
+		logger.Int64("user_id", c.Sender().ID),
+		logger.String("setting", strings.ToLower(args[0])),
+	)
+	view := b.settingsView(ctx, settings)
+	view.Saved = true
+	return b.sendTemplate(chatID, "settings", view)
+}
+
+// settingsView is the data of the settings template.
+type settingsView struct {
+	Sources string
+	NSFW    string
+	Daily   string
+	// Timezone of the daily joke, if there is one.
+	Timezone string
+	Saved    bool
+	Usage    string
+}
+
+func (b *Bot) settingsView(ctx context.Context, chat *models.Chat) settingsView {
+	view := settingsView{Sources: "all", NSFW: "off", Daily: "off"}
+	if len(chat.Sources) > 0 {
+		view.Sources = strings.Join(chat.Sources, ", ")
+	}
+	if chat.NSFW {
+		view.NSFW = "on"
+	}
+
+	sub, err := b.subDB.Get(ctx, chat.ChatID)
+	switch {
+	case err == nil:
+		view.Daily = fmt.Sprintf("%02d:%02d", sub.Hour, sub.Minute)
+		view.Timezone = sub.Timezone
+	case !errors.Is(err, database.ErrSubscriptionNotFound):
+		logger.Error("Failed to load subscription", logger.Err(err), logger.Int64("chat_id", chat.ChatID))
+		view.Daily = "unknown"
+	}
+	return view
+}
+
+// parseSourceList parses "all" or source names separated by spaces or
+// commas. "all" yields nil, which allows every source.
+func parseSourceList(args []string) ([]string, error) {
+	fields := strings.FieldsFunc(strings.ToLower(strings.Join(args, " ")), func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	if len(fields) == 0 {
+		return nil, errInvalidSources
+	}
+	if len(fields) == 1 && fields[0] == "all" {
+		return nil, nil
+	}
+
+	var sources []string
+	for _, f := range fields {
+		if !sourceNamePattern.MatchString(f) || f == "all" {
+			return nil, errInvalidSources
+		}
+		if !slices.Contains(sources, f) {
+			sources = append(sources, f)
+		}
+	}
+	return sources, nil
+}
+
+func parseSwitch(args []string) (on bool, ok bool) {
+	if len(args) != 1 {
+		return false, false
+	}
+	switch strings.ToLower(args[0]) {
+	case "on", "yes", "true", "1":
+		return true, true
+	case "off", "no", "false", "0":
+		return false, true
+	default:
+		return false, false
+	}
+}
@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"anek-bot/internal/models"
+
+	"gopkg.in/telebot.v4"
+)
+
+func TestAddressedTo(t *testing.T) {
+	me := &telebot.User{ID: 42, Username: "AnekBot"}
+	other := &telebot.User{ID: 7, Username: "someone"}
+
+	tests := []struct {
+		name string
+		msg  *telebot.Message
+		want bool
+	}{
+		{
+			name: "plain message",
+			msg:  &telebot.Message{Text: "hello all"},
+			want: false,
+		},
+		{
+			name: "mention",
+			msg: &telebot.Message{
+				Text:     "@anekbot tell a joke",
+				Entities: telebot.Entities{{Type: telebot.EntityMention, Offset: 0, Length: 8}},
+			},
+			want: true,
+		},
+		{
+			name: "mention of another user",
+			msg: &telebot.Message{
+				Text:     "hi @someone",
+				Entities: telebot.Entities{{Type: telebot.EntityMention, Offset: 3, Length: 8}},
+			},
+			want: false,
+		},
+		{
+			name: "mention after cyrillic text",
+			msg: &telebot.Message{
+				Text:     "привет @AnekBot",
+				Entities: telebot.Entities{{Type: telebot.EntityMention, Offset: 7, Length: 8}},
+			},
+			want: true,
+		},
+		{
+			name: "text mention",
+			msg: &telebot.Message{
+				Text:     "bot, joke please",
+				Entities: telebot.Entities{{Type: telebot.EntityTMention, Offset: 0, Length: 3, User: me}},
+			},
+			want: true,
+		},
+		{
+			name: "reply to bot",
+			msg:  &telebot.Message{Text: "more", ReplyTo: &telebot.Message{Sender: me}},
+			want: true,
+		},
+		{
+			name: "reply to someone else",
+			msg:  &telebot.Message{Text: "lol", ReplyTo: &telebot.Message{Sender: other}},
+			want: false,
+		},
+		{
+			name: "nil message",
+			msg:  nil,
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := addressedTo(tt.msg, me); got != tt.want {
+				t.Errorf("addressedTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJokeFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		chat        models.Chat
+		source      models.JokeSource
+		wantSources []string
+		wantNSFW    bool
+		wantOK      bool
+	}{
+		{name: "defaults", wantOK: true},
+		{name: "requested source", source: models.SourceReddit, wantSources: []string{"reddit"}, wantOK: true},
+		{
+			name:        "chat sources",
+			chat:        models.Chat{Sources: []string{"anekdot", "bash"}, NSFW: true},
+			wantSources: []string{"anekdot", "bash"},
+			wantNSFW:    true,
+			wantOK:      true,
+		},
+		{
+			name:        "allowed source",
+			chat:        models.Chat{Sources: []string{"anekdot", "bash"}},
+			source:      models.SourceAnekdot,
+			wantSources: []string{"anekdot"},
+			wantOK:      true,
+		},
+		{
+			name:   "disallowed source",
+			chat:   models.Chat{Sources: []string{"anekdot"}},
+			source: models.SourceReddit,
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, ok := jokeFilter(&tt.chat, tt.source)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if !reflect.DeepEqual(filter.Sources, tt.wantSources) {
+				t.Errorf("Sources = %v, want %v", filter.Sources, tt.wantSources)
+			}
+			if filter.NSFW != tt.wantNSFW {
+				t.Errorf("NSFW = %v, want %v", filter.NSFW, tt.wantNSFW)
+			}
+		})
+	}
+}
+
+func TestParseSourceList(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "all", args: []string{"all"}, want: nil},
+		{name: "spaces", args: []string{"Reddit", "anekdot"}, want: []string{"reddit", "anekdot"}},
+		{name: "commas", args: []string{"reddit,anekdot,", "reddit"}, want: []string{"reddit", "anekdot"}},
+		{name: "empty", args: nil, wantErr: true},
+		{name: "all mixed in", args: []string{"reddit", "all"}, wantErr: true},
+		{name: "bad characters", args: []string{"red*dit"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSourceList(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSourceList(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSourceList(%q) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSwitch(t *testing.T) {
+	tests := []struct {
+		args   []string
+		wantOn bool
+		wantOK bool
+	}{
+		{args: []string{"on"}, wantOn: true, wantOK: true},
+		{args: []string{"OFF"}, wantOn: false, wantOK: true},
+		{args: []string{"maybe"}, wantOK: false},
+		{args: nil, wantOK: false},
+		{args: []string{"on", "off"}, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		on, ok := parseSwitch(tt.args)
+		if on != tt.wantOn || ok != tt.wantOK {
+			t.Errorf("parseSwitch(%q) = %v, %v, want %v, %v", tt.args, on, ok, tt.wantOn, tt.wantOK)
+		}
+	}
+}
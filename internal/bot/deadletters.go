@@ -0,0 +1,157 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"anek-bot/internal/queue"
+	"anek-bot/pkg/logger"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	deadLetterListLimit    = 10
+	deadLetterReplayLimit  = 100
+	deadLetterSnippetRunes = 200
+	deadLetterTimeout      = 30 * time.Second
+)
+
+// deadLettersView is the data of the dead_letters template.
+type deadLettersView struct {
+	Total   int
+	Letters []deadLetterItem
+}
+
+type deadLetterItem struct {
+	Seq        uint64
+	Subject    string
+	Deliveries int
+	FailedAt   time.Time
+	Error      string
+	Snippet    string
+}
+
+func (b *Bot) handleDeadLetters(c telebot.Context) error {
+	chatID := c.Chat().ID
+	if b.q == nil {
+		return b.queueOrSend(chatID, "The queue is not configured, messages are sent directly.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deadLetterTimeout)
+	defer cancel()
+
+	letters, total, err := b.q.DeadLetters(ctx, deadLetterListLimit)
+	if err != nil {
+		logger.Error("Failed to list dead letters", logger.Err(err))
+		return b.queueOrSend(chatID, "Failed to list dead letters")
+	}
+
+	view := deadLettersView{Total: total}
+	for _, letter := range letters {
+		view.Letters = append(view.Letters, deadLetterItem{
+			Seq:        letter.Seq,
+			Subject:    letter.Subject,
+			Deliveries: letter.Deliveries,
+			FailedAt:   letter.FailedAt,
+			Error:      letter.Error,
+			Snippet:    truncateRunes(string(letter.Data), deadLetterSnippetRunes),
+		})
+	}
+	return b.sendTemplate(chatID, "dead_letters", view)
+}
+
+func (b *Bot) handleDeadLetterReplay(c telebot.Context) error {
+	chatID := c.Chat().ID
+	if b.q == nil {
+		return b.queueOrSend(chatID, "The queue is not configured, messages are sent directly.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deadLetterTimeout)
+	defer cancel()
+
+	all, seq, ok := parseDeadLetterArg(c.Args())
+	if !ok {
+		return b.queueOrSend(chatID, "Usage: /deadletter_replay <seq|all>")
+	}
+	if !all {
+		if err := b.q.ReplayDeadLetter(ctx, seq); err != nil {
+			return b.queueOrSend(chatID, deadLetterFailure("replay", seq, err))
+		}
+		logger.Info("Dead letter replayed", logger.Int64("admin_id", senderID(c)), logger.Any("seq", seq))
+		return b.queueOrSend(chatID, fmt.Sprintf("Dead letter #%d is queued again.", seq))
+	}
+
+	letters, total, err := b.q.DeadLetters(ctx, deadLetterReplayLimit)
+	if err != nil {
+		logger.Error("Failed to list dead letters", logger.Err(err))
+		return b.queueOrSend(chatID, "Failed to list dead letters")
+	}
+
+	replayed := 0
+	for _, letter := range letters {
+		if err := b.q.ReplayDeadLetter(ctx, letter.Seq); err != nil {
+			logger.Error("Failed to replay dead letter", logger.Err(err), logger.Any("seq", letter.Seq))
+			continue
+		}
+		replayed++
+	}
+	logger.Info("Dead letters replayed", logger.Int64("admin_id", senderID(c)), logger.Int("count", replayed))
+
+	text := fmt.Sprintf("Queued %d of %d dead letters again.", replayed, total)
+	if left := total - replayed; left > 0 {
+		text += fmt.Sprintf(" %d are left, run the command again for more.", left)
+	}
+	return b.queueOrSend(chatID, text)
+}
+
+func (b *Bot) handleDeadLetterPurge(c telebot.Context) error {
+	chatID := c.Chat().ID
+	if b.q == nil {
+		return b.queueOrSend(chatID, "The queue is not configured, messages are sent directly.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deadLetterTimeout)
+	defer cancel()
+
+	all, seq, ok := parseDeadLetterArg(c.Args())
+	if !ok {
+		return b.queueOrSend(chatID, "Usage: /deadletter_purge <seq|all>")
+	}
+	if !all {
+		if err := b.q.DeleteDeadLetter(ctx, seq); err != nil {
+			return b.queueOrSend(chatID, deadLetterFailure("delete", seq, err))
+		}
+		logger.Info("Dead letter deleted", logger.Int64("admin_id", senderID(c)), logger.Any("seq", seq))
+		return b.queueOrSend(chatID, fmt.Sprintf("Dead letter #%d is deleted.", seq))
+	}
+
+	purged, err := b.q.PurgeDeadLetters(ctx)
+	if err != nil {
+		logger.Error("Failed to purge dead letters", logger.Err(err))
+		return b.queueOrSend(chatID, "Failed to purge dead letters")
+	}
+	logger.Info("Dead letters purged", logger.Int64("admin_id", senderID(c)), logger.Int("count", purged))
+	return b.queueOrSend(chatID, fmt.Sprintf("Purged %d dead letters.", purged))
+}
+
+// parseDeadLetterArg reads the argument of the replay and purge commands:
+// "all" or a dead letter number.
+func parseDeadLetterArg(args []string) (all bool, seq uint64, ok bool) {
+	if len(args) == 1 && strings.EqualFold(args[0], "all") {
+		return true, 0, true
+	}
+	id, ok := parseIDArg(args)
+	return false, uint64(id), ok
+}
+
+func deadLetterFailure(action string, seq uint64, err error) string {
+	if errors.Is(err, queue.ErrDeadLetterNotFound) {
+		return fmt.Sprintf("There is no dead letter #%d.", seq)
+	}
+	logger.Error("Failed to "+action+" dead letter", logger.Err(err), logger.Any("seq", seq))
+	return fmt.Sprintf("Failed to %s dead letter #%d", action, seq)
+}
@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"anek-bot/internal/queue"
+)
+
+func TestParseDeadLetterArg(t *testing.T) {
+	tests := []struct {
+		args    []string
+		wantAll bool
+		wantSeq uint64
+		wantOK  bool
+	}{
+		{args: []string{"all"}, wantAll: true, wantOK: true},
+		{args: []string{"ALL"}, wantAll: true, wantOK: true},
+		{args: []string{"#7"}, wantSeq: 7, wantOK: true},
+		{args: []string{"7"}, wantSeq: 7, wantOK: true},
+		{args: nil},
+		{args: []string{"all", "7"}},
+		{args: []string{"some"}},
+	}
+
+	for _, tt := range tests {
+		all, seq, ok := parseDeadLetterArg(tt.args)
+		if all != tt.wantAll || seq != tt.wantSeq || ok != tt.wantOK {
+			t.Errorf("parseDeadLetterArg(%q) = %v, %d, %v, want %v, %d, %v",
+				tt.args, all, seq, ok, tt.wantAll, tt.wantSeq, tt.wantOK)
+		}
+	}
+}
+
+func TestRenderDeadLetters(t *testing.T) {
+	f, err := NewFormatter("HTML")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	view := deadLettersView{Total: 3, Letters: []deadLetterItem{{
+		Seq:        12,
+		Subject:    queue.TelegramSubject,
+		Deliveries: 10,
+		FailedAt:   time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC),
+		Error:      "chat <not> found",
+		Snippet:    `{"chat_id":1}`,
+	}}}
+	got, err := f.Render("dead_letters", view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"the oldest 1 below", "<code>#12</code> telegram.send, 10 deliveries, 2026-01-02 03:04", "chat &lt;not&gt; found"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("dead_letters = %q, want it to contain %q", got, want)
+		}
+	}
+
+	empty, err := f.Render("dead_letters", deadLettersView{})
+	if err != nil || !strings.Contains(empty, "Nothing failed.") {
+		t.Errorf("empty dead_letters = %q, %v", empty, err)
+	}
+}
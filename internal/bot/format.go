@@ -0,0 +1,209 @@
+package bot
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"gopkg.in/telebot.v4"
+)
+
+// Formatted is text that is already valid for a Formatter's parse mode.
+// Templates escape every other value they print.
+type Formatted string
+
+// Formatter builds message text for one Telegram parse mode. Jokes, names
+// and other outside text are escaped, so they can never break the markup.
+type Formatter struct {
+	mode      telebot.ParseMode
+	escaper   *strings.Replacer
+	templates *template.Template
+}
+
+var (
+	markdownEscaper   = strings.NewReplacer("_", `\_`, "*", `\*`, "`", "\\`", "[", `\[`)
+	markdownV2Escaper = strings.NewReplacer(
+		`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+		"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+		"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+	)
+	markdownV2CodeEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+	htmlEscaper           = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// NewFormatter returns a formatter for a BotConfig.ParseMode value:
+// Markdown, MarkdownV2, HTML, or empty for plain text.
+func NewFormatter(parseMode string) (*Formatter, error) {
+	f := &Formatter{}
+	switch strings.ToLower(parseMode) {
+	case "", "none", "plain":
+		f.mode = telebot.ModeDefault
+	case "markdown":
+		f.mode = telebot.ModeMarkdown
+		f.escaper = markdownEscaper
+	case "markdownv2":
+		f.mode = telebot.ModeMarkdownV2
+		f.escaper = markdownV2Escaper
+	case "html":
+		f.mode = telebot.ModeHTML
+		f.escaper = htmlEscaper
+	default:
+		return nil, fmt.Errorf("unknown parse mode %q", parseMode)
+	}
+
+	tmpl, err := template.New("messages").Funcs(template.FuncMap{
+		"bold":   f.Bold,
+		"italic": f.Italic,
+		"code":   f.Code,
+		"escape": f.escapeValue,
+	}).Parse(messageTemplates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message templates: %w", err)
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			f.escapeTree(t.Tree.Root)
+		}
+	}
+	f.templates = tmpl
+
+	return f, nil
+}
+
+func (f *Formatter) ParseMode() telebot.ParseMode {
+	return f.mode
+}
+
+// Escape makes s safe to embed as plain text.
+func (f *Formatter) Escape(s string) string {
+	if f.escaper == nil {
+		return s
+	}
+	return f.escaper.Replace(s)
+}
+
+func (f *Formatter) Bold(v any) Formatted {
+	return f.entity(toText(v), "*", "<b>", "</b>")
+}
+
+func (f *Formatter) Italic(v any) Formatted {
+	return f.entity(toText(v), "_", "<i>", "</i>")
+}
+
+func (f *Formatter) Code(v any) Formatted {
+	s := toText(v)
+	switch f.mode {
+	case telebot.ModeMarkdownV2:
+		if s == "" {
+			return ""
+		}
+		return Formatted("`" + markdownV2CodeEscaper.Replace(s) + "`")
+	case telebot.ModeMarkdown:
+		return legacyEntity(s, "`")
+	case telebot.ModeHTML:
+		return Formatted("<code>" + htmlEscaper.Replace(s) + "</code>")
+	default:
+		return Formatted(s)
+	}
+}
+
+func (f *Formatter) entity(s, mark, open, close string) Formatted {
+	switch f.mode {
+	case telebot.ModeMarkdownV2:
+		if s == "" {
+			return ""
+		}
+		return Formatted(mark + markdownV2Escaper.Replace(s) + mark)
+	case telebot.ModeMarkdown:
+		return legacyEntity(s, mark)
+	case telebot.ModeHTML:
+		return Formatted(open + htmlEscaper.Replace(s) + close)
+	default:
+		return Formatted(s)
+	}
+}
+
+// legacyEntity wraps s in mark for the old Markdown mode, which does not
+// allow escaping inside an entity. The entity is closed around every mark
+// in s, which is written escaped, and reopened after it.
+func legacyEntity(s, mark string) Formatted {
+	parts := strings.Split(s, mark)
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = mark + part + mark
+		}
+	}
+	return Formatted(strings.Join(parts, `\`+mark))
+}
+
+// Render executes the named message template.
+func (f *Formatter) Render(name string, data any) (string, error) {
+	var sb strings.Builder
+	if err := f.templates.ExecuteTemplate(&sb, name, data); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	return sb.String(), nil
+}
+
+// escapeValue is appended to every template action, see escapeTree.
+func (f *Formatter) escapeValue(v any) Formatted {
+	if formatted, ok := v.(Formatted); ok {
+		return formatted
+	}
+	return Formatted(f.Escape(toText(v)))
+}
+
+// escapeTree escapes the literal text of a template and pipes every value it
+// prints through escapeValue, the way html/template does for HTML. Message
+// templates are therefore written as plain text.
+func (f *Formatter) escapeTree(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			f.escapeTree(child)
+		}
+	case *parse.TextNode:
+		n.Text = []byte(f.Escape(string(n.Text)))
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier("escape").SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		f.escapeTree(n.List)
+		f.escapeTree(n.ElseList)
+	case *parse.RangeNode:
+		f.escapeTree(n.List)
+		f.escapeTree(n.ElseList)
+	case *parse.WithNode:
+		f.escapeTree(n.List)
+		f.escapeTree(n.ElseList)
+	}
+}
+
+// toText prints v the way templates do, following pointers.
+func toText(v any) string {
+	rv := reflect.ValueOf(v)
+	if s, ok := v.(fmt.Stringer); ok && (rv.Kind() != reflect.Pointer || !rv.IsNil()) {
+		return s.String()
+	}
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return ""
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(rv.Interface())
+}
@@ -0,0 +1,182 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"anek-bot/internal/models"
+	"anek-bot/internal/parser"
+	"anek-bot/internal/queue"
+)
+
+func TestNewFormatter(t *testing.T) {
+	for _, mode := range []string{"", "plain", "Markdown", "markdownv2", "HTML"} {
+		if _, err := NewFormatter(mode); err != nil {
+			t.Errorf("NewFormatter(%q) error: %v", mode, err)
+		}
+	}
+	if _, err := NewFormatter("BBCode"); err == nil {
+		t.Error("Expected error for unknown parse mode")
+	}
+}
+
+func TestFormatterEscape(t *testing.T) {
+	const nasty = "*bold* _it_ [link](x) `code` <b>&amp; a.b!"
+
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"", nasty},
+		{"Markdown", "\\*bold\\* \\_it\\_ \\[link](x) \\`code\\` <b>&amp; a.b!"},
+		{"MarkdownV2", "\\*bold\\* \\_it\\_ \\[link\\]\\(x\\) \\`code\\` <b\\>&amp; a\\.b\\!"},
+		{"HTML", "*bold* _it_ [link](x) `code` &lt;b&gt;&amp;amp; a.b!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			f, err := NewFormatter(tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Escape(nasty); got != tt.want {
+				t.Errorf("Escape() = %q, want %q", got, tt.want)
+			}
+			if got := f.Escape(""); got != "" {
+				t.Errorf("Escape(\"\") = %q", got)
+			}
+		})
+	}
+}
+
+func TestFormatterEntities(t *testing.T) {
+	tests := []struct {
+		mode   string
+		bold   Formatted
+		italic Formatted
+		code   Formatted
+	}{
+		{"", "a*b_c`d", "a*b_c`d", "a*b_c`d"},
+		{"Markdown", "*a*\\**b_c`d*", "_a*b_\\__c`d_", "`a*b_c`\\``d`"},
+		{"MarkdownV2", "*a\\*b\\_c\\`d*", "_a\\*b\\_c\\`d_", "`a*b_c\\`d`"},
+		{"HTML", "<b>a*b_c`d</b>", "<i>a*b_c`d</i>", "<code>a*b_c`d</code>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			f, err := NewFormatter(tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Bold("a*b_c`d"); got != tt.bold {
+				t.Errorf("Bold() = %q, want %q", got, tt.bold)
+			}
+			if got := f.Italic("a*b_c`d"); got != tt.italic {
+				t.Errorf("Italic() = %q, want %q", got, tt.italic)
+			}
+			if got := f.Code("a*b_c`d"); got != tt.code {
+				t.Errorf("Code() = %q, want %q", got, tt.code)
+			}
+		})
+	}
+}
+
+func TestLegacyEntity(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Formatted
+	}{
+		{"", ""},
+		{"plain", "*plain*"},
+		{"*", "\\*"},
+		{"a*b", "*a*\\**b*"},
+		{"*a*", "\\**a*\\*"},
+	}
+
+	for _, tt := range tests {
+		if got := legacyEntity(tt.in, "*"); got != tt.want {
+			t.Errorf("legacyEntity(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderEscapesValues(t *testing.T) {
+	f, err := NewFormatter("MarkdownV2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := f.Render("submission", &models.Submission{ID: 7, Author: "@john_doe", Content: "2*2=4 (maybe)."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "*New submission \\#7* from @john\\_doe\n\n2\\*2\\=4 \\(maybe\\)\\."
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+// TestRenderAllTemplates renders every template in every mode with content
+// full of markup characters, so a template that breaks escaping fails here.
+func TestRenderAllTemplates(t *testing.T) {
+	const nasty = "*_[`<b>&.!-#(x)"
+
+	joke := &models.Joke{
+		ID:          1,
+		Source:      string(models.SourceReddit),
+		Content:     nasty,
+		SourceURL:   "https://example.com/a_b",
+		Author:      "u_ser",
+		Hash:        "ab_cd",
+		DuplicateOf: new(int64),
+		CreatedAt:   time.Date(2025, 1, 2, 3, 4, 0, 0, time.UTC),
+	}
+	list := listPage{Title: nasty, Query: nasty, Page: 2, Jokes: listItems([]models.Joke{*joke}, 0)}
+	status := &queue.Status{Stream: "ANEK", Consumers: []queue.ConsumerStatus{{Name: "tg_consumer"}}}
+
+	data := map[string]any{
+		"welcome":       nil,
+		"help":          nil,
+		"group_welcome": nil,
+		"stats":         statsView{TotalJokes: 3},
+		"joke":          jokeView{Title: nasty, Content: nasty, Label: "from u_ser", Repeat: true},
+		"joke_list":     list.Jokes,
+		"top":           list,
+		"search":        list,
+		"settings":      settingsView{Sources: nasty, NSFW: "off", Daily: "09:00", Timezone: "Europe/Kyiv", Saved: true, Usage: nasty},
+		"submission":    &models.Submission{ID: 1, Author: "@a_b", Content: nasty},
+		"admin_help":    nil,
+		"joke_info":     joke,
+		"reparse":       []parser.Result{{Source: "anekdot"}, {Source: "reddit", Err: errors.New("boom")}},
+		"queue_status":  queueStatusView{Status: status},
+		"dead_letters":  deadLettersView{Total: 2, Letters: []deadLetterItem{{Seq: 7, Subject: queue.TelegramSubject, Deliveries: 10, Error: nasty, Snippet: nasty}}},
+	}
+
+	for _, mode := range []string{"", "Markdown", "MarkdownV2", "HTML"} {
+		f, err := NewFormatter(mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tmpl := range f.templates.Templates() {
+			name := tmpl.Name()
+			if name == "messages" {
+				continue
+			}
+			d, ok := data[name]
+			if !ok {
+				t.Errorf("no test data for template %q", name)
+				continue
+			}
+			got, err := f.Render(name, d)
+			if err != nil {
+				t.Errorf("%s/%s: %v", mode, name, err)
+				continue
+			}
+			if mode == "HTML" && strings.Contains(got, "<b>&") {
+				t.Errorf("%s/%s: unescaped content in %q", mode, name, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"anek-bot/internal/models"
+	"anek-bot/pkg/logger"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	inlineResultLimit = 20
+	inlineTitleRunes  = 60
+	inlineDescRunes   = 120
+
+	// Random picks differ per user and per request, search results are the
+	// same for everyone and can be cached by Telegram for longer.
+	inlineRandomCacheSeconds = 5
+	inlineSearchCacheSeconds = 300
+)
+
+func (b *Bot) handleInlineQuery(c telebot.Context) error {
+	q := c.Query()
+	text := strings.Join(strings.Fields(q.Text), " ")
+	offset, _ := strconv.Atoi(q.Offset)
+	if offset < 0 {
+		offset = 0
+	}
+
+	ctx := context.Background()
+	resp := &telebot.QueryResponse{}
+
+	var (
+		jokes []models.Joke
+		err   error
+	)
+	if text == "" {
+		jokes, err = b.jokeDB.SampleRandom(ctx, inlineResultLimit)
+		resp.CacheTime = inlineRandomCacheSeconds
+		resp.IsPersonal = true
+	} else {
+		var hasMore bool
+		jokes, hasMore, err = b.jokeDB.Search(ctx, text, inlineResultLimit, offset)
+		resp.CacheTime = inlineSearchCacheSeconds
+		if hasMore {
+			resp.NextOffset = strconv.Itoa(offset + inlineResultLimit)
+		}
+	}
+
+	if err != nil {
+		logger.Error("Failed to answer inline query",
+			logger.Err(err),
+			logger.Int64("user_id", c.Sender().ID),
+			logger.String("query", text),
+		)
+		return c.Answer(&telebot.QueryResponse{CacheTime: 1, IsPersonal: true})
+	}
+
+	resp.Results = inlineResults(jokes)
+	if len(jokes) == 0 && offset == 0 {
+		resp.Button = &telebot.QueryResponseButton{
+			Text:  "Nothing found, open the bot",
+			Start: "inline",
+		}
+	}
+
+	return c.Answer(resp)
+}
+
+func inlineResults(jokes []models.Joke) telebot.Results {
+	results := make(telebot.Results, 0, len(jokes))
+	for _, joke := range jokes {
+		results = append(results, &telebot.ArticleResult{
+			ResultBase:  telebot.ResultBase{ID: strconv.FormatInt(joke.ID, 10)},
+			Title:       truncateRunes(firstLine(joke.Content), inlineTitleRunes),
+			Description: truncateRunes(strings.Join(strings.Fields(joke.Content), " "), inlineDescRunes),
+			// Inline results are a single message, so the tail of a
+			// joke that does not fit is left out.
+			Text: splitMessage(joke.Content, telebot.ModeDefault, messageLimit)[0],
+		})
+	}
+	return results
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if line, _, ok := strings.Cut(s, "\n"); ok {
+		return strings.TrimSpace(line)
+	}
+	return s
+}
+
+// handleInlineResult receives chosen inline results. Telegram only sends
+// these when inline feedback is enabled for the bot in @BotFather.
+func (b *Bot) handleInlineResult(c telebot.Context) error {
+	result := c.InlineResult()
+
+	jokeID, err := strconv.ParseInt(result.ResultID, 10, 64)
+	if err != nil {
+		logger.Warn("Unexpected inline result id", logger.String("result_id", result.ResultID))
+		return nil
+	}
+
+	ctx := context.Background()
+	if err := b.jokeDB.RecordShare(ctx, c.Sender().ID, jokeID, result.Query); err != nil {
+		logger.Error("Failed to record inline share",
+			logger.Err(err),
+			logger.Int64("user_id", c.Sender().ID),
+			logger.Int64("joke_id", jokeID),
+		)
+		return nil
+	}
+	if err := b.jokeDB.MarkSeen(ctx, c.Sender().ID, jokeID); err != nil {
+		logger.Error("Failed to record joke delivery", logger.Err(err), logger.Int64("joke_id", jokeID))
+	}
+
+	logger.Info("Joke shared inline",
+		logger.Int64("user_id", c.Sender().ID),
+		logger.Int64("joke_id", jokeID),
+	)
+	return nil
+}
@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"anek-bot/internal/models"
+
+	"gopkg.in/telebot.v4"
+)
+
+func TestInlineResults(t *testing.T) {
+	jokes := []models.Joke{
+		{ID: 7, Content: "  Штирлиц шёл по лесу.\nВдруг из-за угла...  "},
+		{ID: 42, Content: strings.Repeat("очень длинный анекдот ", 20)},
+	}
+
+	results := inlineResults(jokes)
+	if len(results) != len(jokes) {
+		t.Fatalf("len(results) = %d, want %d", len(results), len(jokes))
+	}
+
+	first, ok := results[0].(*telebot.ArticleResult)
+	if !ok {
+		t.Fatalf("results[0] is %T, want *telebot.ArticleResult", results[0])
+	}
+	if first.ID != "7" {
+		t.Errorf("ID = %q, want %q", first.ID, "7")
+	}
+	if first.Title != "Штирлиц шёл по лесу." {
+		t.Errorf("Title = %q", first.Title)
+	}
+	if first.Description != "Штирлиц шёл по лесу. Вдруг из-за угла..." {
+		t.Errorf("Description = %q", first.Description)
+	}
+	if first.Text != jokes[0].Content {
+		t.Errorf("Text should carry the full joke, got %q", first.Text)
+	}
+
+	// truncateRunes appends an ellipsis after the cut.
+	second := results[1].(*telebot.ArticleResult)
+	if n := utf8.RuneCountInString(second.Title); n > inlineTitleRunes+1 {
+		t.Errorf("Title has %d runes, want at most %d", n, inlineTitleRunes+1)
+	}
+	if n := utf8.RuneCountInString(second.Description); n > inlineDescRunes+1 {
+		t.Errorf("Description has %d runes, want at most %d", n, inlineDescRunes+1)
+	}
+}
+
+func TestFirstLine(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "single line", in: "joke", want: "joke"},
+		{name: "multi line", in: "first\nsecond", want: "first"},
+		{name: "leading blank", in: "\n  first  \nsecond", want: "first"},
+		{name: "empty", in: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := firstLine(tt.in); got != tt.want {
+				t.Errorf("firstLine(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"anek-bot/internal/queue"
+	"anek-bot/pkg/logger"
+
+	"gopkg.in/telebot.v4"
+)
+
+// Callback data is limited to 64 bytes by Telegram, including the "\f<unique>|"
+// prefix telebot adds.
+const (
+	maxCallbackData = 64
+	maxPageDigits   = 4
+)
+
+var errInvalidPageData = errors.New("invalid page data")
+
+// PageFunc renders one page of a listing. args is the opaque state the
+// listing was started with, page is zero-based.
+type PageFunc func(ctx context.Context, args string, page int) (text string, hasNext bool, err error)
+
+// Paginator sends listings with Prev/Next inline buttons and flips pages in
+// place when they are pressed. The state needed to re-render a page travels
+// in the callback data, so it must stay short.
+type Paginator struct {
+	unique    string
+	parseMode telebot.ParseMode
+	render    PageFunc
+}
+
+// NewPaginator returns a paginator for pages rendered in parseMode.
+func NewPaginator(unique string, parseMode telebot.ParseMode, render PageFunc) *Paginator {
+	return &Paginator{unique: unique, parseMode: parseMode, render: render}
+}
+
+func (p *Paginator) Register(bot *telebot.Bot) {
+	bot.Handle(&telebot.InlineButton{Unique: p.unique}, p.handle)
+}
+
+// Message renders the first page as a queued Telegram message. Args too
+// long for callback data are truncated up front so that every page is
+// rendered from the same state.
+func (p *Paginator) Message(ctx context.Context, chatID int64, args string) (*queue.TelegramMessage, error) {
+	args = strings.TrimSpace(truncateBytes(args, p.maxArgs()))
+
+	text, hasNext, err := p.render(ctx, args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &queue.TelegramMessage{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: string(p.parseMode),
+		Buttons:   p.buttons(args, 0, hasNext),
+	}, nil
+}
+
+func (p *Paginator) buttons(args string, page int, hasNext bool) [][]queue.Button {
+	var row []queue.Button
+	if page > 0 {
+		row = append(row, queue.Button{Text: "« Prev", Unique: p.unique, Data: p.encode(args, page-1)})
+	}
+	if hasNext {
+		row = append(row, queue.Button{Text: "Next »", Unique: p.unique, Data: p.encode(args, page+1)})
+	}
+	if len(row) == 0 {
+		return nil
+	}
+	return [][]queue.Button{row}
+}
+
+// maxArgs leaves room for page numbers up to maxPageDigits digits.
+func (p *Paginator) maxArgs() int {
+	return maxCallbackData - len(p.unique) - 2 - maxPageDigits - 1
+}
+
+func (p *Paginator) encode(args string, page int) string {
+	data := strconv.Itoa(page) + "|" + args
+	if limit := maxCallbackData - len(p.unique) - 2; len(data) > limit {
+		data = truncateBytes(data, limit)
+	}
+	return data
+}
+
+func decodePageData(data string) (string, int, error) {
+	pageStr, args, ok := strings.Cut(data, "|")
+	if !ok {
+		return "", 0, errInvalidPageData
+	}
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 0 {
+		return "", 0, errInvalidPageData
+	}
+	return args, page, nil
+}
+
+func (p *Paginator) handle(c telebot.Context) error {
+	args, page, err := decodePageData(c.Callback().Data)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Invalid page"})
+	}
+
+	text, hasNext, err := p.render(context.Background(), args, page)
+	if err != nil {
+		logger.Error("Failed to render page",
+			logger.Err(err),
+			logger.String("listing", p.unique),
+			logger.Int("page", page),
+		)
+		return c.Respond(&telebot.CallbackResponse{Text: "Failed to load page, try again later"})
+	}
+
+	err = c.Edit(text, &telebot.SendOptions{
+		ParseMode:   p.parseMode,
+		ReplyMarkup: replyMarkup(p.buttons(args, page, hasNext)),
+	})
+	if err != nil && !isNotModified(err) {
+		logger.Warn("Failed to update page", logger.Err(err), logger.String("listing", p.unique))
+	}
+
+	return c.Respond()
+}
+
+// truncateBytes cuts s to at most n bytes without splitting a UTF-8 rune.
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
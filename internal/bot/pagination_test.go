@@ -0,0 +1,122 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gopkg.in/telebot.v4"
+)
+
+func TestPaginatorButtons(t *testing.T) {
+	p := NewPaginator("list", telebot.ModeMarkdown, nil)
+
+	tests := []struct {
+		name    string
+		page    int
+		hasNext bool
+		texts   []string
+	}{
+		{"single page", 0, false, nil},
+		{"first page", 0, true, []string{"Next »"}},
+		{"middle page", 2, true, []string{"« Prev", "Next »"}},
+		{"last page", 3, false, []string{"« Prev"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buttons := p.buttons("week reddit", tt.page, tt.hasNext)
+			if tt.texts == nil {
+				if buttons != nil {
+					t.Fatalf("Expected no buttons, got %v", buttons)
+				}
+				return
+			}
+			if len(buttons) != 1 || len(buttons[0]) != len(tt.texts) {
+				t.Fatalf("Unexpected layout: %v", buttons)
+			}
+			for i, btn := range buttons[0] {
+				if btn.Text != tt.texts[i] || btn.Unique != "list" {
+					t.Errorf("Button %d = %+v", i, btn)
+				}
+			}
+		})
+	}
+}
+
+func TestPageDataRoundTrip(t *testing.T) {
+	p := NewPaginator("list", telebot.ModeMarkdown, nil)
+
+	args, page, err := decodePageData(p.encode("week reddit", 4))
+	if err != nil {
+		t.Fatalf("decodePageData() error = %v", err)
+	}
+	if args != "week reddit" || page != 4 {
+		t.Errorf("decodePageData() = (%q, %d), want (\"week reddit\", 4)", args, page)
+	}
+
+	for _, data := range []string{"", "abc", "x|args", "-1|args"} {
+		if _, _, err := decodePageData(data); err == nil {
+			t.Errorf("decodePageData(%q) expected error", data)
+		}
+	}
+}
+
+func TestPageDataFitsCallbackLimit(t *testing.T) {
+	p := NewPaginator("search", telebot.ModeMarkdown, nil)
+
+	data := p.encode(strings.Repeat("шутка ", 20), 12)
+	if total := len("\f" + "search" + "|" + data); total > maxCallbackData {
+		t.Errorf("Callback data is %d bytes, want <= %d", total, maxCallbackData)
+	}
+	if _, page, err := decodePageData(data); err != nil || page != 12 {
+		t.Errorf("Truncated data did not decode: page=%d err=%v", page, err)
+	}
+}
+
+func TestPaginatorMessage(t *testing.T) {
+	p := NewPaginator("list", telebot.ModeMarkdown, func(ctx context.Context, args string, page int) (string, bool, error) {
+		return "page " + args, true, nil
+	})
+
+	msg, err := p.Message(context.Background(), 99, "all")
+	if err != nil {
+		t.Fatalf("Message() error = %v", err)
+	}
+	if msg.ChatID != 99 || msg.Text != "page all" {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+	if len(msg.Buttons) != 1 || msg.Buttons[0][0].Data != "1|all" {
+		t.Errorf("Unexpected buttons: %+v", msg.Buttons)
+	}
+}
+
+func TestPaginatorMessageTruncatesArgs(t *testing.T) {
+	var rendered []string
+	p := NewPaginator("search", telebot.ModeMarkdown, func(ctx context.Context, args string, page int) (string, bool, error) {
+		rendered = append(rendered, args)
+		return args, true, nil
+	})
+
+	msg, err := p.Message(context.Background(), 1, strings.Repeat("шутка ", 20))
+	if err != nil {
+		t.Fatalf("Message() error = %v", err)
+	}
+
+	args, _, err := decodePageData(msg.Buttons[0][0].Data)
+	if err != nil {
+		t.Fatalf("decodePageData() error = %v", err)
+	}
+	if args != rendered[0] {
+		t.Errorf("Next page args %q differ from first page args %q", args, rendered[0])
+	}
+}
+
+func TestTruncateBytes(t *testing.T) {
+	if got := truncateBytes("привет", 3); got != "п" {
+		t.Errorf("truncateBytes() = %q, want %q", got, "п")
+	}
+	if got := truncateBytes("hello", 10); got != "hello" {
+		t.Errorf("truncateBytes() = %q, want hello", got)
+	}
+}
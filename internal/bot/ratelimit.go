@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"anek-bot/internal/config"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// chatLimiterIdle is how long an unused per-chat bucket is kept.
+	chatLimiterIdle     = 10 * time.Minute
+	chatLimiterSweepGap = time.Minute
+)
+
+// SendLimiter is a global token bucket plus one bucket per chat in front of
+// every outgoing message. Chats Telegram told to back off are paused until
+// the time it gave.
+type SendLimiter struct {
+	global    *rate.Limiter
+	chatRate  rate.Limit
+	groupRate rate.Limit
+	burst     int
+
+	mu        sync.Mutex
+	chats     map[int64]*chatLimiter
+	lastSweep time.Time
+}
+
+type chatLimiter struct {
+	limiter     *rate.Limiter
+	pausedUntil time.Time
+	lastUsed    time.Time
+}
+
+func NewSendLimiter(cfg config.RateLimitConfig) *SendLimiter {
+	burst := max(cfg.Burst, 1)
+	return &SendLimiter{
+		global:    rate.NewLimiter(limitOrInf(cfg.GlobalPerSecond), max(int(cfg.GlobalPerSecond), 1)),
+		chatRate:  limitOrInf(cfg.ChatPerSecond),
+		groupRate: limitOrInf(cfg.GroupPerMinute / 60),
+		burst:     burst,
+		chats:     make(map[int64]*chatLimiter),
+	}
+}
+
+func limitOrInf(perSecond float64) rate.Limit {
+	if perSecond <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(perSecond)
+}
+
+// Wait blocks until the global bucket allows one more message. The global
+// limit is shared by every chat, so there is nothing better to do than wait.
+func (l *SendLimiter) Wait(ctx context.Context) error {
+	return l.global.Wait(ctx)
+}
+
+// ChatDelay takes a token from chatID's bucket and returns 0, or, when the
+// chat is paused or out of tokens, returns how long to wait without taking
+// one.
+func (l *SendLimiter) ChatDelay(chatID int64, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.chat(chatID, now)
+	if now.Before(c.pausedUntil) {
+		return c.pausedUntil.Sub(now)
+	}
+
+	r := c.limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return delay
+	}
+	return 0
+}
+
+// WaitChat blocks until chatID may get another message and takes a token.
+// It is for senders that have no queue to put the message back on.
+func (l *SendLimiter) WaitChat(ctx context.Context, chatID int64) error {
+	for {
+		delay := l.ChatDelay(chatID, time.Now())
+		if delay == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Pause holds every message to chatID until until.
+func (l *SendLimiter) Pause(chatID int64, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.chat(chatID, time.Now())
+	if until.After(c.pausedUntil) {
+		c.pausedUntil = until
+	}
+}
+
+// chat returns chatID's bucket, creating it on first use. Must be called
+// with mu held.
+func (l *SendLimiter) chat(chatID int64, now time.Time) *chatLimiter {
+	l.sweep(now)
+
+	c, ok := l.chats[chatID]
+	if !ok {
+		limit := l.chatRate
+		// Groups and channels have negative IDs and a stricter limit.
+		if chatID < 0 {
+			limit = l.groupRate
+		}
+		c = &chatLimiter{limiter: rate.NewLimiter(limit, l.burst)}
+		l.chats[chatID] = c
+	}
+	c.lastUsed = now
+	return c
+}
+
+// sweep drops buckets of chats that have been quiet long enough to be full
+// again. Must be called with mu held.
+func (l *SendLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < chatLimiterSweepGap {
+		return
+	}
+	l.lastSweep = now
+
+	for id, c := range l.chats {
+		if now.Sub(c.lastUsed) > chatLimiterIdle && now.After(c.pausedUntil) {
+			delete(l.chats, id)
+		}
+	}
+}
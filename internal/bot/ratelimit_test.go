@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"anek-bot/internal/config"
+	"anek-bot/internal/queue"
+
+	"gopkg.in/telebot.v4"
+)
+
+func TestSendLimiterChatDelay(t *testing.T) {
+	l := NewSendLimiter(config.RateLimitConfig{ChatPerSecond: 1, GroupPerMinute: 20, Burst: 2})
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if d := l.ChatDelay(1, now); d != 0 {
+			t.Fatalf("message %d within burst delayed by %v", i+1, d)
+		}
+	}
+
+	d := l.ChatDelay(1, now)
+	if d <= 0 || d > time.Second {
+		t.Errorf("ChatDelay() past burst = %v, want (0, 1s]", d)
+	}
+	// A denied message must not use up a token.
+	if d := l.ChatDelay(1, now.Add(time.Second)); d != 0 {
+		t.Errorf("ChatDelay() after refill = %v, want 0", d)
+	}
+
+	if d := l.ChatDelay(2, now); d != 0 {
+		t.Errorf("another chat delayed by %v", d)
+	}
+}
+
+func TestSendLimiterGroupRate(t *testing.T) {
+	l := NewSendLimiter(config.RateLimitConfig{ChatPerSecond: 1, GroupPerMinute: 20, Burst: 1})
+	now := time.Now()
+
+	l.ChatDelay(-100, now)
+	if d := l.ChatDelay(-100, now); d < 2*time.Second {
+		t.Errorf("group ChatDelay() = %v, want about 3s", d)
+	}
+}
+
+func TestSendLimiterPause(t *testing.T) {
+	l := NewSendLimiter(config.RateLimitConfig{ChatPerSecond: 1, Burst: 3})
+	now := time.Now()
+
+	l.Pause(1, now.Add(10*time.Second))
+	if d := l.ChatDelay(1, now); d < 9*time.Second {
+		t.Errorf("ChatDelay() while paused = %v, want about 10s", d)
+	}
+	// An earlier pause does not shorten a longer one.
+	l.Pause(1, now.Add(time.Second))
+	if d := l.ChatDelay(1, now); d < 9*time.Second {
+		t.Errorf("ChatDelay() after shorter pause = %v, want about 10s", d)
+	}
+}
+
+func TestSendLimiterUnlimited(t *testing.T) {
+	l := NewSendLimiter(config.RateLimitConfig{})
+	now := time.Now()
+
+	for i := 0; i < 100; i++ {
+		if d := l.ChatDelay(1, now); d != 0 {
+			t.Fatalf("unlimited ChatDelay() = %v", d)
+		}
+	}
+	if err := l.Wait(context.Background()); err != nil {
+		t.Errorf("unlimited Wait() = %v", err)
+	}
+}
+
+func TestHandleTelegramMessageThrottled(t *testing.T) {
+	b := &Bot{limiter: NewSendLimiter(config.RateLimitConfig{ChatPerSecond: 1, Burst: 1})}
+	b.limiter.ChatDelay(1, time.Now())
+
+	err := b.handleTelegramMessage(context.Background(), &queue.TelegramMessage{ChatID: 1, Text: "hi"})
+
+	var retry *queue.RetryError
+	if !errors.As(err, &retry) || retry.Delay <= 0 {
+		t.Fatalf("handleTelegramMessage() = %v, want a delayed retry", err)
+	}
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("handleTelegramMessage() = %v, want ErrRateLimited", err)
+	}
+}
+
+func TestFloodDelay(t *testing.T) {
+	b := &Bot{limiter: NewSendLimiter(config.RateLimitConfig{ChatPerSecond: 1, Burst: 3})}
+
+	if d := b.floodDelay(1, nil); d != 0 {
+		t.Errorf("floodDelay(nil) = %v", d)
+	}
+	if d := b.floodDelay(1, newSendError(1, telebot.ErrBlockedByUser)); d != 0 {
+		t.Errorf("floodDelay(blocked) = %v", d)
+	}
+
+	if d := b.floodDelay(1, newSendError(1, telebot.FloodError{RetryAfter: 12})); d != 12*time.Second {
+		t.Errorf("floodDelay() = %v, want 12s", d)
+	}
+	if d := b.limiter.ChatDelay(1, time.Now()); d < 11*time.Second {
+		t.Errorf("chat not paused after flood error, ChatDelay() = %v", d)
+	}
+	if d := b.limiter.ChatDelay(2, time.Now()); d != 0 {
+		t.Errorf("other chat paused by flood error, ChatDelay() = %v", d)
+	}
+}
@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"anek-bot/internal/models"
+	"anek-bot/pkg/logger"
+)
+
+const (
+	schedulerInterval = 30 * time.Second
+	// subscriptionRetryDelay is how long a failed daily delivery waits
+	// before it is attempted again.
+	subscriptionRetryDelay = 5 * time.Minute
+)
+
+// runScheduler delivers daily subscriptions until ctx is done. Due rows are
+// claimed in the database, so any number of bot replicas can run it.
+func (b *Bot) runScheduler(ctx context.Context) {
+	if b.subDB == nil {
+		return
+	}
+
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		b.deliverDueSubscriptions(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *Bot) deliverDueSubscriptions(ctx context.Context) {
+	sent := 0
+	for ctx.Err() == nil {
+		var deliverErr error
+		claimed, err := b.subDB.ClaimDue(ctx, time.Now(), func(sub *models.Subscription) error {
+			deliverErr = b.deliverSubscription(ctx, sub, time.Now())
+			return deliverErr
+		})
+		if err != nil && err != deliverErr {
+			logger.Error("Failed to process subscriptions", logger.Err(err))
+			return
+		}
+		if !claimed {
+			break
+		}
+		if err == nil {
+			sent++
+		}
+	}
+
+	if sent > 0 {
+		logger.Info("Daily jokes delivered", logger.Int("count", sent))
+	}
+}
+
+// deliverSubscription enqueues today's joke and moves the subscription to its
+// next run. A run missed while the bot was down is delivered once, late,
+// rather than skipped.
+func (b *Bot) deliverSubscription(ctx context.Context, sub *models.Subscription, now time.Time) error {
+	loc, err := parseTimezone(sub.Timezone)
+	if err != nil {
+		logger.Warn("Invalid subscription timezone, using default",
+			logger.Int64("chat_id", sub.TelegramID),
+			logger.String("timezone", sub.Timezone),
+		)
+		loc = b.defaultTZ
+	}
+
+	// The day is that of the run being delivered. NextRunAt moves on a
+	// failed attempt, so it is taken from the last scheduled time before it
+	// rather than from NextRunAt itself, keeping retries past midnight on
+	// the same day.
+	day := lastRun(sub.Hour, sub.Minute, loc, sub.NextRunAt).Format("2006-01-02")
+	sub.NextRunAt = nextRun(sub.Hour, sub.Minute, loc, now)
+
+	err = b.sendDailyJoke(ctx, sub.TelegramID, day)
+	if err != nil {
+		sub.NextRunAt = now.Add(subscriptionRetryDelay)
+		logger.Error("Failed to deliver daily joke",
+			logger.Err(err),
+			logger.Int64("chat_id", sub.TelegramID),
+		)
+	}
+	return err
+}
+
+func (b *Bot) sendDailyJoke(ctx context.Context, chatID int64, day string) error {
+	filter, _ := jokeFilter(b.loadChat(ctx, chatID), "")
+	joke, repeat, err := b.jokeFor(ctx, chatID, filter)
+	if err != nil {
+		return fmt.Errorf("failed to pick joke: %w", err)
+	}
+
+	msg, err := b.jokeMessage(chatID, "Joke of the day", joke, repeat)
+	if err != nil {
+		return err
+	}
+	// A replica that published but failed to commit the claim will publish
+	// again on retry; the queue drops the second copy by this ID.
+	msg.ID = fmt.Sprintf("daily:%d:%s", chatID, day)
+
+	if err := b.deliver(ctx, msg); err != nil {
+		return err
+	}
+	b.markSeen(ctx, chatID, joke.ID)
+	return nil
+}
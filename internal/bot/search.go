@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"context"
+	"strings"
+
+	"anek-bot/internal/models"
+	"anek-bot/pkg/logger"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	searchUnique   = "search"
+	searchPageSize = 5
+)
+
+func (b *Bot) handleSearch(c telebot.Context) error {
+	query := strings.Join(strings.Fields(c.Message().Payload), " ")
+	if query == "" {
+		return b.queueOrSend(c.Chat().ID, "Usage: /search <words>")
+	}
+
+	msg, err := b.searchPager.Message(context.Background(), c.Chat().ID, query)
+	if err != nil {
+		logger.Error("Failed to search jokes", logger.Err(err), logger.String("query", query))
+		return b.queueOrSend(c.Chat().ID, "Search failed, try again later")
+	}
+	return b.enqueue(msg)
+}
+
+func (b *Bot) renderSearchPage(ctx context.Context, query string, page int) (string, bool, error) {
+	jokes, hasMore, err := b.jokeDB.Search(ctx, query, searchPageSize, page*searchPageSize)
+	if err != nil {
+		return "", false, err
+	}
+	text, err := formatSearchPage(b.format, query, page, jokes)
+	return text, hasMore, err
+}
+
+func formatSearchPage(f *Formatter, query string, page int, jokes []models.Joke) (string, error) {
+	return f.Render("search", listPage{
+		Query: query,
+		Page:  displayPage(page),
+		Jokes: listItems(jokes, page*searchPageSize),
+	})
+}
@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"anek-bot/internal/models"
+)
+
+func TestFormatSearchPage(t *testing.T) {
+	f, err := NewFormatter("Markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jokes := []models.Joke{{ID: 3, Content: "Кот и пёс", Upvotes: 1}}
+
+	page := func(query string, page int, jokes []models.Joke) string {
+		t.Helper()
+		text, err := formatSearchPage(f, query, page, jokes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return text
+	}
+
+	if text := page("кот", 0, jokes); !strings.HasPrefix(text, "*Search:* кот\n\n1. Кот и пёс") {
+		t.Errorf("Unexpected search page: %q", text)
+	}
+	if text := page("*кот_", 0, jokes); !strings.HasPrefix(text, "*Search:* \\*кот\\_\n") {
+		t.Errorf("Expected query to be escaped, got %q", text)
+	}
+	if !strings.Contains(page("кот", 0, nil), "Nothing found") {
+		t.Error("Expected empty first page message")
+	}
+	if !strings.Contains(page("кот", 2, nil), "No more results") {
+		t.Error("Expected empty later page message")
+	}
+}
@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v4"
+)
+
+// SendErrorKind says why Telegram refused a message.
+type SendErrorKind string
+
+const (
+	SendErrorOther        SendErrorKind = "other"
+	SendErrorBlocked      SendErrorKind = "blocked"
+	SendErrorChatNotFound SendErrorKind = "chat_not_found"
+	SendErrorDeactivated  SendErrorKind = "deactivated"
+	SendErrorBadMarkup    SendErrorKind = "bad_markup"
+	SendErrorFloodWait    SendErrorKind = "flood_wait"
+	// SendErrorMigrated means the group was upgraded to a supergroup, which
+	// has a new chat ID.
+	SendErrorMigrated SendErrorKind = "migrated"
+)
+
+// SendError is a classified failure to send a message to ChatID.
+type SendError struct {
+	Kind   SendErrorKind
+	ChatID int64
+	// RetryAfter is how long Telegram asked to wait, for SendErrorFloodWait.
+	RetryAfter time.Duration
+	// MigratedTo is the supergroup's chat ID, for SendErrorMigrated.
+	MigratedTo int64
+	Err        error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("send to %d failed (%s): %v", e.ChatID, e.Kind, e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent reports whether sending the same message again cannot succeed.
+func (e *SendError) Permanent() bool {
+	switch e.Kind {
+	case SendErrorBlocked, SendErrorChatNotFound, SendErrorDeactivated, SendErrorBadMarkup:
+		return true
+	default:
+		return false
+	}
+}
+
+// Unreachable reports whether the chat itself is gone for the bot, as
+// opposed to a problem with this particular message.
+func (e *SendError) Unreachable() bool {
+	switch e.Kind {
+	case SendErrorBlocked, SendErrorChatNotFound, SendErrorDeactivated:
+		return true
+	default:
+		return false
+	}
+}
+
+// newSendError classifies an error returned by the Telegram API. It returns
+// nil for a nil err.
+func newSendError(chatID int64, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	sendErr := &SendError{Kind: sendErrorKind(err), ChatID: chatID, Err: err}
+	var (
+		flood    telebot.FloodError
+		migrated telebot.GroupError
+	)
+	if errors.As(err, &flood) {
+		sendErr.RetryAfter = time.Duration(flood.RetryAfter) * time.Second
+	}
+	if errors.As(err, &migrated) {
+		sendErr.MigratedTo = migrated.MigratedTo
+	}
+	return sendErr
+}
+
+func sendErrorKind(err error) SendErrorKind {
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		return sendErr.Kind
+	}
+
+	var (
+		flood    telebot.FloodError
+		migrated telebot.GroupError
+	)
+	switch {
+	case errors.As(err, &flood):
+		return SendErrorFloodWait
+	case errors.As(err, &migrated):
+		return SendErrorMigrated
+	case errors.Is(err, telebot.ErrBlockedByUser),
+		errors.Is(err, telebot.ErrNotStartedByUser),
+		errors.Is(err, telebot.ErrKickedFromGroup),
+		errors.Is(err, telebot.ErrKickedFromSuperGroup),
+		errors.Is(err, telebot.ErrKickedFromChannel),
+		errors.Is(err, telebot.ErrNotChannelMember),
+		errors.Is(err, telebot.ErrNoRightsToSend):
+		return SendErrorBlocked
+	case errors.Is(err, telebot.ErrUserIsDeactivated):
+		return SendErrorDeactivated
+	case errors.Is(err, telebot.ErrChatNotFound):
+		return SendErrorChatNotFound
+	}
+
+	// telebot has no sentinels for these, only the API description.
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "can't parse entities"),
+		strings.Contains(msg, "can't find end of the entity"):
+		return SendErrorBadMarkup
+	case strings.Contains(msg, "Too Many Requests"):
+		return SendErrorFloodWait
+	default:
+		return SendErrorOther
+	}
+}
+
+// isPermanentSendError reports whether err is a classified failure that
+// retrying cannot fix.
+func isPermanentSendError(err error) bool {
+	var sendErr *SendError
+	return errors.As(err, &sendErr) && sendErr.Permanent()
+}
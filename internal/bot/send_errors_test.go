@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"anek-bot/internal/queue"
+
+	"gopkg.in/telebot.v4"
+)
+
+func TestNewSendError(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantKind      SendErrorKind
+		wantPermanent bool
+	}{
+		{name: "blocked", err: telebot.ErrBlockedByUser, wantKind: SendErrorBlocked, wantPermanent: true},
+		{name: "never started", err: telebot.ErrNotStartedByUser, wantKind: SendErrorBlocked, wantPermanent: true},
+		{name: "kicked", err: telebot.ErrKickedFromSuperGroup, wantKind: SendErrorBlocked, wantPermanent: true},
+		{name: "deactivated", err: telebot.ErrUserIsDeactivated, wantKind: SendErrorDeactivated, wantPermanent: true},
+		{name: "chat not found", err: telebot.ErrChatNotFound, wantKind: SendErrorChatNotFound, wantPermanent: true},
+		{
+			name:          "bad markup",
+			err:           errors.New("telegram: Bad Request: can't parse entities: Can't find end of the entity starting at byte offset 12 (400)"),
+			wantKind:      SendErrorBadMarkup,
+			wantPermanent: true,
+		},
+		{name: "migrated", err: telebot.GroupError{MigratedTo: -1001}, wantKind: SendErrorMigrated},
+		{name: "flood", err: telebot.FloodError{RetryAfter: 7}, wantKind: SendErrorFloodWait},
+		{name: "flood without retry_after", err: errors.New("telegram: Too Many Requests (429)"), wantKind: SendErrorFloodWait},
+		{name: "network", err: errors.New("dial tcp: i/o timeout"), wantKind: SendErrorOther},
+		{name: "wrapped", err: fmt.Errorf("failed to send message: %w", telebot.ErrBlockedByUser), wantKind: SendErrorBlocked, wantPermanent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newSendError(42, tt.err)
+
+			var sendErr *SendError
+			if !errors.As(err, &sendErr) {
+				t.Fatalf("newSendError() = %T, want *SendError", err)
+			}
+			if sendErr.Kind != tt.wantKind {
+				t.Errorf("Kind = %q, want %q", sendErr.Kind, tt.wantKind)
+			}
+			if sendErr.ChatID != 42 {
+				t.Errorf("ChatID = %d, want 42", sendErr.ChatID)
+			}
+			if got := isPermanentSendError(fmt.Errorf("wrapped: %w", err)); got != tt.wantPermanent {
+				t.Errorf("isPermanentSendError() = %v, want %v", got, tt.wantPermanent)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("newSendError() does not wrap %v", tt.err)
+			}
+		})
+	}
+}
+
+func TestNewSendErrorRetryAfter(t *testing.T) {
+	err := newSendError(1, telebot.FloodError{RetryAfter: 7})
+
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) || sendErr.RetryAfter != 7*time.Second {
+		t.Errorf("newSendError() = %#v, want RetryAfter 7s", err)
+	}
+}
+
+func TestNewSendErrorMigratedTo(t *testing.T) {
+	err := newSendError(1, telebot.GroupError{MigratedTo: -1001})
+
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) || sendErr.MigratedTo != -1001 {
+		t.Errorf("newSendError() = %#v, want MigratedTo -1001", err)
+	}
+	if sendErr.Unreachable() {
+		t.Error("migrated group marks the chat unreachable")
+	}
+}
+
+func TestMovedMessage(t *testing.T) {
+	msg := &queue.TelegramMessage{ID: "daily:1:2026-10-17", ChatID: 1, Text: "joke"}
+
+	moved := movedMessage(msg, -1001)
+	if moved.ChatID != -1001 || moved.Text != "joke" {
+		t.Errorf("movedMessage() = %+v, want the same message for chat -1001", moved)
+	}
+	if moved.ID == msg.ID || moved.ID == "" {
+		t.Errorf("movedMessage() ID = %q, want a new ID", moved.ID)
+	}
+	if msg.ChatID != 1 {
+		t.Error("movedMessage() changed the original message")
+	}
+}
+
+func TestNewSendErrorNil(t *testing.T) {
+	if err := newSendError(1, nil); err != nil {
+		t.Errorf("newSendError(nil) = %v, want nil", err)
+	}
+}
+
+func TestSendErrorUnreachable(t *testing.T) {
+	if (&SendError{Kind: SendErrorBadMarkup}).Unreachable() {
+		t.Error("bad markup marks the chat unreachable")
+	}
+	if !(&SendError{Kind: SendErrorDeactivated}).Unreachable() {
+		t.Error("deactivated account is reachable")
+	}
+}
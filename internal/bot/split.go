@@ -0,0 +1,134 @@
+package bot
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"anek-bot/internal/queue"
+
+	"gopkg.in/telebot.v4"
+)
+
+// messageLimit is the most characters Telegram accepts in one message.
+const messageLimit = 4096
+
+// sentenceEnds are the runes that end a sentence when a space follows.
+const sentenceEnds = ".!?…"
+
+// splitLong fits msg into Telegram's message limit. Text keeps the first
+// part and More the rest, which are sent after it in order.
+func splitLong(msg *queue.TelegramMessage) {
+	parts := splitMessage(msg.Text, telebot.ParseMode(msg.ParseMode), messageLimit)
+	msg.Text = parts[0]
+	if len(parts) > 1 {
+		msg.More = parts[1:]
+	}
+}
+
+// splitMessage breaks text into parts of at most limit characters, counted
+// in UTF-16 code units the way Telegram counts them. A part ends at the
+// last paragraph break, line break, sentence end or space in its second
+// half, in that order of preference, and a word is only cut when there is
+// none. Cuts never fall inside a rune or inside an escape sequence of mode.
+//
+// Markup is counted as text, so parts stay within the limit after Telegram
+// strips it. Entities are not reopened in the next part, which is fine for
+// the short bold and code spans of the message templates.
+func splitMessage(text string, mode telebot.ParseMode, limit int) []string {
+	var parts []string
+	for {
+		end, fits := prefixWithin(text, limit)
+		if fits {
+			return append(parts, text)
+		}
+
+		cut := safeCut(text[:end], cutPoint(text[:end]), mode)
+		parts = append(parts, strings.TrimRightFunc(text[:cut], unicode.IsSpace))
+
+		text = strings.TrimLeftFunc(text[cut:], unicode.IsSpace)
+		if text == "" {
+			return parts
+		}
+	}
+}
+
+// prefixWithin returns the length in bytes of the longest prefix of s that
+// is at most limit characters long, and whether that is all of s.
+func prefixWithin(s string, limit int) (int, bool) {
+	n := 0
+	for i, r := range s {
+		size := utf16.RuneLen(r)
+		if size < 0 {
+			size = 1
+		}
+		if n+size > limit {
+			return i, false
+		}
+		n += size
+	}
+	return len(s), true
+}
+
+// cutPoint returns where to end a part that has to end within s.
+func cutPoint(s string) int {
+	half := len(s) / 2
+
+	for _, sep := range []string{"\n\n", "\n"} {
+		if i := strings.LastIndex(s, sep); i >= half {
+			return i + len(sep)
+		}
+	}
+	if i := lastSentenceEnd(s); i >= half {
+		return i
+	}
+	if i := strings.LastIndexAny(s, " \t"); i >= half {
+		return i + 1
+	}
+	return len(s)
+}
+
+// lastSentenceEnd returns the index just after the space that follows the
+// last sentence end in s, or -1.
+func lastSentenceEnd(s string) int {
+	for i := strings.LastIndexByte(s, ' '); i > 0; i = strings.LastIndexByte(s[:i], ' ') {
+		r, _ := utf8.DecodeLastRuneInString(s[:i])
+		if strings.ContainsRune(sentenceEnds, r) {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// safeCut moves cut back until it is not inside an escape sequence. Text
+// that cannot be cut anywhere safely is cut at cut anyway.
+func safeCut(s string, cut int, mode telebot.ParseMode) int {
+	for i := cut; i > 0; {
+		if canCut(s[:i], mode) {
+			return i
+		}
+		_, size := utf8.DecodeLastRuneInString(s[:i])
+		i -= size
+	}
+	return cut
+}
+
+// canCut reports whether a part may end after prefix.
+func canCut(prefix string, mode telebot.ParseMode) bool {
+	switch mode {
+	case telebot.ModeMarkdown, telebot.ModeMarkdownV2:
+		// An odd number of trailing backslashes escapes the next rune.
+		trailing := len(prefix) - len(strings.TrimRight(prefix, `\`))
+		return trailing%2 == 0
+	case telebot.ModeHTML:
+		if strings.LastIndexByte(prefix, '<') > strings.LastIndexByte(prefix, '>') {
+			return false
+		}
+		// Escaped text has no bare ampersands, so one after the last
+		// semicolon starts an entity.
+		return strings.LastIndexByte(prefix, '&') <= strings.LastIndexByte(prefix, ';')
+	default:
+		return true
+	}
+}
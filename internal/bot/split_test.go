@@ -0,0 +1,277 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"anek-bot/internal/config"
+	"anek-bot/internal/queue"
+
+	"gopkg.in/telebot.v4"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		mode  telebot.ParseMode
+		limit int
+		want  []string
+	}{
+		{"fits", "short joke", telebot.ModeDefault, 20, []string{"short joke"}},
+		{"empty", "", telebot.ModeDefault, 20, []string{""}},
+		{"paragraph", "First part here.\n\nSecond part", telebot.ModeDefault, 20, []string{"First part here.", "Second part"}},
+		{"line", "First line here\nsecond line", telebot.ModeDefault, 20, []string{"First line here", "second line"}},
+		{"sentence", "One sentence. Two words more", telebot.ModeDefault, 20, []string{"One sentence.", "Two words more"}},
+		{"word", "aaaa bbbb cccc dddd eeee", telebot.ModeDefault, 12, []string{"aaaa bbbb", "cccc dddd", "eeee"}},
+		{"hard cut", "abcdefghij", telebot.ModeDefault, 4, []string{"abcd", "efgh", "ij"}},
+		{"early paragraph ignored", "Hi.\n\nand then a long tail", telebot.ModeDefault, 16, []string{"Hi.\n\nand then a", "long tail"}},
+		{"cyrillic runes", "абвгдеёжзи", telebot.ModeDefault, 4, []string{"абвг", "деёж", "зи"}},
+		{"surrogate pairs", "😀😀😀", telebot.ModeDefault, 3, []string{"😀", "😀", "😀"}},
+		{"markdown escape", `abc\*def`, telebot.ModeMarkdownV2, 4, []string{"abc", `\*de`, "f"}},
+		{"escaped backslash", `ab\\cd`, telebot.ModeMarkdownV2, 4, []string{`ab\\`, "cd"}},
+		{"html entity", "ab&amp;cd", telebot.ModeHTML, 5, []string{"ab", "&amp;", "cd"}},
+		{"html tag", "ab<i>c</i>", telebot.ModeHTML, 4, []string{"ab", "<i>c", "</i>"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMessage(tt.text, tt.mode, tt.limit)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("splitMessage(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitMessageLimit(t *testing.T) {
+	paragraph := strings.Repeat("Мужик приходит к врачу и говорит: доктор, помогите! ", 20)
+	text := strings.TrimSpace(strings.Repeat(strings.TrimSpace(paragraph)+"\n\n", 10))
+
+	parts := splitMessage(text, telebot.ModeMarkdownV2, messageLimit)
+	if len(parts) < 2 {
+		t.Fatalf("got %d parts, want a split", len(parts))
+	}
+	for i, part := range parts {
+		if n := len(utf16.Encode([]rune(part))); n > messageLimit {
+			t.Errorf("part %d is %d characters long", i, n)
+		}
+		if !utf8.ValidString(part) {
+			t.Errorf("part %d is not valid UTF-8", i)
+		}
+		if strings.TrimSpace(part) != part {
+			t.Errorf("part %d has surrounding space", i)
+		}
+	}
+	if got := strings.Join(parts, "\n\n"); got != text {
+		t.Error("parts do not add up to the text")
+	}
+}
+
+func TestSplitLong(t *testing.T) {
+	msg := &queue.TelegramMessage{ChatID: 1, Text: "short"}
+	splitLong(msg)
+	if msg.Text != "short" || msg.More != nil {
+		t.Errorf("short message changed: %+v", msg)
+	}
+
+	msg = &queue.TelegramMessage{ChatID: 1, Text: strings.Repeat("word ", messageLimit)}
+	splitLong(msg)
+	if len(msg.More) == 0 {
+		t.Fatal("long message was not split")
+	}
+	if n := len([]rune(msg.Text)); n > messageLimit {
+		t.Errorf("first part is %d characters long", n)
+	}
+}
+
+// TestLongMessageThroughQueue sends a long message through the in-memory
+// queue to a fake Telegram API and checks that its parts arrive in order.
+func TestLongMessageThroughQueue(t *testing.T) {
+	type sent struct {
+		Text        string `json:"text"`
+		ReplyMarkup string `json:"reply_markup"`
+	}
+	sends := make(chan sent, 10)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var s sent
+		json.NewDecoder(r.Body).Decode(&s)
+		sends <- s
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1},"date":0}}`))
+	}))
+	defer api.Close()
+
+	b, err := New(config.BotConfig{Token: "test-token"}, Repositories{}, queue.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.tbot, err = telebot.NewBot(telebot.Settings{Token: "test-token", URL: api.URL, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.startTelegramConsumer(ctx)
+
+	paragraphs := []string{strings.Repeat("a", 3000), strings.Repeat("b", 3000), strings.Repeat("c", 3000)}
+	b.enqueue(&queue.TelegramMessage{
+		ChatID:  1,
+		Text:    strings.Join(paragraphs, "\n\n"),
+		Buttons: [][]queue.Button{{{Text: "👍", Unique: "vote", Data: "1"}}},
+	})
+
+	for i, want := range paragraphs {
+		select {
+		case s := <-sends:
+			if s.Text != want {
+				t.Errorf("part %d starts with %q, want %q", i, s.Text[:1], want[:1])
+			}
+			if last := i == len(paragraphs)-1; last != (s.ReplyMarkup != "") {
+				t.Errorf("part %d has buttons %q", i, s.ReplyMarkup)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("part %d was not sent", i)
+		}
+	}
+}
+
+func TestLongMessagePartsStayTogether(t *testing.T) {
+	texts := make(chan string, 10)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var s struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&s)
+		texts <- s.Text
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1},"date":0}}`))
+	}))
+	defer api.Close()
+
+	b, err := New(config.BotConfig{Token: "test-token"}, Repositories{}, queue.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.tbot, err = telebot.NewBot(telebot.Settings{Token: "test-token", URL: api.URL, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both messages are queued before the consumer starts, so the short
+	// one is waiting right behind the first part of the long one.
+	paragraphs := []string{strings.Repeat("a", 3000), strings.Repeat("b", 3000), strings.Repeat("c", 3000)}
+	b.enqueue(&queue.TelegramMessage{ChatID: 1, Text: strings.Join(paragraphs, "\n\n")})
+	b.enqueue(&queue.TelegramMessage{ChatID: 1, Text: "short"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.startTelegramConsumer(ctx)
+
+	for i, want := range append(paragraphs, "short") {
+		select {
+		case got := <-texts:
+			if got != want {
+				t.Errorf("message %d starts with %q, want %q", i, got[:1], want[:1])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %d was not sent", i)
+		}
+	}
+}
+
+func TestFailedPartRequeuesTheRest(t *testing.T) {
+	texts := make(chan string, 10)
+	failed := false
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var s struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&s)
+		if strings.HasPrefix(s.Text, "b") && !failed {
+			failed = true
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"ok":false,"error_code":500,"description":"Internal Server Error"}`))
+			return
+		}
+		texts <- s.Text
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1},"date":0}}`))
+	}))
+	defer api.Close()
+
+	b, err := New(config.BotConfig{Token: "test-token"}, Repositories{}, queue.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.tbot, err = telebot.NewBot(telebot.Settings{Token: "test-token", URL: api.URL, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.startTelegramConsumer(ctx)
+
+	paragraphs := []string{strings.Repeat("a", 3000), strings.Repeat("b", 3000), strings.Repeat("c", 3000)}
+	b.enqueue(&queue.TelegramMessage{ChatID: 1, Text: strings.Join(paragraphs, "\n\n")})
+
+	// The first part is not sent again after the second one fails.
+	for i, want := range paragraphs {
+		select {
+		case got := <-texts:
+			if got != want {
+				t.Errorf("message %d starts with %q, want %q", i, got[:1], want[:1])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %d was not sent", i)
+		}
+	}
+}
+
+func TestRateLimitedPartsDoNotHoldUpOtherChats(t *testing.T) {
+	texts := make(chan string, 10)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var s struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&s)
+		texts <- s.Text
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1},"date":0}}`))
+	}))
+	defer api.Close()
+
+	cfg := config.BotConfig{Token: "test-token", RateLimit: config.RateLimitConfig{GroupPerMinute: 60, Burst: 1}}
+	b, err := New(cfg, Repositories{}, queue.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.tbot, err = telebot.NewBot(telebot.Settings{Token: "test-token", URL: api.URL, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The group gets a message a second, so the second part of the long
+	// message has to wait; the message for another chat must not.
+	paragraphs := []string{strings.Repeat("a", 3000), strings.Repeat("b", 3000), strings.Repeat("c", 3000)}
+	b.enqueue(&queue.TelegramMessage{ChatID: -100, Text: strings.Join(paragraphs, "\n\n")})
+	b.enqueue(&queue.TelegramMessage{ChatID: 2, Text: "other"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.startTelegramConsumer(ctx)
+
+	for i, want := range []string{paragraphs[0], "other", paragraphs[1], paragraphs[2]} {
+		select {
+		case got := <-texts:
+			if got != want {
+				t.Errorf("message %d starts with %q, want %q", i, got[:1], want[:1])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %d was not sent", i)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"anek-bot/internal/database"
+	"anek-bot/internal/models"
+	"anek-bot/internal/queue"
+	"anek-bot/pkg/logger"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	submissionUnique = "submission"
+
+	submitMinRunes = 10
+	// Leaves room for the header of the moderation message.
+	submitMaxRunes        = 3500
+	maxPendingSubmissions = 5
+
+	reviewApprove = "approve"
+	reviewReject  = "reject"
+)
+
+var (
+	errSubmissionTooShort = errors.New("submission is too short")
+	errSubmissionTooLong  = errors.New("submission is too long")
+	errInvalidReviewData  = errors.New("invalid review data")
+)
+
+func (b *Bot) handleSubmit(c telebot.Context) error {
+	chatID := c.Chat().ID
+	sender := c.Sender()
+
+	ctx := context.Background()
+	if len(b.adminIDs(ctx)) == 0 {
+		return b.queueOrSend(chatID, "Submissions are not accepted right now.")
+	}
+
+	content, err := validateSubmission(commandPayload(c.Text()))
+	switch {
+	case errors.Is(err, errSubmissionTooShort):
+		return b.queueOrSend(chatID, "Usage: /submit <your joke>\n\nThe joke goes right after the command, line breaks are fine.")
+	case errors.Is(err, errSubmissionTooLong):
+		return b.queueOrSend(chatID, fmt.Sprintf("That joke is too long, please keep it under %d characters.", submitMaxRunes))
+	}
+
+	pending, err := b.submissionDB.CountPending(ctx, sender.ID)
+	if err != nil {
+		logger.Error("Failed to count submissions", logger.Err(err), logger.Int64("user_id", sender.ID))
+		return b.queueOrSend(chatID, "Failed to submit, try again later")
+	}
+	if pending >= maxPendingSubmissions {
+		return b.queueOrSend(chatID, "You already have several jokes waiting for review. Please wait for a decision first.")
+	}
+
+	sub := &models.Submission{
+		TelegramID: sender.ID,
+		Author:     displayName(sender),
+		Content:    content,
+	}
+	if err := b.submissionDB.Create(ctx, sub); err != nil {
+		logger.Error("Failed to save submission", logger.Err(err), logger.Int64("user_id", sender.ID))
+		return b.queueOrSend(chatID, "Failed to submit, try again later")
+	}
+
+	logger.Info("Joke submitted",
+		logger.Int64("submission_id", sub.ID),
+		logger.Int64("user_id", sender.ID),
+	)
+
+	review, err := b.message(0, "submission", sub)
+	if err != nil {
+		logger.Error("Failed to render submission", logger.Err(err), logger.Int64("submission_id", sub.ID))
+	} else {
+		review.Buttons = [][]queue.Button{{
+			{Text: "✅ Approve", Unique: submissionUnique, Data: reviewApprove + "|" + strconv.FormatInt(sub.ID, 10)},
+			{Text: "❌ Reject", Unique: submissionUnique, Data: reviewReject + "|" + strconv.FormatInt(sub.ID, 10)},
+		}}
+		b.notifyAdmins(ctx, *review)
+	}
+
+	return b.queueOrSend(chatID, fmt.Sprintf("Thanks! Your joke was sent for review as #%d. I will let you know the decision.", sub.ID))
+}
+
+// handleSubmissionReview is registered in setupAdminHandlers.
+func (b *Bot) handleSubmissionReview(c telebot.Context) error {
+	reviewer := c.Sender()
+
+	action, id, err := parseReviewData(c.Callback().Data)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Invalid review"})
+	}
+
+	ctx := context.Background()
+	var (
+		sub     *models.Submission
+		outcome string
+	)
+	if action == reviewApprove {
+		sub, outcome, err = b.approveSubmission(ctx, id, reviewer.ID)
+	} else {
+		sub, err = b.submissionDB.Review(ctx, id, models.SubmissionRejected, reviewer.ID)
+		outcome = "Rejected"
+	}
+
+	if errors.Is(err, database.ErrAlreadyReviewed) {
+		b.clearReviewButtons(c, "Already reviewed")
+		return c.Respond(&telebot.CallbackResponse{Text: "Already reviewed"})
+	}
+	if err != nil {
+		logger.Error("Failed to review submission",
+			logger.Err(err),
+			logger.Int64("submission_id", id),
+			logger.String("action", action),
+		)
+		return c.Respond(&telebot.CallbackResponse{Text: "Failed to review, try again later"})
+	}
+
+	logger.Info("Submission reviewed",
+		logger.Int64("submission_id", sub.ID),
+		logger.String("status", string(sub.Status)),
+		logger.Int64("reviewer_id", reviewer.ID),
+	)
+
+	b.clearReviewButtons(c, fmt.Sprintf("%s by %s", outcome, displayName(reviewer)))
+	b.notifySubmitter(sub)
+
+	return c.Respond(&telebot.CallbackResponse{Text: outcome})
+}
+
+// approveSubmission claims the submission and copies it into jokes. A joke
+// that turns out to exist already rejects the submission instead.
+func (b *Bot) approveSubmission(ctx context.Context, id, reviewerID int64) (*models.Submission, string, error) {
+	sub, err := b.submissionDB.Review(ctx, id, models.SubmissionApproved, reviewerID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	joke := &models.Joke{
+		Content: sub.Content,
+		Source:  string(models.SourceUser),
+		Author:  sub.Author,
+	}
+	err = b.jokeDB.Create(ctx, joke)
+
+	var dupErr *database.DuplicateError
+	if errors.As(err, &dupErr) || (err == nil && joke.ID == 0) {
+		if err := b.submissionDB.Reopen(ctx, id); err != nil {
+			return nil, "", err
+		}
+		sub, err = b.submissionDB.Review(ctx, id, models.SubmissionRejected, reviewerID)
+		return sub, "Rejected as a duplicate", err
+	}
+	if err != nil {
+		if reopenErr := b.submissionDB.Reopen(ctx, id); reopenErr != nil {
+			logger.Error("Failed to reopen submission", logger.Err(reopenErr), logger.Int64("submission_id", id))
+		}
+		return nil, "", fmt.Errorf("failed to create joke: %w", err)
+	}
+
+	if err := b.submissionDB.SetJoke(ctx, id, joke.ID); err != nil {
+		logger.Error("Failed to link submission to joke",
+			logger.Err(err),
+			logger.Int64("submission_id", id),
+			logger.Int64("joke_id", joke.ID),
+		)
+	}
+	sub.JokeID = &joke.ID
+
+	return sub, fmt.Sprintf("Approved as joke #%d", joke.ID), nil
+}
+
+// clearReviewButtons replaces the moderation buttons with a note about the
+// outcome. The text is sent without parse mode, as it contains user input.
+func (b *Bot) clearReviewButtons(c telebot.Context, note string) {
+	msg := c.Message()
+	if msg == nil {
+		return
+	}
+
+	_, err := c.Bot().Edit(msg, msg.Text+"\n\n— "+note)
+	if err != nil && !isNotModified(err) {
+		logger.Warn("Failed to update review message", logger.Err(err))
+	}
+}
+
+func (b *Bot) notifySubmitter(sub *models.Submission) {
+	text := fmt.Sprintf("Your joke #%d was not accepted this time. Thanks for trying!", sub.ID)
+	if sub.Status == models.SubmissionApproved {
+		text = fmt.Sprintf("Your joke #%d was approved and is now part of the collection. Thanks!", sub.ID)
+	}
+
+	if err := b.queueOrSend(sub.TelegramID, text); err != nil {
+		logger.Warn("Failed to notify submitter", logger.Err(err), logger.Int64("user_id", sub.TelegramID))
+	}
+}
+
+func parseReviewData(data string) (string, int64, error) {
+	action, idStr, ok := strings.Cut(data, "|")
+	if !ok || (action != reviewApprove && action != reviewReject) {
+		return "", 0, errInvalidReviewData
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		return "", 0, errInvalidReviewData
+	}
+	return action, id, nil
+}
+
+// commandPayload returns everything after the command, keeping line breaks
+// that telebot's single-line Payload drops.
+func commandPayload(text string) string {
+	i := strings.IndexFunc(text, unicode.IsSpace)
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSpace(text[i:])
+}
+
+func validateSubmission(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	n := utf8.RuneCountInString(content)
+	if n < submitMinRunes {
+		return "", errSubmissionTooShort
+	}
+	if n > submitMaxRunes {
+		return "", errSubmissionTooLong
+	}
+	return content, nil
+}
+
+func displayName(u *telebot.User) string {
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if name == "" {
+		return "user " + strconv.FormatInt(u.ID, 10)
+	}
+	return name
+}
@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/telebot.v4"
+)
+
+func TestCommandPayload(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "/submit short joke", want: "short joke"},
+		{in: "/submit\nline one\nline two", want: "line one\nline two"},
+		{in: "/submit@AnekBot   joke  ", want: "joke"},
+		{in: "/submit", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := commandPayload(tt.in); got != tt.want {
+			t.Errorf("commandPayload(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSubmission(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr error
+	}{
+		{name: "ok", in: "  Колобок повесился.  ", want: "Колобок повесился."},
+		{name: "too short", in: "ха", wantErr: errSubmissionTooShort},
+		{name: "only spaces", in: strings.Repeat(" ", 50), wantErr: errSubmissionTooShort},
+		{name: "too long", in: strings.Repeat("я", submitMaxRunes+1), wantErr: errSubmissionTooLong},
+		{name: "limit counts runes", in: strings.Repeat("я", submitMaxRunes), want: strings.Repeat("я", submitMaxRunes)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateSubmission(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("validateSubmission() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("validateSubmission() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReviewData(t *testing.T) {
+	tests := []struct {
+		data       string
+		wantAction string
+		wantID     int64
+		wantErr    bool
+	}{
+		{data: "approve|12", wantAction: reviewApprove, wantID: 12},
+		{data: "reject|7", wantAction: reviewReject, wantID: 7},
+		{data: "delete|7", wantErr: true},
+		{data: "approve|", wantErr: true},
+		{data: "approve|-1", wantErr: true},
+		{data: "approve", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		action, id, err := parseReviewData(tt.data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseReviewData(%q) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+			continue
+		}
+		if action != tt.wantAction || id != tt.wantID {
+			t.Errorf("parseReviewData(%q) = %q, %d, want %q, %d", tt.data, action, id, tt.wantAction, tt.wantID)
+		}
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		user *telebot.User
+		want string
+	}{
+		{user: &telebot.User{ID: 1, Username: "vasya", FirstName: "Vasya"}, want: "@vasya"},
+		{user: &telebot.User{ID: 2, FirstName: "Вася", LastName: "Пупкин"}, want: "Вася Пупкин"},
+		{user: &telebot.User{ID: 3, FirstName: "Вася"}, want: "Вася"},
+		{user: &telebot.User{ID: 4}, want: "user 4"},
+	}
+
+	for _, tt := range tests {
+		if got := displayName(tt.user); got != tt.want {
+			t.Errorf("displayName(%+v) = %q, want %q", tt.user, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"anek-bot/internal/database"
+	"anek-bot/internal/models"
+	"anek-bot/pkg/logger"
+
+	"gopkg.in/telebot.v4"
+)
+
+const subscribeUsage = "Usage: /subscribe HH:MM [timezone]\n" +
+	"Examples: /subscribe 09:30, /subscribe 21:00 Europe/Berlin, /subscribe 8:15 +5"
+
+var (
+	errInvalidTime     = errors.New("invalid time")
+	errInvalidTimezone = errors.New("invalid timezone")
+
+	clockPattern  = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+	offsetPattern = regexp.MustCompile(`(?i)^(?:utc|gmt)?([+-])(\d{1,2})(?::?(\d{2}))?$`)
+)
+
+// handleSubscribe subscribes the current chat, so in groups it sets up the
+// group's auto joke of the day and needs an admin.
+func (b *Bot) handleSubscribe(c telebot.Context) error {
+	ctx := context.Background()
+	chatID := c.Chat().ID
+	args := c.Args()
+
+	if len(args) == 0 {
+		sub, err := b.subDB.Get(ctx, chatID)
+		if errors.Is(err, database.ErrSubscriptionNotFound) {
+			return b.queueOrSend(chatID, subscribeUsage)
+		}
+		if err != nil {
+			logger.Error("Failed to load subscription", logger.Err(err), logger.Int64("chat_id", chatID))
+			return b.queueOrSend(chatID, "Failed to load the subscription, try again later")
+		}
+		return b.queueOrSend(chatID, fmt.Sprintf(
+			"A joke arrives here every day at %02d:%02d (%s).\n\n%s\nUse /unsubscribe to stop.",
+			sub.Hour, sub.Minute, sub.Timezone, subscribeUsage,
+		))
+	}
+
+	if !b.requireSettingsAccess(c) {
+		return nil
+	}
+
+	hour, minute, loc, err := parseSubscribeArgs(args, b.defaultTZ)
+	if err != nil {
+		return b.queueOrSend(chatID, fmt.Sprintf("Could not parse that: %v.\n\n%s", err, subscribeUsage))
+	}
+
+	sub := &models.Subscription{
+		TelegramID: chatID,
+		Hour:       hour,
+		Minute:     minute,
+		Timezone:   loc.String(),
+		NextRunAt:  nextRun(hour, minute, loc, time.Now()),
+	}
+	if err := b.subDB.Upsert(ctx, sub); err != nil {
+		logger.Error("Failed to save subscription", logger.Err(err), logger.Int64("chat_id", chatID))
+		return b.queueOrSend(chatID, "Failed to save the subscription, try again later")
+	}
+
+	logger.Info("Chat subscribed",
+		logger.Int64("chat_id", chatID),
+		logger.String("timezone", sub.Timezone),
+		logger.Int("hour", hour),
+		logger.Int("minute", minute),
+	)
+
+	return b.queueOrSend(chatID, fmt.Sprintf(
+		"Subscribed! A joke will arrive here every day at %02d:%02d (%s).\nThe first one arrives %s.",
+		hour, minute, sub.Timezone, sub.NextRunAt.In(loc).Format("Jan 2 at 15:04"),
+	))
+}
+
+func (b *Bot) handleUnsubscribe(c telebot.Context) error {
+	chatID := c.Chat().ID
+	if !b.requireSettingsAccess(c) {
+		return nil
+	}
+
+	err := b.subDB.Delete(context.Background(), chatID)
+	if errors.Is(err, database.ErrSubscriptionNotFound) {
+		return b.queueOrSend(chatID, "Not subscribed. Use /subscribe HH:MM to get a daily joke.")
+	}
+	if err != nil {
+		logger.Error("Failed to delete subscription", logger.Err(err), logger.Int64("chat_id", chatID))
+		return b.queueOrSend(chatID, "Failed to unsubscribe, try again later")
+	}
+
+	logger.Info("Chat unsubscribed", logger.Int64("chat_id", chatID))
+	return b.queueOrSend(chatID, "Unsubscribed. No more daily jokes.")
+}
+
+func parseSubscribeArgs(args []string, defaultTZ *time.Location) (hour, minute int, loc *time.Location, err error) {
+	if len(args) == 0 || len(args) > 2 {
+		return 0, 0, nil, errInvalidTime
+	}
+
+	hour, minute, err = parseClock(args[0])
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	loc = defaultTZ
+	if len(args) == 2 {
+		if loc, err = parseTimezone(args[1]); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+	return hour, minute, loc, nil
+}
+
+// parseClock parses an "HH:MM" wall-clock time.
+func parseClock(s string) (hour, minute int, err error) {
+	m := clockPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, errInvalidTime
+	}
+	hour, _ = strconv.Atoi(m[1])
+	minute, _ = strconv.Atoi(m[2])
+	if hour > 23 || minute > 59 {
+		return 0, 0, errInvalidTime
+	}
+	return hour, minute, nil
+}
+
+// parseTimezone accepts IANA names such as "Europe/Moscow" and UTC offsets
+// such as "+3", "-05:30" or "UTC+03:00". Offsets are normalized to the
+// "UTC+03:00" form, which parses back to the same zone.
+func parseTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+
+	if m := offsetPattern.FindStringSubmatch(name); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes := 0
+		if m[3] != "" {
+			minutes, _ = strconv.Atoi(m[3])
+		}
+		if hours > 14 || minutes > 59 {
+			return nil, errInvalidTimezone
+		}
+
+		offset := hours*3600 + minutes*60
+		if m[1] == "-" {
+			offset = -offset
+		}
+		if offset == 0 {
+			return time.UTC, nil
+		}
+		return time.FixedZone(fmt.Sprintf("UTC%s%02d:%02d", m[1], hours, minutes), offset), nil
+	}
+
+	if name == "" || strings.EqualFold(name, "local") {
+		return nil, errInvalidTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errInvalidTimezone
+	}
+	return loc, nil
+}
+
+// lastRun is the latest daily run at hour:minute in loc at or before at,
+// in loc.
+func lastRun(hour, minute int, loc *time.Location, at time.Time) time.Time {
+	local := at.In(loc)
+	last := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, loc)
+	if last.After(at) {
+		last = time.Date(local.Year(), local.Month(), local.Day()-1, hour, minute, 0, 0, loc)
+	}
+	return last
+}
+
+// nextRun returns the first hour:minute wall-clock time in loc strictly after
+// the given instant. Times skipped by a DST change are normalized by
+// time.Date.
+func nextRun(hour, minute int, loc *time.Location, after time.Time) time.Time {
+	local := after.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, loc)
+	if !next.After(after) {
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, hour, minute, 0, 0, loc)
+	}
+	return next
+}
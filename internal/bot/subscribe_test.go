@@ -0,0 +1,201 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%q) error = %v", name, err)
+	}
+	return loc
+}
+
+func TestParseSubscribeArgs(t *testing.T) {
+	moscow := mustLocation(t, "Europe/Moscow")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantHour   int
+		wantMinute int
+		wantTZ     string
+		wantErr    error
+	}{
+		{name: "default zone", args: []string{"09:30"}, wantHour: 9, wantMinute: 30, wantTZ: "Europe/Moscow"},
+		{name: "single digit hour", args: []string{"8:05"}, wantHour: 8, wantMinute: 5, wantTZ: "Europe/Moscow"},
+		{name: "iana zone", args: []string{"21:00", "America/New_York"}, wantHour: 21, wantTZ: "America/New_York"},
+		{name: "offset zone", args: []string{"07:15", "+5"}, wantHour: 7, wantMinute: 15, wantTZ: "UTC+05:00"},
+		{name: "midnight", args: []string{"00:00"}, wantTZ: "Europe/Moscow"},
+		{name: "no args", args: nil, wantErr: errInvalidTime},
+		{name: "hour out of range", args: []string{"24:00"}, wantErr: errInvalidTime},
+		{name: "minute out of range", args: []string{"12:60"}, wantErr: errInvalidTime},
+		{name: "missing minutes", args: []string{"12"}, wantErr: errInvalidTime},
+		{name: "too many args", args: []string{"12:00", "UTC", "extra"}, wantErr: errInvalidTime},
+		{name: "unknown zone", args: []string{"12:00", "Mars/Olympus"}, wantErr: errInvalidTimezone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hour, minute, loc, err := parseSubscribeArgs(tt.args, moscow)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("parseSubscribeArgs(%q) error = %v, want %v", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSubscribeArgs(%q) error = %v", tt.args, err)
+			}
+			if hour != tt.wantHour || minute != tt.wantMinute {
+				t.Errorf("time = %02d:%02d, want %02d:%02d", hour, minute, tt.wantHour, tt.wantMinute)
+			}
+			if loc.String() != tt.wantTZ {
+				t.Errorf("timezone = %q, want %q", loc.String(), tt.wantTZ)
+			}
+		})
+	}
+}
+
+func TestParseTimezone(t *testing.T) {
+	tests := []struct {
+		in         string
+		wantName   string
+		wantOffset int
+		wantErr    bool
+	}{
+		{in: "Europe/Moscow", wantName: "Europe/Moscow", wantOffset: 3 * 3600},
+		{in: "UTC", wantName: "UTC"},
+		{in: "+3", wantName: "UTC+03:00", wantOffset: 3 * 3600},
+		{in: "-05:30", wantName: "UTC-05:30", wantOffset: -(5*3600 + 30*60)},
+		{in: "gmt+0545", wantName: "UTC+05:45", wantOffset: 5*3600 + 45*60},
+		{in: "UTC+03:00", wantName: "UTC+03:00", wantOffset: 3 * 3600},
+		{in: "+0", wantName: "UTC"},
+		{in: "+15", wantErr: true},
+		{in: "Local", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "../../etc/passwd", wantErr: true},
+	}
+
+	// A winter instant, so that DST does not shift the expected offsets.
+	at := time.Date(2026, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			loc, err := parseTimezone(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseTimezone(%q) = %v, want error", tt.in, loc)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTimezone(%q) error = %v", tt.in, err)
+			}
+			if loc.String() != tt.wantName {
+				t.Errorf("name = %q, want %q", loc.String(), tt.wantName)
+			}
+			if _, offset := at.In(loc).Zone(); offset != tt.wantOffset {
+				t.Errorf("offset = %d, want %d", offset, tt.wantOffset)
+			}
+
+			again, err := parseTimezone(loc.String())
+			if err != nil || again.String() != loc.String() {
+				t.Errorf("parseTimezone(%q) does not round-trip: %v, %v", loc.String(), again, err)
+			}
+		})
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	moscow := mustLocation(t, "Europe/Moscow")
+	berlin := mustLocation(t, "Europe/Berlin")
+
+	tests := []struct {
+		name   string
+		hour   int
+		minute int
+		loc    *time.Location
+		after  time.Time
+		want   time.Time
+	}{
+		{
+			name: "later today",
+			hour: 9, minute: 30, loc: moscow,
+			after: time.Date(2026, 5, 10, 8, 0, 0, 0, moscow),
+			want:  time.Date(2026, 5, 10, 9, 30, 0, 0, moscow),
+		},
+		{
+			name: "already passed today",
+			hour: 9, minute: 30, loc: moscow,
+			after: time.Date(2026, 5, 10, 10, 0, 0, 0, moscow),
+			want:  time.Date(2026, 5, 11, 9, 30, 0, 0, moscow),
+		},
+		{
+			name: "exactly now moves to tomorrow",
+			hour: 9, minute: 30, loc: moscow,
+			after: time.Date(2026, 5, 10, 9, 30, 0, 0, moscow),
+			want:  time.Date(2026, 5, 11, 9, 30, 0, 0, moscow),
+		},
+		{
+			name: "local date differs from utc date",
+			hour: 1, minute: 0, loc: moscow,
+			after: time.Date(2026, 5, 10, 23, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 5, 12, 1, 0, 0, 0, moscow),
+		},
+		{
+			name: "month rollover",
+			hour: 8, minute: 0, loc: berlin,
+			after: time.Date(2026, 1, 31, 9, 0, 0, 0, berlin),
+			want:  time.Date(2026, 2, 1, 8, 0, 0, 0, berlin),
+		},
+		{
+			name: "dst gap",
+			hour: 2, minute: 30, loc: berlin,
+			after: time.Date(2026, 3, 28, 12, 0, 0, 0, berlin),
+			want:  time.Date(2026, 3, 29, 3, 30, 0, 0, berlin),
+		},
+		{
+			name: "day after dst change keeps wall clock",
+			hour: 8, minute: 0, loc: berlin,
+			after: time.Date(2026, 3, 29, 9, 0, 0, 0, berlin),
+			want:  time.Date(2026, 3, 30, 8, 0, 0, 0, berlin),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextRun(tt.hour, tt.minute, tt.loc, tt.after)
+			if !got.Equal(tt.want) {
+				t.Errorf("nextRun() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastRun(t *testing.T) {
+	moscow := mustLocation(t, "Europe/Moscow")
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want time.Time
+	}{
+		{"the run itself", time.Date(2026, 5, 10, 23, 55, 0, 0, moscow), time.Date(2026, 5, 10, 23, 55, 0, 0, moscow)},
+		{"retry past midnight", time.Date(2026, 5, 11, 0, 5, 0, 0, moscow), time.Date(2026, 5, 10, 23, 55, 0, 0, moscow)},
+		{"retry given in utc", time.Date(2026, 5, 10, 21, 5, 0, 0, time.UTC), time.Date(2026, 5, 10, 23, 55, 0, 0, moscow)},
+		{"a day of retries", time.Date(2026, 5, 12, 0, 0, 0, 0, moscow), time.Date(2026, 5, 11, 23, 55, 0, 0, moscow)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastRun(23, 55, moscow, tt.at); !got.Equal(tt.want) {
+				t.Errorf("lastRun() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package bot
+
+// messageTemplates are the formatted messages the bot sends. They are
+// written as plain text: the Formatter escapes the literal text and every
+// printed value for the configured parse mode, and bold, italic and code
+// add markup.
+const messageTemplates = `
+{{- define "welcome" -}}
+{{bold "Welcome to Anek Bot!"}}
+
+I'll send you random jokes from Reddit and anekdot.ru.
+
+Commands:
+- /joke - Get a random joke
+- /joke reddit - Get a joke from Reddit
+- /joke anekdot - Get a joke from anekdot.ru
+- /top [day|week|all] [source] - Best rated jokes
+- /search <words> - Find jokes
+- /submit <joke> - Share your own joke
+- /subscribe HH:MM [timezone] - Get a joke every day
+- /stats - Bot statistics
+- /help - Show this help message
+{{- end}}
+
+{{- define "help" -}}
+{{bold "Help"}}
+
+Commands:
+- /start - Start the bot
+- /joke - Get a random joke
+- /joke reddit - Get a joke from Reddit
+- /joke anekdot - Get a joke from anekdot.ru
+- /top [day|week|all] [source] - Show best rated jokes
+- /search <words> - Search jokes by words
+- /subscribe HH:MM [timezone] - Daily joke at your local time
+- /unsubscribe - Stop the daily joke
+- /settings - Sources, NSFW and daily joke for this chat
+- /submit <joke> - Propose your own joke
+- /stats - Show bot statistics
+- /help - Show this help message
+
+In any chat, type my username followed by a few words to share a joke.
+{{- end}}
+
+{{- define "group_welcome" -}}
+{{bold "Hi everyone!"}}
+
+Send /joke for a joke, or mention me or reply to my message.
+Admins can tune what I post with /settings.
+{{- end}}
+
+{{- define "stats" -}}
+{{bold "Bot Statistics"}}
+
+Total jokes: {{.TotalJokes}}
+Reddit jokes: {{.RedditJokes}}
+Anekdot jokes: {{.AnekdotJokes}}
+Total users: {{.TotalUsers}}
+{{- end}}
+
+{{- define "joke" -}}
+{{if .Repeat}}{{italic "You have seen all the jokes here, so this one is a repeat."}}
+
+{{end}}
+{{- if .Title}}{{bold .Title}}
+
+{{end}}
+{{- .Content}}
+
+[{{.Label}}]
+{{- end}}
+
+{{- define "joke_list" -}}
+{{range $i, $joke := .}}{{if $i}}
+
+{{end}}{{$joke.Number}}. {{$joke.Snippet}}
+👍 {{$joke.Upvotes}}  👎 {{$joke.Downvotes}}  · #{{$joke.ID}}
+{{- end}}
+{{- end}}
+
+{{- define "top" -}}
+{{bold .Title}}{{if .Page}} (page {{.Page}}){{end}}
+
+{{if .Jokes}}{{template "joke_list" .Jokes}}{{else}}No rated jokes yet. Vote with 👍/👎 under /joke!{{end}}
+{{- end}}
+
+{{- define "search" -}}
+{{bold "Search:"}} {{.Query}}{{if .Page}} (page {{.Page}}){{end}}
+
+{{if .Jokes}}{{template "joke_list" .Jokes}}
+{{- else if .Page}}No more results.
+{{- else}}Nothing found. Try other words.{{end}}
+{{- end}}
+
+{{- define "settings" -}}
+{{if .Saved}}Saved.
+
+{{end -}}
+{{bold "Settings"}}
+
+Sources: {{.Sources}}
+NSFW: {{.NSFW}}
+Daily joke: {{.Daily}}{{with .Timezone}} {{code .}}{{end}}
+{{- with .Usage}}
+
+{{.}}{{end}}
+{{- end}}
+
+{{- define "submission" -}}
+{{bold (printf "New submission #%d" .ID)}} from {{.Author}}
+
+{{.Content}}
+{{- end}}
+
+{{- define "admin_help" -}}
+{{bold "Admin commands"}}
+
+- /addjoke <text> - Add a joke
+- /deljoke <id> - Delete a joke
+- /joke_info <id> - Show everything about a joke
+- /ban <id|@username> [reason] - Ignore a user, or reply to their message
+- /unban <id|@username> - Lift a ban
+- /reparse [source] - Run the parser now
+- /queue_status - Show the message queue backlog
+- /deadletters - Show messages that could not be processed
+- /deadletter_replay <seq|all> - Queue dead letters again
+- /deadletter_purge <seq|all> - Drop dead letters
+- /broadcast <text> - Send a message to every user
+- /broadcast_cancel <id> - Stop a running broadcast
+- /promote, /demote <id|@username> - Manage admins (owners only)
+{{- end}}
+
+{{- define "joke_info" -}}
+{{bold (printf "Joke #%d" .ID)}}
+
+{{.Content}}
+
+Source: {{.Source}}
+{{with .SourceURL}}URL: {{.}}
+{{end}}
+{{- with .Author}}Author: {{.}}
+{{end -}}
+Added: {{.CreatedAt.Format "2006-01-02 15:04"}}
+Sent: {{.UsedCount}} times
+Votes: +{{.Upvotes}} / -{{.Downvotes}} (rating {{.Rating}})
+NSFW: {{.NSFW}}
+{{with .DuplicateOf}}Duplicate of: #{{.}}
+{{end -}}
+Hash: {{code .Hash}}
+{{- end}}
+
+{{- define "reparse" -}}
+{{bold "Reparse finished"}}
+{{range .}}
+{{code .Source}}: fetched {{.Fetched}}, queued {{.Published}}{{with .Skipped}}, {{.}} seen before{{end}}{{if .Err}} (with errors){{end}}
+{{- end}}
+{{- end}}
+
+{{- define "dead_letters" -}}
+{{bold "Dead letters"}}: {{.Total}}{{if gt .Total (len .Letters)}}, the oldest {{len .Letters}} below{{end}}
+{{range .Letters}}
+{{code (printf "#%d" .Seq)}} {{.Subject}}, {{.Deliveries}} deliveries, {{.FailedAt.Format "2006-01-02 15:04"}}
+Error: {{.Error}}
+{{.Snippet}}
+{{else}}
+Nothing failed.
+{{- end}}
+{{- end}}
+
+{{- define "queue_status" -}}
+{{bold "Queue status"}}
+
+Stream {{code .Stream}}: {{.Messages}} messages, {{.KB}} KB
+{{range .Consumers}}
+{{code .Name}}: {{.Pending}} pending, {{.AckPending}} in flight, {{.Redelivered}} redelivered
+{{- else}}
+No consumers yet.
+{{- end}}
+{{with .Duplicates}}
+{{bold "Duplicate jokes dropped"}}
+{{range .}}
+{{.Layer}}: {{.Count}}
+{{- end}}
+{{- end}}
+{{- end}}
+`
@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"anek-bot/internal/database"
+	"anek-bot/internal/models"
+	"anek-bot/pkg/logger"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	topUnique        = "top"
+	topPageSize      = 5
+	listSnippetRunes = 500
+)
+
+type topPeriod string
+
+const (
+	periodDay  topPeriod = "day"
+	periodWeek topPeriod = "week"
+	periodAll  topPeriod = "all"
+)
+
+func (p topPeriod) since(now time.Time) time.Time {
+	switch p {
+	case periodDay:
+		return now.Add(-24 * time.Hour)
+	case periodWeek:
+		return now.Add(-7 * 24 * time.Hour)
+	default:
+		return time.Time{}
+	}
+}
+
+// parseTopArgs accepts "[day|week|all] [source]" in either order and returns
+// the canonical "period source" form used as paginator state.
+func parseTopArgs(args []string) (topPeriod, models.JokeSource, bool) {
+	period := periodAll
+	var rest []string
+	for _, arg := range args {
+		switch p := topPeriod(strings.ToLower(arg)); p {
+		case periodDay, periodWeek, periodAll:
+			period = p
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	if len(rest) > 1 {
+		return "", "", false
+	}
+	source, ok := parseSourceArg(rest)
+	if !ok {
+		return "", "", false
+	}
+	return period, source, true
+}
+
+func (b *Bot) handleTop(c telebot.Context) error {
+	ctx := context.Background()
+	period, source, ok := parseTopArgs(c.Args())
+	if !ok {
+		return b.queueOrSend(c.Chat().ID, "Usage: /top [day|week|all] [source], such as /top week reddit")
+	}
+	found, err := b.hasJokesFrom(ctx, source)
+	if err != nil {
+		logger.Error("Failed to check source", logger.Err(err), logger.String("source", string(source)))
+		return b.queueOrSend(c.Chat().ID, "Failed to get top jokes, try again later")
+	}
+	if !found {
+		return b.queueOrSend(c.Chat().ID, fmt.Sprintf("There are no jokes from %q.", source))
+	}
+
+	msg, err := b.topPager.Message(ctx, c.Chat().ID, strings.TrimSpace(string(period)+" "+string(source)))
+	if err != nil {
+		logger.Error("Failed to get top jokes", logger.Err(err))
+		return b.queueOrSend(c.Chat().ID, "Failed to get top jokes, try again later")
+	}
+	return b.enqueue(msg)
+}
+
+func (b *Bot) renderTopPage(ctx context.Context, args string, page int) (string, bool, error) {
+	period, source, ok := parseTopArgs(strings.Fields(args))
+	if !ok {
+		return "", false, fmt.Errorf("invalid top arguments %q", args)
+	}
+
+	jokes, hasMore, err := b.jokeDB.GetTop(ctx, database.TopQuery{
+		Since:  period.since(time.Now()),
+		Source: source,
+		Limit:  topPageSize,
+		Offset: page * topPageSize,
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	text, err := formatTopPage(b.format, period, source, page, jokes)
+	return text, hasMore, err
+}
+
+func formatTopPage(f *Formatter, period topPeriod, source models.JokeSource, page int, jokes []models.Joke) (string, error) {
+	title := "Top jokes"
+	if source != "" {
+		title += " from " + string(source)
+	}
+	switch period {
+	case periodDay:
+		title += " today"
+	case periodWeek:
+		title += " this week"
+	}
+
+	return f.Render("top", listPage{
+		Title: title,
+		Page:  displayPage(page),
+		Jokes: listItems(jokes, page*topPageSize),
+	})
+}
+
+// listPage is the data of the top and search templates.
+type listPage struct {
+	Title string
+	Query string
+	// Page is the one-based page number, 0 on the first page, which shows
+	// no number.
+	Page  int
+	Jokes []listItem
+}
+
+type listItem struct {
+	Number             int
+	ID                 int64
+	Snippet            string
+	Upvotes, Downvotes int
+}
+
+func displayPage(page int) int {
+	if page == 0 {
+		return 0
+	}
+	return page + 1
+}
+
+// listItems turns jokes into numbered snippets, numbering from offset+1 so
+// that the count continues across pages.
+func listItems(jokes []models.Joke, offset int) []listItem {
+	items := make([]listItem, len(jokes))
+	for i, joke := range jokes {
+		items[i] = listItem{
+			Number:    offset + i + 1,
+			ID:        joke.ID,
+			Snippet:   truncateRunes(joke.Content, listSnippetRunes),
+			Upvotes:   joke.Upvotes,
+			Downvotes: joke.Downvotes,
+		}
+	}
+	return items
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
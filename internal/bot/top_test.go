@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"anek-bot/internal/models"
+)
+
+func TestParseTopArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		period topPeriod
+		source models.JokeSource
+		ok     bool
+	}{
+		{"defaults", nil, periodAll, "", true},
+		{"period only", []string{"week"}, periodWeek, "", true},
+		{"source only", []string{"reddit"}, periodAll, models.SourceReddit, true},
+		{"both", []string{"day", "anekdot"}, periodDay, models.SourceAnekdot, true},
+		{"reversed", []string{"anekdot", "DAY"}, periodDay, models.SourceAnekdot, true},
+		{"feed source", []string{"week", "feed"}, periodWeek, "feed", true},
+		{"invalid source", []string{"week", "r/jokes"}, "", "", false},
+		{"too many", []string{"reddit", "anekdot"}, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			period, source, ok := parseTopArgs(tt.args)
+			if period != tt.period || source != tt.source || ok != tt.ok {
+				t.Errorf("parseTopArgs(%v) = (%q, %q, %v), want (%q, %q, %v)",
+					tt.args, period, source, ok, tt.period, tt.source, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTopPeriodSince(t *testing.T) {
+	now := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+
+	if got := periodDay.since(now); !got.Equal(now.Add(-24 * time.Hour)) {
+		t.Errorf("day since = %v", got)
+	}
+	if got := periodWeek.since(now); !got.Equal(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("week since = %v", got)
+	}
+	if got := periodAll.since(now); !got.IsZero() {
+		t.Errorf("all since = %v, want zero", got)
+	}
+}
+
+func TestFormatTopPage(t *testing.T) {
+	f, err := NewFormatter("Markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jokes := []models.Joke{
+		{ID: 10, Content: "First joke", Upvotes: 5, Downvotes: 1},
+		{ID: 11, Content: strings.Repeat("a", listSnippetRunes+50), Upvotes: 2},
+	}
+
+	text, err := formatTopPage(f, periodWeek, models.SourceReddit, 1, jokes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(text, "*Top jokes from reddit this week* (page 2)") {
+		t.Errorf("Unexpected header: %q", strings.SplitN(text, "\n", 2)[0])
+	}
+	if !strings.Contains(text, "6. First joke\n👍 5  👎 1  · #10") {
+		t.Errorf("Expected numbering to continue across pages, got %q", text)
+	}
+	if !strings.Contains(text, "…") {
+		t.Error("Expected long joke to be truncated")
+	}
+
+	empty, err := formatTopPage(f, periodAll, "", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(empty, "No rated jokes yet") {
+		t.Errorf("Unexpected empty page: %q", empty)
+	}
+}
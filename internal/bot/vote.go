@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"anek-bot/internal/database"
+	"anek-bot/internal/queue"
+	"anek-bot/pkg/logger"
+
+	"gopkg.in/telebot.v4"
+)
+
+const voteUnique = "vote"
+
+var errInvalidVoteData = errors.New("invalid vote data")
+
+func voteButtons(jokeID int64, upvotes, downvotes int) [][]queue.Button {
+	id := strconv.FormatInt(jokeID, 10)
+	return [][]queue.Button{{
+		{Text: fmt.Sprintf("👍 %d", upvotes), Unique: voteUnique, Data: id + "|1"},
+		{Text: fmt.Sprintf("👎 %d", downvotes), Unique: voteUnique, Data: id + "|-1"},
+	}}
+}
+
+func parseVoteData(data string) (int64, int, error) {
+	idStr, valueStr, ok := strings.Cut(data, "|")
+	if !ok {
+		return 0, 0, errInvalidVoteData
+	}
+
+	jokeID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || jokeID <= 0 {
+		return 0, 0, errInvalidVoteData
+	}
+
+	value, err := strconv.Atoi(valueStr)
+	if err != nil || (value != 1 && value != -1) {
+		return 0, 0, errInvalidVoteData
+	}
+
+	return jokeID, value, nil
+}
+
+func (b *Bot) handleVote(c telebot.Context) error {
+	jokeID, value, err := parseVoteData(c.Callback().Data)
+	if err != nil {
+		logger.Warn("Invalid vote callback",
+			logger.Int64("user_id", c.Sender().ID),
+			logger.String("callback_data", c.Callback().Data),
+		)
+		return c.Respond(&telebot.CallbackResponse{Text: "Invalid vote"})
+	}
+
+	vote, err := b.jokeDB.Vote(context.Background(), c.Sender().ID, jokeID, value)
+	if err != nil {
+		if errors.Is(err, database.ErrNoJokesFound) {
+			return c.Respond(&telebot.CallbackResponse{Text: "This joke no longer exists"})
+		}
+		logger.Error("Failed to record vote",
+			logger.Err(err),
+			logger.Int64("user_id", c.Sender().ID),
+			logger.Int64("joke_id", jokeID),
+		)
+		return c.Respond(&telebot.CallbackResponse{Text: "Failed to record your vote, try again later"})
+	}
+
+	markup := replyMarkup(voteButtons(jokeID, vote.Upvotes, vote.Downvotes))
+	if _, err := c.Bot().EditReplyMarkup(c.Callback(), markup); err != nil && !isNotModified(err) {
+		logger.Warn("Failed to update vote buttons", logger.Err(err), logger.Int64("joke_id", jokeID))
+	}
+
+	return c.Respond(&telebot.CallbackResponse{Text: voteResponse(vote.Value)})
+}
+
+func voteResponse(value int) string {
+	switch value {
+	case 1:
+		return "You liked this joke"
+	case -1:
+		return "You disliked this joke"
+	default:
+		return "Vote removed"
+	}
+}
+
+func replyMarkup(buttons [][]queue.Button) *telebot.ReplyMarkup {
+	if len(buttons) == 0 {
+		return nil
+	}
+
+	markup := &telebot.ReplyMarkup{}
+	for _, row := range buttons {
+		keys := make([]telebot.InlineButton, 0, len(row))
+		for _, btn := range row {
+			keys = append(keys, telebot.InlineButton{
+				Unique: btn.Unique,
+				Text:   btn.Text,
+				Data:   btn.Data,
+			})
+		}
+		markup.InlineKeyboard = append(markup.InlineKeyboard, keys)
+	}
+	return markup
+}
+
+func isNotModified(err error) bool {
+	return errors.Is(err, telebot.ErrSameMessageContent) || errors.Is(err, telebot.ErrMessageNotModified)
+}
@@ -0,0 +1,78 @@
+package bot
+
+import "testing"
+
+func TestParseVoteData(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		jokeID  int64
+		value   int
+		wantErr bool
+	}{
+		{"upvote", "42|1", 42, 1, false},
+		{"downvote", "7|-1", 7, -1, false},
+		{"missing separator", "42", 0, 0, true},
+		{"bad id", "abc|1", 0, 0, true},
+		{"negative id", "-3|1", 0, 0, true},
+		{"bad value", "42|2", 0, 0, true},
+		{"empty", "", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jokeID, value, err := parseVoteData(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVoteData(%q) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+			}
+			if jokeID != tt.jokeID || value != tt.value {
+				t.Errorf("parseVoteData(%q) = (%d, %d), want (%d, %d)", tt.data, jokeID, value, tt.jokeID, tt.value)
+			}
+		})
+	}
+}
+
+func TestVoteButtons(t *testing.T) {
+	buttons := voteButtons(42, 3, 1)
+	if len(buttons) != 1 || len(buttons[0]) != 2 {
+		t.Fatalf("Expected one row with two buttons, got %v", buttons)
+	}
+
+	up, down := buttons[0][0], buttons[0][1]
+	if up.Text != "👍 3" || down.Text != "👎 1" {
+		t.Errorf("Unexpected button texts: %q, %q", up.Text, down.Text)
+	}
+
+	for _, btn := range []struct {
+		data  string
+		value int
+	}{{up.Data, 1}, {down.Data, -1}} {
+		jokeID, value, err := parseVoteData(btn.data)
+		if err != nil || jokeID != 42 || value != btn.value {
+			t.Errorf("Button data %q does not round-trip: (%d, %d, %v)", btn.data, jokeID, value, err)
+		}
+	}
+}
+
+func TestReplyMarkup(t *testing.T) {
+	if replyMarkup(nil) != nil {
+		t.Error("Expected nil markup for no buttons")
+	}
+
+	markup := replyMarkup(voteButtons(1, 0, 0))
+	if len(markup.InlineKeyboard) != 1 || len(markup.InlineKeyboard[0]) != 2 {
+		t.Fatalf("Unexpected keyboard layout: %v", markup.InlineKeyboard)
+	}
+	if markup.InlineKeyboard[0][0].Unique != voteUnique {
+		t.Errorf("Unique = %q, want %q", markup.InlineKeyboard[0][0].Unique, voteUnique)
+	}
+}
+
+func TestVoteResponse(t *testing.T) {
+	if voteResponse(0) != "Vote removed" {
+		t.Errorf("voteResponse(0) = %q", voteResponse(0))
+	}
+	if voteResponse(1) == voteResponse(-1) {
+		t.Error("Expected different responses for up and down votes")
+	}
+}
@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+
+	"anek-bot/internal/config"
+	"anek-bot/pkg/logger"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+	// maxUpdateBytes bounds the body of a webhook request. Updates are a
+	// few kilobytes at most.
+	maxUpdateBytes = 1 << 20
+	// setWebhookRetry is how long to wait before registering the webhook
+	// with Telegram again after it failed.
+	setWebhookRetry = 10 * time.Second
+)
+
+// secretTokenPattern is what Telegram accepts as a webhook secret token.
+var secretTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// Webhook is a telebot poller that gets updates from Telegram's webhook
+// requests instead of long polling. It serves them on its own listener or,
+// through Register, on another server's mux.
+type Webhook struct {
+	cfg  config.WebhookConfig
+	path string
+
+	mu   sync.RWMutex
+	dest chan<- telebot.Update
+	stop <-chan struct{}
+}
+
+func NewWebhook(cfg config.WebhookConfig) (*Webhook, error) {
+	u, err := url.Parse(cfg.PublicURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("webhook public URL must be an https URL, got %q", cfg.PublicURL)
+	}
+	if !secretTokenPattern.MatchString(cfg.SecretToken) {
+		return nil, fmt.Errorf("webhook secret token must be 1-256 letters, digits, _ or -")
+	}
+	if cfg.Key != "" && cfg.Cert == "" {
+		return nil, fmt.Errorf("webhook key is set without a cert")
+	}
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	return &Webhook{cfg: cfg, path: path}, nil
+}
+
+// Register adds the webhook to mux unless it has a listener of its own.
+func (w *Webhook) Register(mux *http.ServeMux) {
+	if w.cfg.Listen == "" {
+		mux.Handle(w.path, w)
+	}
+}
+
+// Poll accepts updates until stop is closed. Updates can arrive before the
+// webhook is registered with Telegram, from the previous run's webhook.
+func (w *Webhook) Poll(b *telebot.Bot, dest chan telebot.Update, stop chan struct{}) {
+	w.mu.Lock()
+	w.dest, w.stop = dest, stop
+	w.mu.Unlock()
+
+	if w.cfg.Listen != "" {
+		go w.listen(stop)
+	}
+	w.setWebhook(b, stop)
+
+	<-stop
+	w.mu.Lock()
+	w.dest, w.stop = nil, nil
+	w.mu.Unlock()
+}
+
+// setWebhook registers the webhook with Telegram, retrying until it works
+// or stop is closed.
+func (w *Webhook) setWebhook(b *telebot.Bot, stop <-chan struct{}) {
+	hook := &telebot.Webhook{
+		SecretToken: w.cfg.SecretToken,
+		Endpoint: &telebot.WebhookEndpoint{
+			PublicURL: w.cfg.PublicURL,
+			Cert:      w.cfg.Cert,
+		},
+	}
+
+	for {
+		err := b.SetWebhook(hook)
+		if err == nil {
+			logger.Info("Webhook registered", logger.String("url", w.cfg.PublicURL))
+			return
+		}
+		logger.Error("Failed to register webhook", logger.Err(err), logger.Duration("retry_in", setWebhookRetry))
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(setWebhookRetry):
+		}
+	}
+}
+
+func (w *Webhook) listen(stop <-chan struct{}) {
+	mux := http.NewServeMux()
+	mux.Handle(w.path, w)
+	srv := &http.Server{
+		Addr:              w.cfg.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	logger.Info("Webhook server starting", logger.String("addr", w.cfg.Listen))
+	var err error
+	if w.cfg.Key != "" {
+		err = srv.ListenAndServeTLS(w.cfg.Cert, w.cfg.Key)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Webhook server error", logger.Err(err))
+	}
+}
+
+// ServeHTTP passes one update on to the bot. Telegram retries requests
+// that fail, so the update is only acknowledged once the bot has it.
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.Header.Get(secretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(w.cfg.SecretToken)) != 1 {
+		logger.Warn("Webhook request with a wrong secret token", logger.String("remote_addr", r.RemoteAddr))
+		http.Error(rw, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var update telebot.Update
+	if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, maxUpdateBytes)).Decode(&update); err != nil {
+		logger.Warn("Failed to decode webhook update", logger.Err(err))
+		http.Error(rw, "bad update", http.StatusBadRequest)
+		return
+	}
+
+	w.mu.RLock()
+	dest, stop := w.dest, w.stop
+	w.mu.RUnlock()
+	if dest == nil {
+		http.Error(rw, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	select {
+	case dest <- update:
+		rw.WriteHeader(http.StatusOK)
+	case <-stop:
+		http.Error(rw, "shutting down", http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+	}
+}
@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"anek-bot/internal/config"
+
+	"gopkg.in/telebot.v4"
+)
+
+const testSecret = "s3cret_token-1"
+
+func TestNewWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.WebhookConfig
+		path    string
+		wantErr bool
+	}{
+		{"valid", config.WebhookConfig{PublicURL: "https://bot.example.com/tg/hook", SecretToken: testSecret}, "/tg/hook", false},
+		{"root path", config.WebhookConfig{PublicURL: "https://bot.example.com", SecretToken: testSecret}, "/", false},
+		{"plain http", config.WebhookConfig{PublicURL: "http://bot.example.com/hook", SecretToken: testSecret}, "", true},
+		{"no secret", config.WebhookConfig{PublicURL: "https://bot.example.com/hook"}, "", true},
+		{"bad secret", config.WebhookConfig{PublicURL: "https://bot.example.com/hook", SecretToken: "no spaces"}, "", true},
+		{"key without cert", config.WebhookConfig{PublicURL: "https://bot.example.com/hook", SecretToken: testSecret, Key: "key.pem"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := NewWebhook(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && w.path != tt.path {
+				t.Errorf("path = %q, want %q", w.path, tt.path)
+			}
+		})
+	}
+}
+
+func TestNewBotWebhook(t *testing.T) {
+	cfg := config.BotConfig{
+		Token:   "test-token",
+		Webhook: config.WebhookConfig{PublicURL: "https://bot.example.com/hook", SecretToken: testSecret},
+	}
+	b, err := New(cfg, Repositories{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.settings.Poller != b.webhook || b.webhook == nil {
+		t.Error("webhook mode does not use the webhook poller")
+	}
+
+	cfg.Webhook.SecretToken = ""
+	if _, err := New(cfg, Repositories{}, nil); err == nil {
+		t.Error("Expected error for a webhook without secret token")
+	}
+}
+
+// TestWebhookUpdates runs a bot against a fake Telegram API and posts
+// updates to its webhook the way Telegram does.
+func TestWebhookUpdates(t *testing.T) {
+	registered := make(chan string, 1)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/setWebhook") {
+			var params struct {
+				SecretToken string `json:"secret_token"`
+			}
+			json.NewDecoder(r.Body).Decode(&params)
+			registered <- params.SecretToken
+		}
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer api.Close()
+
+	webhook, err := NewWebhook(config.WebhookConfig{PublicURL: "https://bot.example.com/hook", SecretToken: testSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	webhook.Register(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	post := func(method, secret, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+"/hook", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret != "" {
+			req.Header.Set(secretTokenHeader, secret)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	const update = `{"update_id":1,"message":{"message_id":1,"text":"/ping","chat":{"id":42,"type":"private"},"from":{"id":42}}}`
+
+	if code := post(http.MethodPost, testSecret, update); code != http.StatusServiceUnavailable {
+		t.Errorf("update before start = %d, want 503", code)
+	}
+
+	tbot, err := telebot.NewBot(telebot.Settings{Token: "test-token", URL: api.URL, Poller: webhook, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pings := make(chan int64, 1)
+	tbot.Handle("/ping", func(c telebot.Context) error {
+		pings <- c.Chat().ID
+		return nil
+	})
+	go tbot.Start()
+	defer tbot.Stop()
+
+	select {
+	case secret := <-registered:
+		if secret != testSecret {
+			t.Errorf("registered secret token = %q", secret)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not registered")
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		secret string
+		body   string
+		want   int
+	}{
+		{"no secret", http.MethodPost, "", update, http.StatusUnauthorized},
+		{"wrong secret", http.MethodPost, "wrong", update, http.StatusUnauthorized},
+		{"get", http.MethodGet, testSecret, "", http.StatusMethodNotAllowed},
+		{"bad json", http.MethodPost, testSecret, "{", http.StatusBadRequest},
+		{"update", http.MethodPost, testSecret, update, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if code := post(tt.method, tt.secret, tt.body); code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	select {
+	case chatID := <-pings:
+		if chatID != 42 {
+			t.Errorf("update came from chat %d, want 42", chatID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("update did not reach the handler")
+	}
+	select {
+	case <-pings:
+		t.Error("rejected requests reached the handler")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
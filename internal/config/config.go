@@ -6,21 +6,27 @@ import (
 	"os"
 	"time"
 
+	"anek-bot/internal/fingerprint"
+
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
 var (
 	ErrEmptyBotToken   = errors.New("telegram bot token is required")
 	ErrEmptyDBPassword = errors.New("database password is required")
+	ErrDedupThreshold  = errors.New("dedup threshold is too low")
 )
 
 type Config struct {
-	App      AppConfig      `yaml:"app" env:"APP"`
-	Database DatabaseConfig `yaml:"database" env:"DB"`
-	Bot      BotConfig      `yaml:"bot" env:"BOT"`
-	Parser   ParserConfig   `yaml:"parser" env:"PARSER"`
-	NATS     NATSConfig     `yaml:"nats" env:"NATS"`
-	Health   HealthConfig   `yaml:"health" env:"HEALTH"`
+	App      AppConfig       `yaml:"app" env:"APP"`
+	Database DatabaseConfig  `yaml:"database" env:"DB"`
+	Bot      BotConfig       `yaml:"bot" env:"BOT"`
+	Parser   ParserConfig    `yaml:"parser" env:"PARSER"`
+	Dedup    DedupConfig     `yaml:"dedup" env:"DEDUP"`
+	Channels []ChannelConfig `yaml:"channels"`
+	Queue    QueueConfig     `yaml:"queue" env:"QUEUE"`
+	NATS     NATSConfig      `yaml:"nats" env:"NATS"`
+	Health   HealthConfig    `yaml:"health" env:"HEALTH"`
 }
 
 type AppConfig struct {
@@ -47,40 +53,178 @@ func (d DatabaseConfig) ConnectionString() string {
 }
 
 type BotConfig struct {
-	Token     string `yaml:"token" env:"TOKEN"`
-	ParseMode string `yaml:"parse_mode" env:"PARSE_MODE" env-default:"Markdown"`
+	Token        string        `yaml:"token" env:"TOKEN"`
+	ParseMode    string        `yaml:"parse_mode" env:"PARSE_MODE" env-default:"Markdown"`
+	RepeatWindow time.Duration `yaml:"repeat_window" env:"REPEAT_WINDOW"`
+	// AdminIDs are Telegram user IDs allowed to moderate the bot.
+	AdminIDs []int64 `yaml:"admin_ids" env:"ADMIN_IDS" env-separator:","`
+	// DefaultTimezone applies to /subscribe when the user gives no zone.
+	DefaultTimezone string `yaml:"default_timezone" env:"DEFAULT_TIMEZONE" env-default:"Europe/Moscow"`
+	// RateLimit keeps outgoing messages under Telegram's limits.
+	RateLimit RateLimitConfig `yaml:"rate_limit" env:"RATE"`
+	// Webhook replaces long polling when its PublicURL is set.
+	Webhook WebhookConfig `yaml:"webhook" env:"WEBHOOK"`
+}
+
+// WebhookConfig makes Telegram push updates to PublicURL. Without Listen
+// the webhook is served by the health server at the path of PublicURL, for
+// deployments where a load balancer terminates TLS. Cert is uploaded to
+// Telegram when it is self-signed; with Key as well the bot serves TLS on
+// Listen itself.
+type WebhookConfig struct {
+	PublicURL string `yaml:"public_url" env:"PUBLIC_URL"`
+	Listen    string `yaml:"listen" env:"LISTEN"`
+	// SecretToken is sent by Telegram with every update, requests without
+	// it are rejected.
+	SecretToken string `yaml:"secret_token" env:"SECRET_TOKEN"`
+	Cert        string `yaml:"cert" env:"CERT"`
+	Key         string `yaml:"key" env:"KEY"`
+}
+
+func (w WebhookConfig) Enabled() bool {
+	return w.PublicURL != ""
+}
+
+// RateLimitConfig sets the token buckets in front of every send. Telegram
+// allows about 30 messages per second in total, one per second in a
+// private chat and 20 per minute in a group.
+type RateLimitConfig struct {
+	GlobalPerSecond float64 `yaml:"global_per_second" env:"GLOBAL_PER_SECOND" env-default:"30"`
+	ChatPerSecond   float64 `yaml:"chat_per_second" env:"CHAT_PER_SECOND" env-default:"1"`
+	GroupPerMinute  float64 `yaml:"group_per_minute" env:"GROUP_PER_MINUTE" env-default:"20"`
+	// Burst is how many messages a single chat may get at once.
+	Burst int `yaml:"burst" env:"BURST" env-default:"3"`
 }
 
+// ParserConfig.SeenCache keeps the hashes of jokes the parser already
+// published, so a joke fetched again within SeenTTL is not queued again.
+// Zero SeenCache turns the cache off.
 type ParserConfig struct {
-	Enabled      bool          `yaml:"enabled" env:"ENABLED" env-default:"true"`
-	IntervalMins time.Duration `yaml:"interval_minutes" env:"INTERVAL_MINUTES" env-default:"30m"`
-	Sources      SourcesConfig `yaml:"sources" env:"PARSER_SOURCES"`
+	Enabled      bool           `yaml:"enabled" env:"ENABLED" env-default:"true"`
+	IntervalMins time.Duration  `yaml:"interval_minutes" env:"INTERVAL_MINUTES" env-default:"30m"`
+	SeenCache    int            `yaml:"seen_cache" env:"SEEN_CACHE" env-default:"10000"`
+	SeenTTL      time.Duration  `yaml:"seen_ttl" env:"SEEN_TTL" env-default:"24h"`
+	Sources      []SourceConfig `yaml:"sources"`
 }
 
-type SourcesConfig struct {
-	Reddit  RedditConfig  `yaml:"reddit" env:"PARSER_REDDIT"`
-	Anekdot AnekdotConfig `yaml:"anekdot" env:"PARSER_ANEKDOT"`
+type SourceConfig struct {
+	Name       string   `yaml:"name"`
+	Type       string   `yaml:"type"`
+	Enabled    bool     `yaml:"enabled"`
+	Limit      int      `yaml:"limit"`
+	URL        string   `yaml:"url"`
+	URLs       []string `yaml:"urls"`
+	Subreddits []string `yaml:"subreddits"`
+
+	Selectors SelectorsConfig `yaml:"selectors"`
+	Encoding  string          `yaml:"encoding"`
+	MaxPages  int             `yaml:"max_pages"`
 }
 
-type RedditConfig struct {
-	Enabled    bool     `yaml:"enabled" env:"ENABLED" env-default:"true"`
-	Subreddits []string `yaml:"subreddits" env:"SUBREDDITS" env-separator:","`
-	Limit      int      `yaml:"limit" env:"LIMIT" env-default:"25"`
+type SelectorsConfig struct {
+	Item string `yaml:"item"`
+	Text string `yaml:"text"`
+	Link string `yaml:"link"`
+	Next string `yaml:"next"`
 }
 
-type AnekdotConfig struct {
-	Enabled bool `yaml:"enabled" env:"ENABLED" env-default:"true"`
-	Limit   int  `yaml:"limit" env:"LIMIT" env-default:"20"`
+func (s SourceConfig) SourceName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Type
+}
+
+func (s SourceConfig) AllURLs() []string {
+	urls := make([]string, 0, len(s.URLs)+1)
+	if s.URL != "" {
+		urls = append(urls, s.URL)
+	}
+	return append(urls, s.URLs...)
+}
+
+// ChannelConfig describes a Telegram channel the bot posts jokes to. Posts go
+// out at the given local Times each day, or every Interval when no times are
+// set. The bot must be an admin of the channel.
+type ChannelConfig struct {
+	Name     string        `yaml:"name"`
+	ChatID   int64         `yaml:"chat_id"`
+	Enabled  bool          `yaml:"enabled"`
+	Times    []string      `yaml:"times"`
+	Timezone string        `yaml:"timezone"`
+	Interval time.Duration `yaml:"interval"`
+
+	// Source limits posts to one joke source. TopRated posts the best rated
+	// unposted joke instead of a random one, MinRating skips jokes rated
+	// lower. NSFW jokes are only posted when NSFW is set.
+	Source    string `yaml:"source"`
+	TopRated  bool   `yaml:"top_rated"`
+	MinRating int    `yaml:"min_rating"`
+	NSFW      bool   `yaml:"nsfw"`
 }
 
+const (
+	DedupActionReject = "reject"
+	DedupActionLink   = "link"
+)
+
+type DedupConfig struct {
+	Enabled   bool    `yaml:"enabled" env:"ENABLED" env-default:"true"`
+	Threshold float64 `yaml:"threshold" env:"THRESHOLD" env-default:"0.9"`
+	Action    string  `yaml:"action" env:"ACTION" env-default:"reject"`
+}
+
+// Validate rejects a threshold below what the SimHash band index can look
+// up, which would miss near-duplicates without any sign of it.
+func (c DedupConfig) Validate() error {
+	if c.Enabled && c.Threshold < fingerprint.MinSimilarity {
+		return fmt.Errorf("%w: %.3f, the lowest supported is %.3f", ErrDedupThreshold, c.Threshold, fingerprint.MinSimilarity)
+	}
+	return nil
+}
+
+// HealthConfig.MetricsEndpoint serves the expvar counters, such as the
+// duplicates dropped by each layer, next to the health check. An empty
+// MetricsEndpoint turns it off.
 type HealthConfig struct {
-	Port     int    `yaml:"port" env:"PORT" env-default:"8080"`
-	Endpoint string `yaml:"endpoint" env:"ENDPOINT" env-default:"/healthz"`
+	Port            int    `yaml:"port" env:"PORT" env-default:"8080"`
+	Endpoint        string `yaml:"endpoint" env:"ENDPOINT" env-default:"/healthz"`
+	MetricsEndpoint string `yaml:"metrics_endpoint" env:"METRICS_ENDPOINT" env-default:"/debug/vars"`
 }
 
+const (
+	QueueDriverNATS   = "nats"
+	QueueDriverMemory = "memory"
+)
+
+// QueueConfig picks the message queue. The memory driver keeps everything
+// in process, for development without NATS; queued messages are lost on
+// restart.
+type QueueConfig struct {
+	Driver string `yaml:"driver" env:"DRIVER" env-default:"nats"`
+}
+
+// NATSConfig also describes the JetStream stream and consumers, which the
+// bot creates or updates on start. Retention is limits, interest or
+// workqueue. A message that fails MaxDeliver times becomes a dead letter;
+// waiting out rate limits does not count as failing. Dead letters are kept
+// on a stream of their own and expire after DeadLetterMaxAge.
+// A message published again with the same ID within DuplicateWindow is
+// dropped; it should outlast the parser interval so that jokes seen on
+// every run are caught. Zero MaxBytes, MaxDeliver and DeadLetterMaxAge mean
+// no limit.
 type NATSConfig struct {
-	URL        string `yaml:"url" env:"URL" env-default:"nats://localhost:4222"`
-	StreamName string `yaml:"stream_name" env:"STREAM_NAME" env-default:"ANEK"`
+	URL        string        `yaml:"url" env:"URL" env-default:"nats://localhost:4222"`
+	StreamName string        `yaml:"stream_name" env:"STREAM_NAME" env-default:"ANEK"`
+	Retention  string        `yaml:"retention" env:"RETENTION" env-default:"limits"`
+	MaxAge     time.Duration `yaml:"max_age" env:"MAX_AGE" env-default:"168h"`
+	MaxBytes   int64         `yaml:"max_bytes" env:"MAX_BYTES"`
+	Replicas   int           `yaml:"replicas" env:"REPLICAS" env-default:"1"`
+	AckWait    time.Duration `yaml:"ack_wait" env:"ACK_WAIT" env-default:"30s"`
+	MaxDeliver int           `yaml:"max_deliver" env:"MAX_DELIVER" env-default:"10"`
+
+	DuplicateWindow  time.Duration `yaml:"duplicate_window" env:"DUPLICATE_WINDOW" env-default:"1h"`
+	DeadLetterMaxAge time.Duration `yaml:"dead_letter_max_age" env:"DEAD_LETTER_MAX_AGE" env-default:"720h"`
 }
 
 func Load() (*Config, error) {
@@ -105,5 +249,9 @@ func Load() (*Config, error) {
 		return nil, ErrEmptyDBPassword
 	}
 
+	if err := cfg.Dedup.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
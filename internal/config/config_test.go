@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"testing"
 )
@@ -49,6 +50,25 @@ func TestNATSConfig(t *testing.T) {
 	}
 }
 
+func TestDedupConfigValidate(t *testing.T) {
+	tests := []struct {
+		cfg     DedupConfig
+		wantErr bool
+	}{
+		{DedupConfig{Enabled: true, Threshold: 0.9}, false},
+		{DedupConfig{Enabled: true, Threshold: 1}, false},
+		{DedupConfig{Enabled: true, Threshold: 0.8}, true},
+		{DedupConfig{Enabled: false, Threshold: 0.8}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.cfg.Validate()
+		if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrDedupThreshold)) {
+			t.Errorf("Validate(%+v) = %v, wantErr %v", tt.cfg, err, tt.wantErr)
+		}
+	}
+}
+
 func TestEnvOverrides(t *testing.T) {
 	os.Setenv("APP_NAME", "custom-name")
 	defer os.Unsetenv("APP_NAME")
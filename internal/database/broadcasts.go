@@ -0,0 +1,286 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"anek-bot/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrBroadcastNotFound = errors.New("broadcast not found")
+
+const broadcastColumns = "id, admin_id, chat_id, text, status, total, created_at, started_at, finished_at"
+
+type BroadcastRepository struct {
+	db *DB
+}
+
+func NewBroadcastRepository(db *DB) *BroadcastRepository {
+	return &BroadcastRepository{db: db}
+}
+
+func scanBroadcast(row pgx.Row) (*models.Broadcast, error) {
+	var b models.Broadcast
+	err := row.Scan(
+		&b.ID, &b.AdminID, &b.ChatID, &b.Text, &b.Status,
+		&b.Total, &b.CreatedAt, &b.StartedAt, &b.FinishedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrBroadcastNotFound
+		}
+		return nil, err
+	}
+	return &b, nil
+}
+
+// CreateDraft stores a broadcast that waits for the admin to confirm it.
+func (r *BroadcastRepository) CreateDraft(ctx context.Context, b *models.Broadcast) error {
+	query := `
+		INSERT INTO broadcasts (admin_id, chat_id, text)
+		VALUES ($1, $2, $3)
+		RETURNING id, status, created_at
+	`
+	return r.db.Pool.QueryRow(ctx, query, b.AdminID, b.ChatID, b.Text).
+		Scan(&b.ID, &b.Status, &b.CreatedAt)
+}
+
+func (r *BroadcastRepository) Get(ctx context.Context, id int64) (*models.Broadcast, error) {
+	query := "SELECT " + broadcastColumns + " FROM broadcasts WHERE id = $1"
+	return scanBroadcast(r.db.Pool.QueryRow(ctx, query, id))
+}
+
+// CountRecipients returns how many users a broadcast started now would reach.
+func (r *BroadcastRepository) CountRecipients(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM users u WHERE "+broadcastRecipientCondition).Scan(&count)
+	return count, err
+}
+
+// broadcastRecipientCondition selects the users a broadcast goes to.
+const broadcastRecipientCondition = `
+	u.is_active
+	AND NOT EXISTS (SELECT 1 FROM user_bans b WHERE b.telegram_id = u.telegram_id)
+`
+
+// Start moves a draft to running and snapshots its recipients. Users who
+// join later do not get it. Starting a broadcast that is no longer a draft
+// returns ErrBroadcastNotFound.
+func (r *BroadcastRepository) Start(ctx context.Context, id int64) (*models.Broadcast, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE broadcasts
+		SET status = 'running', started_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'draft'
+		RETURNING ` + broadcastColumns
+	b, err := scanBroadcast(tx.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO broadcast_deliveries (broadcast_id, telegram_id)
+		SELECT $1, u.telegram_id FROM users u
+		WHERE `+broadcastRecipientCondition+`
+		ON CONFLICT DO NOTHING
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	b.Total = int(tag.RowsAffected())
+	if _, err := tx.Exec(ctx, "UPDATE broadcasts SET total = $2 WHERE id = $1", id, b.Total); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Cancel stops a draft or running broadcast. Messages already queued are
+// still delivered. It reports false when the broadcast was not active.
+func (r *BroadcastRepository) Cancel(ctx context.Context, id int64) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE broadcasts
+		SET status = 'cancelled', finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status IN ('draft', 'running')
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Running returns the broadcasts being sent, oldest first.
+func (r *BroadcastRepository) Running(ctx context.Context) ([]models.Broadcast, error) {
+	query := "SELECT " + broadcastColumns + " FROM broadcasts WHERE status = 'running' ORDER BY id"
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var broadcasts []models.Broadcast
+	for rows.Next() {
+		b, err := scanBroadcast(rows)
+		if err != nil {
+			return nil, err
+		}
+		broadcasts = append(broadcasts, *b)
+	}
+	return broadcasts, rows.Err()
+}
+
+// ClaimBatch locks up to limit pending recipients and passes each to send.
+// Recipients send accepted are marked queued. Rows stay locked until the
+// batch is done, so replicas never queue the same recipient concurrently.
+// It returns how many were queued and the first send error, which also
+// ends the batch.
+func (r *BroadcastRepository) ClaimBatch(ctx context.Context, id int64, limit int, send func(telegramID int64) error) (int, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT telegram_id FROM broadcast_deliveries
+		WHERE broadcast_id = $1 AND status = 'pending'
+		ORDER BY telegram_id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, id, limit)
+	if err != nil {
+		return 0, err
+	}
+	recipients, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return 0, err
+	}
+
+	queued := make([]int64, 0, len(recipients))
+	var sendErr error
+	for _, tid := range recipients {
+		if sendErr = send(tid); sendErr != nil {
+			break
+		}
+		queued = append(queued, tid)
+	}
+
+	if len(queued) > 0 {
+		_, err = tx.Exec(ctx, `
+			UPDATE broadcast_deliveries
+			SET status = 'queued', updated_at = CURRENT_TIMESTAMP
+			WHERE broadcast_id = $1 AND telegram_id = ANY($2) AND status = 'pending'
+		`, id, queued)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(queued), sendErr
+}
+
+// RecordDelivery stores the outcome of sending a broadcast to one user.
+// Once the broadcast is finished its counts were reported and stay as they
+// are, so late outcomes are ignored.
+func (r *BroadcastRepository) RecordDelivery(ctx context.Context, id, telegramID int64, status models.DeliveryStatus, errText string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE broadcast_deliveries
+		SET status = $3, error = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE broadcast_id = $1 AND telegram_id = $2
+		  AND NOT EXISTS (SELECT 1 FROM broadcasts WHERE id = $1 AND status = 'finished')
+	`, id, telegramID, status, errText)
+	return err
+}
+
+// Postpone notes that the message to a queued recipient was held back and
+// will be retried, so that ExpireQueued counts its staleness from now.
+func (r *BroadcastRepository) Postpone(ctx context.Context, id, telegramID int64) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE broadcast_deliveries
+		SET updated_at = CURRENT_TIMESTAMP
+		WHERE broadcast_id = $1 AND telegram_id = $2 AND status = 'queued'
+	`, id, telegramID)
+	return err
+}
+
+// ExpireQueued gives up on recipients queued or last postponed before
+// staleBefore whose delivery was never reported, so that the broadcast can
+// finish.
+func (r *BroadcastRepository) ExpireQueued(ctx context.Context, id int64, staleBefore time.Time) (int64, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE broadcast_deliveries
+		SET status = 'failed', error = 'no delivery report', updated_at = CURRENT_TIMESTAMP
+		WHERE broadcast_id = $1 AND status = 'queued' AND updated_at < $2
+	`, id, staleBefore)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *BroadcastRepository) Progress(ctx context.Context, id int64) (*models.BroadcastProgress, error) {
+	var p models.BroadcastProgress
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE status = 'pending'),
+			COUNT(*) FILTER (WHERE status = 'queued'),
+			COUNT(*) FILTER (WHERE status = 'sent'),
+			COUNT(*) FILTER (WHERE status = 'blocked'),
+			COUNT(*) FILTER (WHERE status = 'failed')
+		FROM broadcast_deliveries
+		WHERE broadcast_id = $1
+	`, id).Scan(&p.Total, &p.Pending, &p.Queued, &p.Sent, &p.Blocked, &p.Failed)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ClaimReport reports whether a progress report for the broadcast is due,
+// at most one per interval across all replicas.
+func (r *BroadcastRepository) ClaimReport(ctx context.Context, id int64, interval time.Duration) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE broadcasts
+		SET reported_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'running'
+		  AND COALESCE(reported_at, started_at) < CURRENT_TIMESTAMP - make_interval(secs => $2)
+	`, id, interval.Seconds())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Finish marks a running broadcast finished once no recipient is pending or
+// queued. Only the caller that finishes it gets true.
+func (r *BroadcastRepository) Finish(ctx context.Context, id int64) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE broadcasts
+		SET status = 'finished', finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'running'
+		  AND NOT EXISTS (
+			SELECT 1 FROM broadcast_deliveries
+			WHERE broadcast_id = $1 AND status IN ('pending', 'queued')
+		  )
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
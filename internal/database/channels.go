@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"anek-bot/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// topRatedJokeQuery is the rating-ordered counterpart of randomJokeQuery,
+// with filter arguments starting at $1.
+const topRatedJokeQuery = `
+	UPDATE jokes
+	SET used_count = used_count + 1
+	WHERE id = (
+		SELECT id FROM jokes
+		WHERE duplicate_of IS NULL %s
+		ORDER BY rating DESC, id
+		LIMIT 1
+	)
+	RETURNING ` + jokeColumns + `
+`
+
+// ChannelFilter selects which jokes a channel may receive.
+type ChannelFilter struct {
+	Source    string
+	TopRated  bool
+	MinRating int
+	NSFW      bool
+}
+
+type ChannelRepository struct {
+	db *DB
+}
+
+func NewChannelRepository(db *DB) *ChannelRepository {
+	return &ChannelRepository{db: db}
+}
+
+// SyncSchedule registers a channel. schedule is an opaque description of the
+// configured timing: next_run_at is only reset to next when it changes, so
+// restarts keep the stored run.
+func (r *ChannelRepository) SyncSchedule(ctx context.Context, chatID int64, schedule string, next time.Time) error {
+	query := `
+		INSERT INTO channel_schedules (chat_id, schedule, next_run_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (chat_id) DO UPDATE SET
+			schedule = EXCLUDED.schedule,
+			next_run_at = EXCLUDED.next_run_at
+		WHERE channel_schedules.schedule <> EXCLUDED.schedule
+	`
+	_, err := r.db.Pool.Exec(ctx, query, chatID, schedule, next)
+	return err
+}
+
+// PostDue locks the channel's schedule if it is due at now, picks the next
+// joke the channel has not received and passes it to post, or nil when every
+// matching joke was already posted, along with the scheduled run it is for.
+// post returns the next run time.
+//
+// The joke is recorded and the schedule moved only when post succeeds; on
+// failure nothing changes and the channel stays due. Replicas skip channels
+// locked by another one. It reports false when the channel is not due.
+func (r *ChannelRepository) PostDue(ctx context.Context, chatID int64, now time.Time, filter ChannelFilter, post func(joke *models.Joke, slot time.Time) (time.Time, error)) (bool, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var slot time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT next_run_at FROM channel_schedules
+		WHERE chat_id = $1 AND next_run_at <= $2
+		FOR UPDATE SKIP LOCKED
+	`, chatID, now).Scan(&slot)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	joke, err := nextChannelJoke(ctx, tx, chatID, filter)
+	if err != nil && !errors.Is(err, ErrNoJokesFound) {
+		return true, fmt.Errorf("failed to pick joke: %w", err)
+	}
+
+	next, err := post(joke, slot)
+	if err != nil {
+		return true, err
+	}
+
+	if joke != nil {
+		_, err = tx.Exec(ctx, "INSERT INTO channel_posts (chat_id, joke_id) VALUES ($1, $2)", chatID, joke.ID)
+		if err != nil {
+			return true, err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE channel_schedules
+		SET next_run_at = $2, last_posted_at = CASE WHEN $3 THEN $4 ELSE last_posted_at END
+		WHERE chat_id = $1
+	`, chatID, next, joke != nil, now)
+	if err != nil {
+		return true, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+func nextChannelJoke(ctx context.Context, tx pgx.Tx, chatID int64, filter ChannelFilter) (*models.Joke, error) {
+	var args []any
+	if !filter.TopRated {
+		args = append(args, rand.Float64())
+	}
+
+	args = append(args, chatID)
+	cond := fmt.Sprintf(`
+		AND NOT EXISTS (
+			SELECT 1 FROM channel_posts p
+			WHERE p.chat_id = $%d AND p.joke_id = jokes.id
+		)`, len(args))
+	if filter.MinRating != 0 {
+		args = append(args, filter.MinRating)
+		cond += fmt.Sprintf(" AND rating >= $%d", len(args))
+	}
+	if filter.Source != "" {
+		args = append(args, filter.Source)
+		cond += fmt.Sprintf(" AND source = $%d", len(args))
+	}
+	if !filter.NSFW {
+		cond += " AND NOT nsfw"
+	}
+
+	query := fmt.Sprintf(randomJokeQuery, cond)
+	if filter.TopRated {
+		query = fmt.Sprintf(topRatedJokeQuery, cond)
+	}
+	return scanJoke(tx.QueryRow(ctx, query, args...))
+}
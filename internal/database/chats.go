@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"anek-bot/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type ChatRepository struct {
+	db *DB
+}
+
+func NewChatRepository(db *DB) *ChatRepository {
+	return &ChatRepository{db: db}
+}
+
+// Get returns the chat's settings. Chats that never changed anything get
+// the defaults: all sources, no NSFW.
+func (r *ChatRepository) Get(ctx context.Context, chatID int64) (*models.Chat, error) {
+	query := `
+		SELECT chat_id, type, COALESCE(title, ''), sources, nsfw, created_at, updated_at
+		FROM chats
+		WHERE chat_id = $1
+	`
+	var chat models.Chat
+	err := r.db.Pool.QueryRow(ctx, query, chatID).Scan(
+		&chat.ChatID, &chat.Type, &chat.Title, &chat.Sources, &chat.NSFW,
+		&chat.CreatedAt, &chat.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &models.Chat{ChatID: chatID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+func (r *ChatRepository) Save(ctx context.Context, chat *models.Chat) error {
+	sources := chat.Sources
+	if sources == nil {
+		sources = []string{}
+	}
+
+	query := `
+		INSERT INTO chats (chat_id, type, title, sources, nsfw)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (chat_id) DO UPDATE SET
+			type = EXCLUDED.type,
+			title = EXCLUDED.title,
+			sources = EXCLUDED.sources,
+			nsfw = EXCLUDED.nsfw,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING created_at, updated_at
+	`
+	return r.db.Pool.QueryRow(ctx, query,
+		chat.ChatID, chat.Type, chat.Title, sources, chat.NSFW,
+	).Scan(&chat.CreatedAt, &chat.UpdatedAt)
+}
+
+// Migrate moves everything kept under a group's chat ID to the supergroup
+// it was upgraded to: its settings, daily subscription and seen jokes.
+// Where the supergroup already has its own, those are kept and the old
+// group's are dropped.
+func (r *ChatRepository) Migrate(ctx context.Context, from, to int64) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	statements := []string{
+		`UPDATE chats SET chat_id = $2, type = 'supergroup', updated_at = CURRENT_TIMESTAMP
+			WHERE chat_id = $1 AND NOT EXISTS (SELECT 1 FROM chats WHERE chat_id = $2)`,
+		`DELETE FROM chats WHERE chat_id = $1`,
+		`UPDATE subscriptions SET telegram_id = $2, updated_at = CURRENT_TIMESTAMP
+			WHERE telegram_id = $1 AND NOT EXISTS (SELECT 1 FROM subscriptions WHERE telegram_id = $2)`,
+		`DELETE FROM subscriptions WHERE telegram_id = $1`,
+		`UPDATE user_seen_jokes s SET telegram_id = $2
+			WHERE telegram_id = $1 AND NOT EXISTS (
+				SELECT 1 FROM user_seen_jokes n WHERE n.telegram_id = $2 AND n.joke_id = s.joke_id
+			)`,
+		`DELETE FROM user_seen_jokes WHERE telegram_id = $1`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(ctx, stmt, from, to); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
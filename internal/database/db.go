@@ -2,10 +2,16 @@ package database
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
 
 	"anek-bot/internal/config"
+	"anek-bot/internal/fingerprint"
 	"anek-bot/internal/models"
 
 	"github.com/jackc/pgx/v5"
@@ -13,9 +19,25 @@ import (
 )
 
 var (
-	ErrNoJokesFound = errors.New("no jokes found in database")
+	ErrNoJokesFound  = errors.New("no jokes found in database")
+	ErrUserNotFound  = errors.New("user not found")
+	ErrDuplicateJoke = errors.New("joke is a near-duplicate of an existing joke")
+	ErrAllJokesSeen  = errors.New("user has already seen all jokes")
 )
 
+type DuplicateError struct {
+	OriginalID int64
+	Similarity float64
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("joke is a near-duplicate of joke %d (similarity %.2f)", e.OriginalID, e.Similarity)
+}
+
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrDuplicateJoke
+}
+
 type ConnectionError struct {
 	Host string
 	Port int
@@ -74,42 +96,250 @@ func (db *DB) Ping(ctx context.Context) error {
 }
 
 type JokeRepository struct {
-	db *DB
+	db    *DB
+	dedup config.DedupConfig
 }
 
-func NewJokeRepository(db *DB) *JokeRepository {
-	return &JokeRepository{db: db}
+type JokeRepositoryOption func(*JokeRepository)
+
+func WithDedup(cfg config.DedupConfig) JokeRepositoryOption {
+	return func(r *JokeRepository) {
+		r.dedup = cfg
+	}
+}
+
+func NewJokeRepository(db *DB, opts ...JokeRepositoryOption) *JokeRepository {
+	r := &JokeRepository{db: db}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
+// Create stores a joke unless its exact hash already exists, in which case
+// joke.ID stays zero. A missing hash is computed from the content. With dedup
+// enabled, jokes similar to an existing one are either rejected with a
+// *DuplicateError or stored linked to the original, depending on the
+// configured action.
 func (r *JokeRepository) Create(ctx context.Context, joke *models.Joke) error {
+	if joke.Hash == "" {
+		sum := sha256.Sum256([]byte(joke.Content))
+		joke.Hash = hex.EncodeToString(sum[:])
+	}
+	if joke.NormalizedHash == "" {
+		fp := fingerprint.Compute(joke.Content)
+		joke.NormalizedHash = fp.NormalizedHash
+		joke.SimHash = fp.SimHash
+	}
+
+	if r.dedup.Enabled {
+		// An exact repeat also matches on similarity. It is checked first so
+		// that it ends up like it does without dedup, with joke.ID zero,
+		// rather than as a near-duplicate of itself.
+		exists, err := r.HashExists(ctx, joke.Hash)
+		if err != nil {
+			return fmt.Errorf("failed to look up joke hash: %w", err)
+		}
+		if exists {
+			return nil
+		}
+
+		dup, err := r.findDuplicate(ctx, joke, 0)
+		if err != nil {
+			return fmt.Errorf("failed to look up duplicates: %w", err)
+		}
+		if dup != nil {
+			if r.dedup.Action != config.DedupActionLink {
+				return dup
+			}
+			joke.DuplicateOf = &dup.OriginalID
+		}
+	}
+
+	bands := fingerprint.Bands(joke.SimHash)
 	query := `
-		INSERT INTO jokes (content, source, source_url, hash)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO jokes (content, source, source_url, hash, normalized_hash, simhash,
+			simhash_b0, simhash_b1, simhash_b2, simhash_b3,
+			simhash_b4, simhash_b5, simhash_b6, simhash_b7, duplicate_of, nsfw, author)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
 		ON CONFLICT (hash) DO NOTHING
 		RETURNING id, created_at
 	`
-	err := r.db.Pool.QueryRow(ctx, query, joke.Content, joke.Source, joke.SourceURL, joke.Hash).Scan(&joke.ID, &joke.CreatedAt)
+	err := r.db.Pool.QueryRow(ctx, query,
+		joke.Content, joke.Source, joke.SourceURL, joke.Hash, joke.NormalizedHash, int64(joke.SimHash),
+		bands[0], bands[1], bands[2], bands[3], bands[4], bands[5], bands[6], bands[7],
+		joke.DuplicateOf, joke.NSFW, joke.Author,
+	).Scan(&joke.ID, &joke.CreatedAt)
 	if err == pgx.ErrNoRows {
 		return nil
 	}
 	return err
 }
 
-func (r *JokeRepository) GetRandom(ctx context.Context) (*models.Joke, error) {
+// findDuplicate returns the most similar original joke above the configured
+// threshold, or nil. Candidates are narrowed by normalized hash and SimHash
+// bands, then by Hamming distance so that the limit only cuts off the least
+// similar ones; excludeID skips the joke itself during backfill.
+func (r *JokeRepository) findDuplicate(ctx context.Context, joke *models.Joke, excludeID int64) (*DuplicateError, error) {
+	bands := fingerprint.Bands(joke.SimHash)
+	// The epsilon keeps a threshold like 0.953125 from rounding down a bit.
+	maxDistance := int((1-r.dedup.Threshold)*fingerprint.Bits + 1e-9)
 	query := `
-		UPDATE jokes
-		SET used_count = used_count + 1
-		WHERE id = (
+		SELECT id, simhash, normalized_hash = $1 FROM jokes
+		WHERE duplicate_of IS NULL
+			AND simhash IS NOT NULL
+			AND id <> $2
+			AND (
+				normalized_hash = $1
+				OR ((simhash_b0 = $5 OR simhash_b1 = $6 OR simhash_b2 = $7 OR simhash_b3 = $8
+					OR simhash_b4 = $9 OR simhash_b5 = $10 OR simhash_b6 = $11 OR simhash_b7 = $12)
+					AND bit_count((simhash # $3)::bit(64)) <= $4)
+			)
+		ORDER BY normalized_hash = $1 DESC, bit_count((simhash # $3)::bit(64)), id
+		LIMIT 100
+	`
+	rows, err := r.db.Pool.Query(ctx, query, joke.NormalizedHash, excludeID, int64(joke.SimHash), maxDistance,
+		bands[0], bands[1], bands[2], bands[3], bands[4], bands[5], bands[6], bands[7])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var best *DuplicateError
+	for rows.Next() {
+		var (
+			id       int64
+			simhash  int64
+			sameNorm bool
+		)
+		if err := rows.Scan(&id, &simhash, &sameNorm); err != nil {
+			return nil, err
+		}
+
+		similarity := fingerprint.Similarity(joke.SimHash, uint64(simhash))
+		if sameNorm {
+			similarity = 1
+		}
+		if similarity < r.dedup.Threshold {
+			continue
+		}
+		if best == nil || similarity > best.Similarity || (similarity == best.Similarity && id < best.OriginalID) {
+			best = &DuplicateError{OriginalID: id, Similarity: similarity}
+		}
+	}
+	return best, rows.Err()
+}
+
+// BackfillFingerprints computes fingerprints for up to batchSize jokes that
+// do not have one yet, oldest first, linking near-duplicates to the earlier
+// joke. It returns the number of processed and linked rows.
+func (r *JokeRepository) BackfillFingerprints(ctx context.Context, batchSize int) (int, int, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, content FROM jokes
+		WHERE normalized_hash IS NULL
+		ORDER BY id
+		LIMIT $1
+	`, batchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var jokes []models.Joke
+	for rows.Next() {
+		var joke models.Joke
+		if err := rows.Scan(&joke.ID, &joke.Content); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		jokes = append(jokes, joke)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	linked := 0
+	for i := range jokes {
+		joke := &jokes[i]
+		fp := fingerprint.Compute(joke.Content)
+		joke.NormalizedHash = fp.NormalizedHash
+		joke.SimHash = fp.SimHash
+
+		if r.dedup.Enabled {
+			dup, err := r.findDuplicate(ctx, joke, joke.ID)
+			if err != nil {
+				return i, linked, fmt.Errorf("failed to look up duplicates for joke %d: %w", joke.ID, err)
+			}
+			switch {
+			case dup == nil:
+			case dup.OriginalID < joke.ID:
+				joke.DuplicateOf = &dup.OriginalID
+				linked++
+			default:
+				// The match was inserted after this joke, so the older row
+				// stays the original and the newer one gets linked to it.
+				if _, err := r.db.Pool.Exec(ctx, "UPDATE jokes SET duplicate_of = $1 WHERE id = $2", joke.ID, dup.OriginalID); err != nil {
+					return i, linked, fmt.Errorf("failed to link joke %d: %w", dup.OriginalID, err)
+				}
+				linked++
+			}
+		}
+
+		bands := fingerprint.Bands(joke.SimHash)
+		_, err := r.db.Pool.Exec(ctx, `
+			UPDATE jokes
+			SET normalized_hash = $2, simhash = $3,
+				simhash_b0 = $4, simhash_b1 = $5, simhash_b2 = $6, simhash_b3 = $7,
+				simhash_b4 = $8, simhash_b5 = $9, simhash_b6 = $10, simhash_b7 = $11,
+				duplicate_of = $12
+			WHERE id = $1
+		`, joke.ID, joke.NormalizedHash, int64(joke.SimHash),
+			bands[0], bands[1], bands[2], bands[3], bands[4], bands[5], bands[6], bands[7], joke.DuplicateOf)
+		if err != nil {
+			return i, linked, fmt.Errorf("failed to update joke %d: %w", joke.ID, err)
+		}
+	}
+
+	return len(jokes), linked, nil
+}
+
+// randomJokeQuery picks the first joke at or after a random point on the
+// rand_key index, wrapping around to the start when nothing is found after
+// it. Both branches are index range scans, so selection stays cheap on large
+// tables. The picked row gets a fresh rand_key to even out gaps between keys.
+// Extra filter conditions are spliced into both branches and take their
+// arguments from $2 on.
+const randomJokeQuery = `
+	WITH pick AS (
+		(
 			SELECT id FROM jokes
-			ORDER BY RANDOM()
+			WHERE duplicate_of IS NULL AND rand_key >= $1 %[1]s
+			ORDER BY rand_key
 			LIMIT 1
 		)
-		RETURNING id, content, source, source_url, hash, created_at, used_count
-	`
+		UNION ALL
+		(
+			SELECT id FROM jokes
+			WHERE duplicate_of IS NULL AND rand_key < $1 %[1]s
+			ORDER BY rand_key
+			LIMIT 1
+		)
+		LIMIT 1
+	)
+	UPDATE jokes
+	SET used_count = used_count + 1, rand_key = random()
+	WHERE id = (SELECT id FROM pick)
+	RETURNING ` + jokeColumns + `
+`
+
+const jokeColumns = "id, content, source, source_url, hash, author, created_at, used_count, upvotes, downvotes, rating"
+
+func scanJoke(row pgx.Row) (*models.Joke, error) {
 	var joke models.Joke
-	err := r.db.Pool.QueryRow(ctx, query).Scan(
+	err := row.Scan(
 		&joke.ID, &joke.Content, &joke.Source,
-		&joke.SourceURL, &joke.Hash, &joke.CreatedAt, &joke.UsedCount,
+		&joke.SourceURL, &joke.Hash, &joke.Author, &joke.CreatedAt, &joke.UsedCount,
+		&joke.Upvotes, &joke.Downvotes, &joke.Rating,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -120,22 +350,123 @@ func (r *JokeRepository) GetRandom(ctx context.Context) (*models.Joke, error) {
 	return &joke, nil
 }
 
+func (r *JokeRepository) pickRandom(ctx context.Context, filter string, args ...any) (*models.Joke, error) {
+	query := fmt.Sprintf(randomJokeQuery, filter)
+	return scanJoke(r.db.Pool.QueryRow(ctx, query, append([]any{rand.Float64()}, args...)...))
+}
+
+func (r *JokeRepository) GetRandom(ctx context.Context) (*models.Joke, error) {
+	return r.pickRandom(ctx, "")
+}
+
 func (r *JokeRepository) GetRandomBySource(ctx context.Context, source models.JokeSource) (*models.Joke, error) {
-	query := `
-		UPDATE jokes
-		SET used_count = used_count + 1
-		WHERE id = (
-			SELECT id FROM jokes
-			WHERE source = $1
-			ORDER BY RANDOM()
-			LIMIT 1
+	return r.pickRandom(ctx, "AND source = $2", source)
+}
+
+// JokeFilter narrows random picks for a chat. Empty Sources allows every
+// source. NSFW jokes are only picked when NSFW is set.
+type JokeFilter struct {
+	Sources []string
+	NSFW    bool
+}
+
+// conditions renders the filter for randomJokeQuery, numbering arguments
+// from first.
+func (f JokeFilter) conditions(first int) (string, []any) {
+	var (
+		cond string
+		args []any
+	)
+	if len(f.Sources) > 0 {
+		cond += fmt.Sprintf(" AND source = ANY($%d)", first)
+		args = append(args, f.Sources)
+	}
+	if !f.NSFW {
+		cond += " AND NOT nsfw"
+	}
+	return cond, args
+}
+
+// GetRandomMatching returns a random joke passing the filter.
+func (r *JokeRepository) GetRandomMatching(ctx context.Context, f JokeFilter) (*models.Joke, error) {
+	cond, args := f.conditions(2)
+	return r.pickRandom(ctx, cond, args...)
+}
+
+// GetRandomUnseen returns a random joke passing the filter that the user has
+// not been sent yet. With a positive window, jokes seen longer ago than the
+// window count as unseen again. ErrAllJokesSeen is returned when the user
+// has exhausted the selection.
+func (r *JokeRepository) GetRandomUnseen(ctx context.Context, telegramID int64, f JokeFilter, window time.Duration) (*models.Joke, error) {
+	filter := `
+		AND NOT EXISTS (
+			SELECT 1 FROM user_seen_jokes s
+			WHERE s.telegram_id = $2
+				AND s.joke_id = jokes.id
+				AND ($3::bigint = 0 OR s.seen_at > NOW() - $3::bigint * INTERVAL '1 second')
+		)`
+	args := []any{telegramID, int64(window.Seconds())}
+	cond, condArgs := f.conditions(4)
+
+	joke, err := r.pickRandom(ctx, filter+cond, append(args, condArgs...)...)
+	if errors.Is(err, ErrNoJokesFound) {
+		return nil, ErrAllJokesSeen
+	}
+	return joke, err
+}
+
+// SampleRandom returns up to limit jokes starting at a random point of the
+// rand_key index, without counting them as used. NSFW jokes are left out as
+// the results can end up in any chat.
+func (r *JokeRepository) SampleRandom(ctx context.Context, limit int) ([]models.Joke, error) {
+	query := fmt.Sprintf(`
+		(
+			SELECT %[1]s FROM jokes
+			WHERE duplicate_of IS NULL AND NOT nsfw AND rand_key >= $1
+			ORDER BY rand_key
+			LIMIT $2
 		)
-		RETURNING id, content, source, source_url, hash, created_at, used_count
+		UNION ALL
+		(
+			SELECT %[1]s FROM jokes
+			WHERE duplicate_of IS NULL AND NOT nsfw AND rand_key < $1
+			ORDER BY rand_key
+			LIMIT $2
+		)
+		LIMIT $2
+	`, jokeColumns)
+
+	rows, err := r.db.Pool.Query(ctx, query, rand.Float64(), limit)
+	if err != nil {
+		return nil, err
+	}
+	jokes, _, err := collectJokePage(rows, limit)
+	return jokes, err
+}
+
+// RecordShare stores that a user sent a joke to a chat through inline mode
+// and counts it as used.
+func (r *JokeRepository) RecordShare(ctx context.Context, telegramID, jokeID int64, query string) error {
+	sql := `
+		WITH share AS (
+			INSERT INTO joke_shares (telegram_id, joke_id, query)
+			VALUES ($1, $2, $3)
+		)
+		UPDATE jokes SET used_count = used_count + 1 WHERE id = $2
 	`
+	_, err := r.db.Pool.Exec(ctx, sql, telegramID, jokeID, query)
+	return err
+}
+
+// GetByID returns a joke including its duplicate link and NSFW flag.
+func (r *JokeRepository) GetByID(ctx context.Context, id int64) (*models.Joke, error) {
+	query := "SELECT " + jokeColumns + ", duplicate_of, nsfw FROM jokes WHERE id = $1"
+
 	var joke models.Joke
-	err := r.db.Pool.QueryRow(ctx, query, source).Scan(
+	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
 		&joke.ID, &joke.Content, &joke.Source,
-		&joke.SourceURL, &joke.Hash, &joke.CreatedAt, &joke.UsedCount,
+		&joke.SourceURL, &joke.Hash, &joke.Author, &joke.CreatedAt, &joke.UsedCount,
+		&joke.Upvotes, &joke.Downvotes, &joke.Rating, &joke.DuplicateOf, &joke.NSFW,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -146,6 +477,206 @@ func (r *JokeRepository) GetRandomBySource(ctx context.Context, source models.Jo
 	return &joke, nil
 }
 
+// Delete removes a joke together with its votes, shares and delivery
+// history. Jokes linked to it as duplicates are unlinked.
+func (r *JokeRepository) Delete(ctx context.Context, id int64) error {
+	tag, err := r.db.Pool.Exec(ctx, "DELETE FROM jokes WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoJokesFound
+	}
+	return nil
+}
+
+func (r *JokeRepository) MarkSeen(ctx context.Context, telegramID, jokeID int64) error {
+	query := `
+		INSERT INTO user_seen_jokes (telegram_id, joke_id)
+		VALUES ($1, $2)
+		ON CONFLICT (telegram_id, joke_id) DO UPDATE SET seen_at = CURRENT_TIMESTAMP
+	`
+	_, err := r.db.Pool.Exec(ctx, query, telegramID, jokeID)
+	return err
+}
+
+type TopQuery struct {
+	Since  time.Time
+	Source models.JokeSource
+	Limit  int
+	Offset int
+}
+
+// GetTop returns jokes ranked by rating, leaving out NSFW ones. With Since set, jokes are ranked by
+// the net votes they received since then instead of their all-time rating.
+// The second return value reports whether another page follows.
+func (r *JokeRepository) GetTop(ctx context.Context, q TopQuery) ([]models.Joke, bool, error) {
+	args := []any{q.Limit + 1, q.Offset}
+	sourceFilter := ""
+	if q.Source != "" {
+		args = append(args, q.Source)
+		sourceFilter = fmt.Sprintf("AND j.source = $%d", len(args))
+	}
+
+	var query string
+	if q.Since.IsZero() {
+		query = fmt.Sprintf(`
+			SELECT %s FROM jokes j
+			WHERE j.duplicate_of IS NULL AND NOT j.nsfw %s
+			ORDER BY j.rating DESC, j.used_count DESC, j.id
+			LIMIT $1 OFFSET $2
+		`, prefixColumns("j", jokeColumns), sourceFilter)
+	} else {
+		args = append(args, q.Since)
+		query = fmt.Sprintf(`
+			SELECT %s FROM jokes j
+			JOIN (
+				SELECT joke_id, SUM(value) AS score
+				FROM joke_votes
+				WHERE updated_at >= $%d
+				GROUP BY joke_id
+			) v ON v.joke_id = j.id
+			WHERE j.duplicate_of IS NULL AND NOT j.nsfw AND v.score > 0 %s
+			ORDER BY v.score DESC, j.rating DESC, j.id
+			LIMIT $1 OFFSET $2
+		`, prefixColumns("j", jokeColumns), len(args), sourceFilter)
+	}
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	return collectJokePage(rows, q.Limit)
+}
+
+// collectJokePage reads up to limit jokes from rows queried with LIMIT
+// limit+1 and reports whether the extra row, and so another page, exists.
+func collectJokePage(rows pgx.Rows, limit int) ([]models.Joke, bool, error) {
+	defer rows.Close()
+
+	var jokes []models.Joke
+	for rows.Next() {
+		joke, err := scanJoke(rows)
+		if err != nil {
+			return nil, false, err
+		}
+		jokes = append(jokes, *joke)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(jokes) > limit
+	if hasMore {
+		jokes = jokes[:limit]
+	}
+	return jokes, hasMore, nil
+}
+
+// Search returns jokes matching the words in query, best matches first.
+// The query is stemmed with both the Russian and English configurations and
+// accepts web search syntax ("quoted phrases", -excluded, or). NSFW jokes
+// are left out.
+func (r *JokeRepository) Search(ctx context.Context, query string, limit, offset int) ([]models.Joke, bool, error) {
+	sql := fmt.Sprintf(`
+		WITH q AS (
+			SELECT websearch_to_tsquery('russian', $1) || websearch_to_tsquery('english', $1) AS query
+		)
+		SELECT %s FROM jokes j, q
+		WHERE j.duplicate_of IS NULL AND NOT j.nsfw AND j.search_vector @@ q.query
+		ORDER BY ts_rank(j.search_vector, q.query) DESC, j.rating DESC, j.id
+		LIMIT $2 OFFSET $3
+	`, prefixColumns("j", jokeColumns))
+
+	rows, err := r.db.Pool.Query(ctx, sql, query, limit+1, offset)
+	if err != nil {
+		return nil, false, err
+	}
+	return collectJokePage(rows, limit)
+}
+
+func prefixColumns(alias, columns string) string {
+	cols := strings.Split(columns, ", ")
+	for i, col := range cols {
+		cols[i] = alias + "." + col
+	}
+	return strings.Join(cols, ", ")
+}
+
+// Vote records a user's vote on a joke and returns the updated counters.
+// Voting the same way twice withdraws the vote, voting the other way
+// changes it.
+func (r *JokeRepository) Vote(ctx context.Context, telegramID, jokeID int64, value int) (*models.Vote, error) {
+	if value != 1 && value != -1 {
+		return nil, fmt.Errorf("invalid vote value %d", value)
+	}
+
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the joke so concurrent votes on it apply their deltas in order.
+	var exists bool
+	err = tx.QueryRow(ctx, "SELECT true FROM jokes WHERE id = $1 FOR UPDATE", jokeID).Scan(&exists)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoJokesFound
+		}
+		return nil, err
+	}
+
+	var prev int
+	err = tx.QueryRow(ctx, "SELECT value FROM joke_votes WHERE telegram_id = $1 AND joke_id = $2", telegramID, jokeID).Scan(&prev)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	next := value
+	if prev == value {
+		next = 0
+	}
+
+	if next == 0 {
+		_, err = tx.Exec(ctx, "DELETE FROM joke_votes WHERE telegram_id = $1 AND joke_id = $2", telegramID, jokeID)
+	} else {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO joke_votes (telegram_id, joke_id, value)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (telegram_id, joke_id) DO UPDATE SET
+				value = EXCLUDED.value,
+				updated_at = CURRENT_TIMESTAMP
+		`, telegramID, jokeID, next)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vote := &models.Vote{JokeID: jokeID, Value: next}
+	err = tx.QueryRow(ctx, `
+		UPDATE jokes
+		SET upvotes = upvotes + $2, downvotes = downvotes + $3
+		WHERE id = $1
+		RETURNING upvotes, downvotes
+	`, jokeID, countOf(next, 1)-countOf(prev, 1), countOf(next, -1)-countOf(prev, -1)).Scan(&vote.Upvotes, &vote.Downvotes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return vote, nil
+}
+
+func countOf(vote, value int) int {
+	if vote == value {
+		return 1
+	}
+	return 0
+}
+
 func (r *JokeRepository) Count(ctx context.Context) (int, error) {
 	var count int
 	err := r.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM jokes").Scan(&count)
@@ -180,12 +711,79 @@ func (r *UserRepository) Upsert(ctx context.Context, user *models.User) error {
 			username = EXCLUDED.username,
 			first_name = EXCLUDED.first_name,
 			last_name = EXCLUDED.last_name,
-			last_interaction = CURRENT_TIMESTAMP
-		RETURNING id, created_at
+			last_interaction = CURRENT_TIMESTAMP,
+			is_active = TRUE,
+			blocked_at = NULL
+		RETURNING id, created_at, is_active
 	`
 	return r.db.Pool.QueryRow(ctx, query,
 		user.TelegramID, user.Username, user.FirstName, user.LastName,
-	).Scan(&user.ID, &user.CreatedAt)
+	).Scan(&user.ID, &user.CreatedAt, &user.IsActive)
+}
+
+// MarkInactive records that the bot can no longer message the user. The
+// next Upsert, when the user talks to the bot again, reactivates them.
+func (r *UserRepository) MarkInactive(ctx context.Context, telegramID int64, at time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE users
+		SET is_active = FALSE, blocked_at = $2
+		WHERE telegram_id = $1 AND is_active
+	`, telegramID, at)
+	return err
+}
+
+// FindByUsername looks a user up by Telegram username, without the @ and
+// ignoring case.
+func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	query := `
+		SELECT id, telegram_id, COALESCE(username, ''), COALESCE(first_name, ''), COALESCE(last_name, ''),
+			created_at, last_interaction, is_active, blocked_at
+		FROM users
+		WHERE LOWER(username) = LOWER($1)
+		ORDER BY last_interaction DESC
+		LIMIT 1
+	`
+	var user models.User
+	err := r.db.Pool.QueryRow(ctx, query, username).Scan(
+		&user.ID, &user.TelegramID, &user.Username, &user.FirstName, &user.LastName,
+		&user.CreatedAt, &user.LastInteraction, &user.IsActive, &user.BlockedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) Ban(ctx context.Context, telegramID, bannedBy int64, reason string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO user_bans (telegram_id, reason, banned_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (telegram_id) DO UPDATE SET
+			reason = EXCLUDED.reason,
+			banned_by = EXCLUDED.banned_by,
+			created_at = CURRENT_TIMESTAMP
+	`, telegramID, reason, bannedBy)
+	return err
+}
+
+// Unban lifts a ban and reports whether the user was banned.
+func (r *UserRepository) Unban(ctx context.Context, telegramID int64) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, "DELETE FROM user_bans WHERE telegram_id = $1", telegramID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *UserRepository) IsBanned(ctx context.Context, telegramID int64) (bool, error) {
+	var banned bool
+	err := r.db.Pool.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM user_bans WHERE telegram_id = $1)", telegramID,
+	).Scan(&banned)
+	return banned, err
 }
 
 func (r *UserRepository) Count(ctx context.Context) (int, error) {
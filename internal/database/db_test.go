@@ -2,6 +2,7 @@ package database
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"anek-bot/internal/models"
@@ -99,3 +100,19 @@ func TestJokeSourceConstants(t *testing.T) {
 		t.Errorf("SourceAnekdot = %v, want anekdot", models.SourceAnekdot)
 	}
 }
+
+func TestDuplicateError(t *testing.T) {
+	var err error = &DuplicateError{OriginalID: 42, Similarity: 0.95}
+
+	if !errors.Is(err, ErrDuplicateJoke) {
+		t.Error("DuplicateError should match ErrDuplicateJoke")
+	}
+
+	var dupErr *DuplicateError
+	if !errors.As(fmt.Errorf("wrapped: %w", err), &dupErr) {
+		t.Fatal("Expected wrapped error to unwrap to *DuplicateError")
+	}
+	if dupErr.OriginalID != 42 {
+		t.Errorf("OriginalID = %v, want 42", dupErr.OriginalID)
+	}
+}
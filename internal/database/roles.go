@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+
+	"anek-bot/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type RoleRepository struct {
+	db *DB
+}
+
+func NewRoleRepository(db *DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+func (r *RoleRepository) Has(ctx context.Context, telegramID int64, role models.Role) (bool, error) {
+	var exists bool
+	err := r.db.Pool.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM roles WHERE telegram_id = $1 AND role = $2)",
+		telegramID, role,
+	).Scan(&exists)
+	return exists, err
+}
+
+func (r *RoleRepository) Grant(ctx context.Context, telegramID int64, role models.Role, grantedBy int64) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO roles (telegram_id, role, granted_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (telegram_id, role) DO NOTHING
+	`, telegramID, role, grantedBy)
+	return err
+}
+
+// Revoke removes a role and reports whether the user had it.
+func (r *RoleRepository) Revoke(ctx context.Context, telegramID int64, role models.Role) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, "DELETE FROM roles WHERE telegram_id = $1 AND role = $2", telegramID, role)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *RoleRepository) List(ctx context.Context, role models.Role) ([]int64, error) {
+	rows, err := r.db.Pool.Query(ctx, "SELECT telegram_id FROM roles WHERE role = $1 ORDER BY created_at", role)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
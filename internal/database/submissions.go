@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"anek-bot/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrSubmissionNotFound = errors.New("submission not found")
+	ErrAlreadyReviewed    = errors.New("submission was already reviewed")
+)
+
+const submissionColumns = "id, telegram_id, author, content, status, joke_id, reviewed_by, reviewed_at, created_at"
+
+type SubmissionRepository struct {
+	db *DB
+}
+
+func NewSubmissionRepository(db *DB) *SubmissionRepository {
+	return &SubmissionRepository{db: db}
+}
+
+func scanSubmission(row pgx.Row) (*models.Submission, error) {
+	var s models.Submission
+	err := row.Scan(
+		&s.ID, &s.TelegramID, &s.Author, &s.Content, &s.Status,
+		&s.JokeID, &s.ReviewedBy, &s.ReviewedAt, &s.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSubmissionNotFound
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *SubmissionRepository) Create(ctx context.Context, s *models.Submission) error {
+	query := `
+		INSERT INTO submissions (telegram_id, author, content)
+		VALUES ($1, $2, $3)
+		RETURNING id, status, created_at
+	`
+	return r.db.Pool.QueryRow(ctx, query, s.TelegramID, s.Author, s.Content).
+		Scan(&s.ID, &s.Status, &s.CreatedAt)
+}
+
+func (r *SubmissionRepository) Get(ctx context.Context, id int64) (*models.Submission, error) {
+	query := "SELECT " + submissionColumns + " FROM submissions WHERE id = $1"
+	return scanSubmission(r.db.Pool.QueryRow(ctx, query, id))
+}
+
+func (r *SubmissionRepository) CountPending(ctx context.Context, telegramID int64) (int, error) {
+	var count int
+	err := r.db.Pool.QueryRow(ctx,
+		"SELECT COUNT(*) FROM submissions WHERE telegram_id = $1 AND status = 'pending'",
+		telegramID,
+	).Scan(&count)
+	return count, err
+}
+
+// Review moves a pending submission to status. Only the first of several
+// concurrent reviews wins, the others get ErrAlreadyReviewed.
+func (r *SubmissionRepository) Review(ctx context.Context, id int64, status models.SubmissionStatus, reviewerID int64) (*models.Submission, error) {
+	query := `
+		UPDATE submissions
+		SET status = $2, reviewed_by = $3, reviewed_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'pending'
+		RETURNING ` + submissionColumns
+	s, err := scanSubmission(r.db.Pool.QueryRow(ctx, query, id, status, reviewerID))
+	if errors.Is(err, ErrSubmissionNotFound) {
+		if _, getErr := r.Get(ctx, id); getErr == nil {
+			return nil, ErrAlreadyReviewed
+		}
+	}
+	return s, err
+}
+
+// Reopen puts a reviewed submission back into the pending state, for when
+// acting on the review failed.
+func (r *SubmissionRepository) Reopen(ctx context.Context, id int64) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE submissions
+		SET status = 'pending', reviewed_by = NULL, reviewed_at = NULL
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+func (r *SubmissionRepository) SetJoke(ctx context.Context, id, jokeID int64) error {
+	_, err := r.db.Pool.Exec(ctx, "UPDATE submissions SET joke_id = $2 WHERE id = $1", id, jokeID)
+	return err
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"anek-bot/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+const subscriptionColumns = "telegram_id, hour, minute, timezone, next_run_at, last_sent_at, created_at"
+
+type SubscriptionRepository struct {
+	db *DB
+}
+
+func NewSubscriptionRepository(db *DB) *SubscriptionRepository {
+	return &SubscriptionRepository{db: db}
+}
+
+func scanSubscription(row pgx.Row) (*models.Subscription, error) {
+	var sub models.Subscription
+	err := row.Scan(
+		&sub.TelegramID, &sub.Hour, &sub.Minute, &sub.Timezone,
+		&sub.NextRunAt, &sub.LastSentAt, &sub.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// Upsert creates or replaces the user's subscription. NextRunAt must already
+// be computed by the caller.
+func (r *SubscriptionRepository) Upsert(ctx context.Context, sub *models.Subscription) error {
+	query := `
+		INSERT INTO subscriptions (telegram_id, hour, minute, timezone, next_run_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (telegram_id) DO UPDATE SET
+			hour = EXCLUDED.hour,
+			minute = EXCLUDED.minute,
+			timezone = EXCLUDED.timezone,
+			next_run_at = EXCLUDED.next_run_at,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING created_at
+	`
+	return r.db.Pool.QueryRow(ctx, query,
+		sub.TelegramID, sub.Hour, sub.Minute, sub.Timezone, sub.NextRunAt,
+	).Scan(&sub.CreatedAt)
+}
+
+func (r *SubscriptionRepository) Get(ctx context.Context, telegramID int64) (*models.Subscription, error) {
+	query := "SELECT " + subscriptionColumns + " FROM subscriptions WHERE telegram_id = $1"
+	sub, err := scanSubscription(r.db.Pool.QueryRow(ctx, query, telegramID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSubscriptionNotFound
+	}
+	return sub, err
+}
+
+func (r *SubscriptionRepository) Delete(ctx context.Context, telegramID int64) error {
+	tag, err := r.db.Pool.Exec(ctx, "DELETE FROM subscriptions WHERE telegram_id = $1", telegramID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// ClaimDue locks one subscription due at now and passes it to deliver. The
+// row stays locked until deliver returns, and other replicas skip it, so a
+// subscription is never handed out twice for the same run.
+//
+// deliver must set sub.NextRunAt to the next run, or to a retry time when it
+// fails. The new time is stored either way, last_sent_at only on success.
+// It reports false when nothing is due.
+func (r *SubscriptionRepository) ClaimDue(ctx context.Context, now time.Time, deliver func(sub *models.Subscription) error) (bool, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := "SELECT " + subscriptionColumns + `
+		FROM subscriptions
+		WHERE next_run_at <= $1
+		ORDER BY next_run_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`
+	sub, err := scanSubscription(tx.QueryRow(ctx, query, now))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	prevRun := sub.NextRunAt
+	deliverErr := deliver(sub)
+	if !sub.NextRunAt.After(prevRun) {
+		return true, fmt.Errorf("subscription %d was not rescheduled", sub.TelegramID)
+	}
+
+	var sentAt *time.Time
+	if deliverErr == nil {
+		sentAt = &now
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE subscriptions
+		SET next_run_at = $2, last_sent_at = COALESCE($3, last_sent_at)
+		WHERE telegram_id = $1
+	`, sub.TelegramID, sub.NextRunAt, sentAt)
+	if err != nil {
+		return true, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return true, err
+	}
+	return true, deliverErr
+}
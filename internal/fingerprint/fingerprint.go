@@ -0,0 +1,116 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math/bits"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	Bits      = 64
+	BandCount = 8
+	bandBits  = Bits / BandCount
+)
+
+// MinSimilarity is the lowest similarity at which Bands is sure to find a
+// candidate: hashes up to BandCount-1 bits apart. A lower dedup threshold
+// would let near-duplicates further apart slip through.
+const MinSimilarity = 1 - float64(BandCount-1)/Bits
+
+// editPattern matches Reddit-style trailers such as "Edit: thanks for the
+// gold" and everything after them.
+var editPattern = regexp.MustCompile(`(?is)(^|\s)(edit|eta|upd|update)\s*\d*\s*:.*$`)
+
+type Fingerprint struct {
+	NormalizedHash string
+	SimHash        uint64
+}
+
+func Compute(content string) Fingerprint {
+	normalized := Normalize(content)
+	sum := sha256.Sum256([]byte(normalized))
+	return Fingerprint{
+		NormalizedHash: hex.EncodeToString(sum[:]),
+		SimHash:        SimHash(normalized),
+	}
+}
+
+// Normalize reduces content to lowercase words separated by single spaces so
+// that whitespace, punctuation, quote style and edit trailers do not affect
+// comparison.
+func Normalize(content string) string {
+	content = editPattern.ReplaceAllString(content, "")
+	content = strings.ToLower(content)
+	content = strings.ReplaceAll(content, "ё", "е")
+
+	content = strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return ' '
+		}
+		return r
+	}, content)
+
+	return strings.Join(strings.Fields(content), " ")
+}
+
+// SimHash returns a 64-bit similarity hash over word bigrams of already
+// normalized text. Similar texts produce hashes with a small Hamming distance.
+func SimHash(normalized string) uint64 {
+	words := strings.Fields(normalized)
+	if len(words) == 0 {
+		return 0
+	}
+
+	features := words
+	if len(words) > 1 {
+		features = make([]string, 0, len(words)-1)
+		for i := 0; i < len(words)-1; i++ {
+			features = append(features, words[i]+" "+words[i+1])
+		}
+	}
+
+	var weights [Bits]int
+	for _, f := range features {
+		h := fnv.New64a()
+		h.Write([]byte(f))
+		sum := h.Sum64()
+		for i := 0; i < Bits; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+
+	var hash uint64
+	for i := 0; i < Bits; i++ {
+		if weights[i] > 0 {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func Similarity(a, b uint64) float64 {
+	return 1 - float64(Distance(a, b))/Bits
+}
+
+// Bands splits a hash into BandCount equal chunks. Two hashes within
+// BandCount-1 bits of each other always share at least one band, which makes
+// bands usable as an index for candidate lookup.
+func Bands(hash uint64) [BandCount]int32 {
+	var bands [BandCount]int32
+	for i := 0; i < BandCount; i++ {
+		bands[i] = int32((hash >> uint(i*bandBits)) & (1<<bandBits - 1))
+	}
+	return bands
+}
@@ -0,0 +1,113 @@
+package fingerprint
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"whitespace", "  Why   did\tthe\nchicken  ", "why did the chicken"},
+		{"punctuation", "Why did the chicken cross the road?!", "why did the chicken cross the road"},
+		{"quotes", `He said «hello» and “bye”`, "he said hello and bye"},
+		{"yo", "Ёжик и ёлка", "ежик и елка"},
+		{"edit trailer", "Punchline here.\n\nEdit: thanks for the gold!", "punchline here"},
+		{"numbered edit", "Punchline here. EDIT 2: wow, front page", "punchline here"},
+		{"editor is kept", "The editor said no", "the editor said no"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Normalize(tt.input)
+			if got != tt.expected {
+				t.Errorf("Normalize() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestComputeIgnoresFormatting(t *testing.T) {
+	a := Compute("A man walks into a bar. \"Ouch!\" he says.")
+	b := Compute("a man walks into a bar 'ouch' he says\n\nEdit: thanks for the gold")
+
+	if a.NormalizedHash != b.NormalizedHash {
+		t.Errorf("Expected equal normalized hashes")
+	}
+	if a.SimHash != b.SimHash {
+		t.Errorf("Expected equal simhashes")
+	}
+}
+
+func TestSimHashSimilarity(t *testing.T) {
+	base := Normalize("Встречаются два программиста. Один говорит другому: у меня код работает, а я не знаю почему. Второй отвечает: а у меня не работает, и я тоже не знаю почему.")
+	similar := Normalize("Встречаются два программиста. Один говорит другому: у меня код работает, а я не понимаю почему. Второй отвечает: а у меня не работает, и я тоже не знаю почему.")
+	different := Normalize("Why did the scarecrow win an award? Because he was outstanding in his field.")
+
+	simSimilar := Similarity(SimHash(base), SimHash(similar))
+	simDifferent := Similarity(SimHash(base), SimHash(different))
+
+	if simSimilar <= simDifferent {
+		t.Errorf("Expected similar texts to score higher: similar=%v different=%v", simSimilar, simDifferent)
+	}
+	if simSimilar < 0.8 {
+		t.Errorf("Similarity of near-duplicates = %v, want >= 0.8", simSimilar)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	if d := Distance(0, 0); d != 0 {
+		t.Errorf("Distance(0, 0) = %d, want 0", d)
+	}
+	if d := Distance(0, ^uint64(0)); d != 64 {
+		t.Errorf("Distance(0, max) = %d, want 64", d)
+	}
+	if s := Similarity(0b1011, 0b0011); s != 1-1.0/64 {
+		t.Errorf("Similarity = %v", s)
+	}
+}
+
+func TestBands(t *testing.T) {
+	hash := uint64(0x1234_5678_9abc_def0)
+	bands := Bands(hash)
+	expected := [BandCount]int32{0xf0, 0xde, 0xbc, 0x9a, 0x78, 0x56, 0x34, 0x12}
+	if bands != expected {
+		t.Errorf("Bands() = %x, want %x", bands, expected)
+	}
+
+	tests := []struct {
+		name string
+		flip uint64
+	}{
+		{"3 bits in one band", 0b111},
+		// Every 16-bit band differs here, so the old four bands missed it.
+		{"5 bits across all 16-bit bands", 1 | 1<<1 | 1<<16 | 1<<32 | 1<<48},
+		{"7 bits in different bands", 1 | 1<<9 | 1<<18 | 1<<27 | 1<<36 | 1<<45 | 1<<54},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			near := hash ^ tt.flip
+			if Similarity(hash, near) < MinSimilarity {
+				t.Fatalf("Similarity = %v, below MinSimilarity", Similarity(hash, near))
+			}
+
+			shared := false
+			for i, b := range Bands(near) {
+				if b == bands[i] {
+					shared = true
+				}
+			}
+			if !shared {
+				t.Errorf("hashes %d bits apart share no band", Distance(hash, near))
+			}
+		})
+	}
+}
+
+func TestMinSimilarityCoversDefaultThreshold(t *testing.T) {
+	// The default dedup threshold, 0.9, accepts hashes 6 bits apart.
+	if MinSimilarity > 0.9 {
+		t.Errorf("MinSimilarity = %v, above the default threshold", MinSimilarity)
+	}
+}
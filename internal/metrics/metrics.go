@@ -0,0 +1,46 @@
+// Package metrics holds the process counters published through expvar.
+package metrics
+
+import "expvar"
+
+// Layers that drop duplicate jokes, from the first to the last one a joke
+// passes.
+const (
+	// LayerParser is the parser's cache of hashes it already published.
+	LayerParser = "parser"
+	// LayerQueue is the JetStream duplicate window on the joke hash.
+	LayerQueue = "queue"
+	// LayerDatabase is the unique joke hash in the database.
+	LayerDatabase = "database"
+	// LayerSimilarity is the near-duplicate check on save.
+	LayerSimilarity = "similarity"
+)
+
+var layers = []string{LayerParser, LayerQueue, LayerDatabase, LayerSimilarity}
+
+var duplicates = expvar.NewMap("duplicates_dropped")
+
+// DuplicateDropped counts a joke dropped as a duplicate by layer.
+func DuplicateDropped(layer string) {
+	duplicates.Add(layer, 1)
+}
+
+// LayerCount is the number of duplicates one layer dropped.
+type LayerCount struct {
+	Layer string
+	Count int64
+}
+
+// DuplicatesDropped returns the duplicates dropped since start by every
+// layer, in the order a joke passes them.
+func DuplicatesDropped() []LayerCount {
+	counts := make([]LayerCount, 0, len(layers))
+	for _, layer := range layers {
+		count := LayerCount{Layer: layer}
+		if v, ok := duplicates.Get(layer).(*expvar.Int); ok {
+			count.Count = v.Value()
+		}
+		counts = append(counts, count)
+	}
+	return counts
+}
@@ -0,0 +1,22 @@
+package metrics
+
+import "testing"
+
+func TestDuplicatesDropped(t *testing.T) {
+	before := DuplicatesDropped()
+	DuplicateDropped(LayerDatabase)
+	DuplicateDropped(LayerDatabase)
+	DuplicateDropped(LayerParser)
+
+	got := DuplicatesDropped()
+	wantLayers := []string{LayerParser, LayerQueue, LayerDatabase, LayerSimilarity}
+	wantAdded := []int64{1, 0, 2, 0}
+	if len(got) != len(wantLayers) {
+		t.Fatalf("DuplicatesDropped() = %v, want every layer", got)
+	}
+	for i, layer := range wantLayers {
+		if got[i].Layer != layer || got[i].Count-before[i].Count != wantAdded[i] {
+			t.Errorf("DuplicatesDropped()[%d] = %v, want %s up by %d", i, got[i], layer, wantAdded[i])
+		}
+	}
+}
@@ -3,13 +3,28 @@ package models
 import "time"
 
 type Joke struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Source    string    `json:"source"`
-	SourceURL string    `json:"source_url"`
-	Hash      string    `json:"hash"`
-	CreatedAt time.Time `json:"created_at"`
-	UsedCount int       `json:"used_count"`
+	ID             int64     `json:"id"`
+	Content        string    `json:"content"`
+	Source         string    `json:"source"`
+	SourceURL      string    `json:"source_url"`
+	Hash           string    `json:"hash"`
+	NormalizedHash string    `json:"normalized_hash,omitempty"`
+	SimHash        uint64    `json:"simhash,omitempty"`
+	DuplicateOf    *int64    `json:"duplicate_of,omitempty"`
+	NSFW           bool      `json:"nsfw,omitempty"`
+	Author         string    `json:"author,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UsedCount      int       `json:"used_count"`
+	Upvotes        int       `json:"upvotes"`
+	Downvotes      int       `json:"downvotes"`
+	Rating         int       `json:"rating"`
+}
+
+type Vote struct {
+	JokeID    int64 `json:"joke_id"`
+	Value     int   `json:"value"`
+	Upvotes   int   `json:"upvotes"`
+	Downvotes int   `json:"downvotes"`
 }
 
 type User struct {
@@ -20,6 +35,106 @@ type User struct {
 	LastName        string    `json:"last_name"`
 	CreatedAt       time.Time `json:"created_at"`
 	LastInteraction time.Time `json:"last_interaction"`
+	// IsActive is false once the user blocked the bot or deleted their
+	// account, since BlockedAt.
+	IsActive  bool       `json:"is_active"`
+	BlockedAt *time.Time `json:"blocked_at,omitempty"`
+}
+
+type Subscription struct {
+	TelegramID int64      `json:"telegram_id"`
+	Hour       int        `json:"hour"`
+	Minute     int        `json:"minute"`
+	Timezone   string     `json:"timezone"`
+	NextRunAt  time.Time  `json:"next_run_at"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Chat holds per-chat settings. Sources lists the joke sources allowed in the
+// chat, empty meaning all of them.
+type Chat struct {
+	ChatID    int64     `json:"chat_id"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Sources   []string  `json:"sources"`
+	NSFW      bool      `json:"nsfw"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Role string
+
+const RoleAdmin Role = "admin"
+
+type SubmissionStatus string
+
+const (
+	SubmissionPending  SubmissionStatus = "pending"
+	SubmissionApproved SubmissionStatus = "approved"
+	SubmissionRejected SubmissionStatus = "rejected"
+)
+
+// Submission is a joke proposed by a user. Approved submissions are copied
+// into jokes and linked through JokeID.
+type Submission struct {
+	ID         int64            `json:"id"`
+	TelegramID int64            `json:"telegram_id"`
+	Author     string           `json:"author"`
+	Content    string           `json:"content"`
+	Status     SubmissionStatus `json:"status"`
+	JokeID     *int64           `json:"joke_id,omitempty"`
+	ReviewedBy *int64           `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time       `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+type BroadcastStatus string
+
+const (
+	BroadcastDraft     BroadcastStatus = "draft"
+	BroadcastRunning   BroadcastStatus = "running"
+	BroadcastFinished  BroadcastStatus = "finished"
+	BroadcastCancelled BroadcastStatus = "cancelled"
+)
+
+// Broadcast is a message from an admin to every user. Recipients are fixed
+// when it starts, ChatID is where progress reports go.
+type Broadcast struct {
+	ID         int64           `json:"id"`
+	AdminID    int64           `json:"admin_id"`
+	ChatID     int64           `json:"chat_id"`
+	Text       string          `json:"text"`
+	Status     BroadcastStatus `json:"status"`
+	Total      int             `json:"total"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending DeliveryStatus = "pending"
+	DeliveryQueued  DeliveryStatus = "queued"
+	DeliverySent    DeliveryStatus = "sent"
+	DeliveryBlocked DeliveryStatus = "blocked"
+	DeliveryFailed  DeliveryStatus = "failed"
+)
+
+// BroadcastProgress counts the recipients of a broadcast by delivery status.
+type BroadcastProgress struct {
+	Total   int `json:"total"`
+	Pending int `json:"pending"`
+	Queued  int `json:"queued"`
+	Sent    int `json:"sent"`
+	Blocked int `json:"blocked"`
+	Failed  int `json:"failed"`
+}
+
+// Done reports whether every recipient has a final delivery status.
+func (p BroadcastProgress) Done() bool {
+	return p.Pending == 0 && p.Queued == 0
 }
 
 type JokeSource string
@@ -27,4 +142,6 @@ type JokeSource string
 const (
 	SourceReddit  JokeSource = "reddit"
 	SourceAnekdot JokeSource = "anekdot"
+	SourceUser    JokeSource = "user"
+	SourceManual  JokeSource = "manual"
 )
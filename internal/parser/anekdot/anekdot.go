@@ -0,0 +1,37 @@
+package anekdot
+
+import (
+	"net/http"
+
+	"anek-bot/internal/config"
+	"anek-bot/internal/parser"
+	"anek-bot/internal/parser/scraper"
+)
+
+const (
+	Type         = "anekdot"
+	defaultURL   = "https://anekdot.ru/random/anekdot/"
+	itemSelector = "div.text"
+	defaultLimit = 20
+	userAgent    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+)
+
+func init() {
+	parser.Register(Type, New)
+}
+
+// New returns an HTML scraper preconfigured for anekdot.ru. Any selector or
+// URL set in the config overrides the built-in one.
+func New(cfg config.SourceConfig, client *http.Client) (parser.Source, error) {
+	if len(cfg.AllURLs()) == 0 {
+		cfg.URL = defaultURL
+	}
+	if cfg.Selectors.Item == "" {
+		cfg.Selectors.Item = itemSelector
+	}
+	if cfg.Limit <= 0 {
+		cfg.Limit = defaultLimit
+	}
+
+	return scraper.NewSource(cfg, client, scraper.WithUserAgent(userAgent))
+}
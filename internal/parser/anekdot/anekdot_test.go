@@ -0,0 +1,63 @@
+package anekdot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"anek-bot/internal/config"
+)
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.UserAgent() != userAgent {
+			t.Errorf("User-Agent = %q, want %q", r.UserAgent(), userAgent)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><body>
+<div class="text">Short</div>
+<div class="text">Встречаются два &quot;программиста&quot;...</div>
+<div class="text">Второй <b>анекдот</b> тоже подходит</div>
+<div class="text">Третий анекдот уже не влезает в лимит</div>
+</body></html>`))
+	}))
+	defer srv.Close()
+
+	src, err := New(config.SourceConfig{Type: Type, URL: srv.URL, Limit: 2}, srv.Client())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	jokes, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(jokes) != 2 {
+		t.Fatalf("Expected 2 jokes, got %d", len(jokes))
+	}
+	if jokes[0].Content != `Встречаются два "программиста"...` {
+		t.Errorf("Content = %q", jokes[0].Content)
+	}
+	if jokes[1].Content != "Второй анекдот тоже подходит" {
+		t.Errorf("Content = %q", jokes[1].Content)
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	src, err := New(config.SourceConfig{Name: "anekdot", Type: Type}, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	cfg := src.Config()
+	if cfg.URL != defaultURL {
+		t.Errorf("URL = %q, want %q", cfg.URL, defaultURL)
+	}
+	if cfg.Selectors.Item != itemSelector {
+		t.Errorf("Item selector = %q, want %q", cfg.Selectors.Item, itemSelector)
+	}
+	if cfg.Limit != defaultLimit {
+		t.Errorf("Limit = %d, want %d", cfg.Limit, defaultLimit)
+	}
+}
@@ -0,0 +1,248 @@
+package feed
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"anek-bot/internal/config"
+	"anek-bot/internal/parser"
+	"anek-bot/internal/queue"
+	"anek-bot/pkg/logger"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	Type          = "feed"
+	defaultLimit  = 20
+	minContentLen = 10
+	maxContentLen = 3000
+	maxFeedSize   = 10 << 20
+)
+
+var ErrUnsupportedFeed = errors.New("unsupported feed format")
+
+func init() {
+	parser.Register(Type, New)
+}
+
+type rssFeed struct {
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	Description string `xml:"description"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title   string     `xml:"title"`
+	ID      string     `xml:"id"`
+	Links   []atomLink `xml:"link"`
+	Summary atomText   `xml:"summary"`
+	Content atomText   `xml:"content"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t atomText) HTML() string {
+	if t.Type == "xhtml" {
+		return t.Inner
+	}
+	return t.Text
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type item struct {
+	Title string
+	Body  string
+	Link  string
+}
+
+type Source struct {
+	cfg    config.SourceConfig
+	client *http.Client
+}
+
+func New(cfg config.SourceConfig, client *http.Client) (parser.Source, error) {
+	if len(cfg.AllURLs()) == 0 {
+		return nil, errors.New("at least one feed url is required")
+	}
+	if cfg.Limit <= 0 {
+		cfg.Limit = defaultLimit
+	}
+
+	return &Source{cfg: cfg, client: client}, nil
+}
+
+func (s *Source) Name() string {
+	return s.cfg.SourceName()
+}
+
+func (s *Source) Config() config.SourceConfig {
+	return s.cfg
+}
+
+func (s *Source) Fetch(ctx context.Context) ([]*queue.JokeMessage, error) {
+	var (
+		jokes []*queue.JokeMessage
+		errs  []error
+	)
+
+	for _, url := range s.cfg.AllURLs() {
+		items, err := s.fetchFeed(ctx, url)
+		if err != nil {
+			logger.Error("Failed to fetch feed", logger.String("url", url), logger.Err(err))
+			errs = append(errs, fmt.Errorf("feed %s: %w", url, err))
+			continue
+		}
+
+		jokes = append(jokes, s.jokesFromItems(items)...)
+	}
+
+	return jokes, errors.Join(errs...)
+}
+
+func (s *Source) fetchFeed(ctx context.Context, url string) ([]item, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "anek-bot/1.0")
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed returned status %d", resp.StatusCode)
+	}
+
+	return parseFeed(io.LimitReader(resp.Body, maxFeedSize))
+}
+
+func (s *Source) jokesFromItems(items []item) []*queue.JokeMessage {
+	var jokes []*queue.JokeMessage
+	for _, it := range items {
+		if len(jokes) >= s.cfg.Limit {
+			break
+		}
+
+		content := parser.HTMLToText(it.Body)
+		if content == "" {
+			content = parser.HTMLToText(it.Title)
+		}
+		if len(content) < minContentLen || len(content) > maxContentLen {
+			continue
+		}
+
+		jokes = append(jokes, &queue.JokeMessage{
+			Content:   content,
+			SourceURL: it.Link,
+		})
+	}
+	return jokes
+}
+
+func parseFeed(r io.Reader) ([]item, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, ErrUnsupportedFeed
+			}
+			return nil, fmt.Errorf("failed to parse feed: %w", err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch strings.ToLower(start.Name.Local) {
+		case "rss":
+			var feed rssFeed
+			if err := dec.DecodeElement(&feed, &start); err != nil {
+				return nil, fmt.Errorf("failed to decode rss: %w", err)
+			}
+			return rssItems(feed), nil
+		case "feed":
+			var feed atomFeed
+			if err := dec.DecodeElement(&feed, &start); err != nil {
+				return nil, fmt.Errorf("failed to decode atom: %w", err)
+			}
+			return atomItems(feed), nil
+		default:
+			return nil, fmt.Errorf("%w: root element <%s>", ErrUnsupportedFeed, start.Name.Local)
+		}
+	}
+}
+
+func rssItems(feed rssFeed) []item {
+	items := make([]item, 0, len(feed.Channel.Items))
+	for _, it := range feed.Channel.Items {
+		body := it.Content
+		if strings.TrimSpace(body) == "" {
+			body = it.Description
+		}
+		link := strings.TrimSpace(it.Link)
+		if link == "" {
+			link = strings.TrimSpace(it.GUID)
+		}
+		items = append(items, item{Title: it.Title, Body: body, Link: link})
+	}
+	return items
+}
+
+func atomItems(feed atomFeed) []item {
+	items := make([]item, 0, len(feed.Entries))
+	for _, e := range feed.Entries {
+		body := e.Content.HTML()
+		if strings.TrimSpace(body) == "" {
+			body = e.Summary.HTML()
+		}
+		items = append(items, item{Title: e.Title, Body: body, Link: atomLinkHref(e)})
+	}
+	return items
+}
+
+func atomLinkHref(e atomEntry) string {
+	for _, l := range e.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return strings.TrimSpace(l.Href)
+		}
+	}
+	if len(e.Links) > 0 {
+		return strings.TrimSpace(e.Links[0].Href)
+	}
+	return strings.TrimSpace(e.ID)
+}
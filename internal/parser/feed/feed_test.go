@@ -0,0 +1,128 @@
+package feed
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"anek-bot/internal/config"
+	"anek-bot/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error", io.Discard)
+	os.Exit(m.Run())
+}
+
+func newFixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchRSS(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	src, err := New(config.SourceConfig{Name: "jokes-rss", Type: Type, URLs: []string{srv.URL + "/rss.xml"}}, srv.Client())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	jokes, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(jokes) != 2 {
+		t.Fatalf("Expected 2 jokes, got %d", len(jokes))
+	}
+
+	tests := []struct {
+		content string
+		link    string
+	}{
+		{"Why do programmers prefer dark mode?\n\nBecause light attracts bugs.", "https://example.com/jokes/1"},
+		{"Колобок повесился.\nШтирлиц «долго смеялся» & думал.", "https://example.com/jokes/2"},
+	}
+	for i, tt := range tests {
+		if jokes[i].Content != tt.content {
+			t.Errorf("jokes[%d].Content = %q, want %q", i, jokes[i].Content, tt.content)
+		}
+		if jokes[i].SourceURL != tt.link {
+			t.Errorf("jokes[%d].SourceURL = %q, want %q", i, jokes[i].SourceURL, tt.link)
+		}
+	}
+}
+
+func TestFetchAtom(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	src, err := New(config.SourceConfig{Type: Type, URL: srv.URL + "/atom.xml"}, srv.Client())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	jokes, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(jokes) != 2 {
+		t.Fatalf("Expected 2 jokes, got %d", len(jokes))
+	}
+	if jokes[0].Content != "A SQL query walks into a bar & asks two tables: can I join you?" {
+		t.Errorf("Content = %q", jokes[0].Content)
+	}
+	if jokes[0].SourceURL != "https://example.org/posts/1" {
+		t.Errorf("SourceURL = %q", jokes[0].SourceURL)
+	}
+	if jokes[1].Content != "There are 10 kinds of people." {
+		t.Errorf("Content = %q", jokes[1].Content)
+	}
+	if jokes[1].SourceURL != "https://example.org/posts/2" {
+		t.Errorf("SourceURL = %q", jokes[1].SourceURL)
+	}
+}
+
+func TestFetchMultipleFeeds(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	src, err := New(config.SourceConfig{
+		Type:  Type,
+		Limit: 1,
+		URLs: []string{
+			srv.URL + "/rss.xml",
+			srv.URL + "/missing.xml",
+			srv.URL + "/rss-cp1251.xml",
+		},
+	}, srv.Client())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	jokes, err := src.Fetch(context.Background())
+	if err == nil {
+		t.Error("Expected error for missing feed")
+	}
+	if len(jokes) != 2 {
+		t.Fatalf("Expected 2 jokes, got %d", len(jokes))
+	}
+	if jokes[1].Content != "Встречаются два программиста в баре..." {
+		t.Errorf("Content = %q", jokes[1].Content)
+	}
+}
+
+func TestParseFeedUnsupported(t *testing.T) {
+	if _, err := parseFeed(strings.NewReader("<html><body>nope</body></html>")); err == nil {
+		t.Error("Expected error for non-feed document")
+	}
+}
+
+func TestNewRequiresURLs(t *testing.T) {
+	if _, err := New(config.SourceConfig{Type: Type}, http.DefaultClient); err == nil {
+		t.Error("Expected error when no urls are configured")
+	}
+}
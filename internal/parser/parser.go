@@ -4,15 +4,12 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"regexp"
-	"strings"
 	"time"
 
 	"anek-bot/internal/config"
+	"anek-bot/internal/metrics"
 	"anek-bot/internal/models"
 	"anek-bot/internal/queue"
 	"anek-bot/pkg/logger"
@@ -23,12 +20,15 @@ type Queue interface {
 }
 
 type Parser struct {
-	cfg    config.ParserConfig
-	client *http.Client
-	q      Queue
+	cfg     config.ParserConfig
+	client  *http.Client
+	q       Queue
+	sources []Source
+	// seen is nil when the seen-hash cache is off.
+	seen *seenCache
 }
 
-func New(cfg config.ParserConfig, q Queue, opts ...Option) *Parser {
+func New(cfg config.ParserConfig, q Queue, opts ...Option) (*Parser, error) {
 	p := &Parser{
 		cfg: cfg,
 		q:   q,
@@ -37,11 +37,26 @@ func New(cfg config.ParserConfig, q Queue, opts ...Option) *Parser {
 		},
 	}
 
+	if cfg.SeenCache > 0 {
+		p.seen = newSeenCache(cfg.SeenCache, cfg.SeenTTL)
+	}
+
 	for _, opt := range opts {
 		opt(p)
 	}
 
-	return p
+	for _, sc := range cfg.Sources {
+		if !sc.Enabled {
+			continue
+		}
+		src, err := NewSource(sc, p.client)
+		if err != nil {
+			return nil, err
+		}
+		p.sources = append(p.sources, src)
+	}
+
+	return p, nil
 }
 
 type Option func(*Parser)
@@ -52,17 +67,23 @@ func WithHTTPClient(client *http.Client) Option {
 	}
 }
 
-type RedditPost struct {
-	Data struct {
-		Children []struct {
-			Data struct {
-				Title     string `json:"title"`
-				Selftext  string `json:"selftext"`
-				Permalink string `json:"permalink"`
-				URL       string `json:"url"`
-			} `json:"data"`
-		} `json:"children"`
-	} `json:"data"`
+func WithSources(sources ...Source) Option {
+	return func(p *Parser) {
+		p.sources = append(p.sources, sources...)
+	}
+}
+
+type Result struct {
+	Source    string
+	Fetched   int
+	Published int
+	// Skipped counts the jokes the seen-hash cache kept off the queue.
+	Skipped int
+	Err     error
+}
+
+func (p *Parser) Sources() []Source {
+	return p.sources
 }
 
 func (p *Parser) Start(ctx context.Context) error {
@@ -70,11 +91,8 @@ func (p *Parser) Start(ctx context.Context) error {
 		return nil
 	}
 
-	logger.Info("Running initial parse...")
-	if err := p.ParseAll(ctx); err != nil {
-		logger.Error("Initial parse failed", logger.Err(err))
-		return fmt.Errorf("initial parse failed: %w", err)
-	}
+	logger.Info("Running initial parse...", logger.Int("sources", len(p.sources)))
+	p.ParseAll(ctx)
 	logger.Info("Initial parse completed")
 
 	ticker := time.NewTicker(p.cfg.IntervalMins)
@@ -85,143 +103,74 @@ func (p *Parser) Start(ctx context.Context) error {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-ticker.C:
-			if err := p.ParseAll(ctx); err != nil {
-				return fmt.Errorf("parse failed: %w", err)
-			}
+			p.ParseAll(ctx)
 		}
 	}
 }
 
-func (p *Parser) ParseAll(ctx context.Context) error {
-	if p.cfg.Sources.Reddit.Enabled {
-		if err := p.parseReddit(ctx); err != nil {
-			return fmt.Errorf("reddit parsing failed: %w", err)
-		}
+func (p *Parser) ParseAll(ctx context.Context) []Result {
+	results := make([]Result, 0, len(p.sources))
+	for _, src := range p.sources {
+		results = append(results, p.runSource(ctx, src))
 	}
-
-	if p.cfg.Sources.Anekdot.Enabled {
-		if err := p.parseAnekdot(ctx); err != nil {
-			return fmt.Errorf("anekdot parsing failed: %w", err)
-		}
-	}
-
-	return nil
+	return results
 }
 
-func (p *Parser) parseReddit(ctx context.Context) error {
-	limit := p.cfg.Sources.Reddit.Limit
-
-	for _, subreddit := range p.cfg.Sources.Reddit.Subreddits {
-		logger.Info("Parsing subreddit", logger.String("subreddit", subreddit))
-		url := fmt.Sprintf("https://www.reddit.com/r/%s/hot.json?limit=%d", subreddit, limit)
-
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			logger.Error("Failed to create request", logger.Err(err))
-			return err
-		}
-		req.Header.Set("User-Agent", "anek-bot/1.0")
-
-		resp, err := p.client.Do(req)
-		if err != nil {
-			logger.Error("Failed to fetch subreddit", logger.String("subreddit", subreddit), logger.Err(err))
-			return err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			logger.Warn("Non-OK status from Reddit", logger.String("subreddit", subreddit), logger.Int("status", resp.StatusCode))
-			continue
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-
-		var posts RedditPost
-		if err := json.Unmarshal(body, &posts); err != nil {
-			return err
-		}
+// Reparse runs one source by name right away, or every source when name is
+// empty. Sources disabled in the config cannot be run.
+func (p *Parser) Reparse(ctx context.Context, name string) ([]Result, error) {
+	if name == "" {
+		return p.ParseAll(ctx), nil
+	}
 
-		for _, child := range posts.Data.Children {
-			post := child.Data
-			content := post.Selftext
-			if content == "" {
-				continue
-			}
-			if len(content) > 3000 {
-				content = content[:3000]
-			}
-
-			joke := &queue.JokeMessage{
-				Content:   content,
-				Source:    models.SourceReddit,
-				SourceURL: "https://reddit.com" + post.Permalink,
-				Hash:      generateHash(content),
-			}
-
-			if err := p.q.PublishJoke(ctx, joke); err != nil {
-				logger.Error("Failed to publish joke to queue",
-					logger.Err(err),
-					logger.String("source", string(joke.Source)),
-				)
-				continue
-			}
-			logger.Info("Published joke to queue", logger.String("source", string(joke.Source)), logger.String("hash", joke.Hash))
+	for _, src := range p.sources {
+		if src.Name() == name {
+			return []Result{p.runSource(ctx, src)}, nil
 		}
 	}
-
-	return nil
+	return nil, fmt.Errorf("%w: %q", ErrUnknownSource, name)
 }
 
-func (p *Parser) parseAnekdot(ctx context.Context) error {
-	url := "https://anekdot.ru/random/anekdot/"
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return err
+func (p *Parser) runSource(ctx context.Context, src Source) Result {
+	res := p.parseSource(ctx, src)
+	if res.Err != nil {
+		logger.Error("Source parsing failed",
+			logger.String("source", res.Source),
+			logger.Err(res.Err),
+		)
+	} else {
+		logger.Info("Source parsed",
+			logger.String("source", res.Source),
+			logger.Int("fetched", res.Fetched),
+			logger.Int("published", res.Published),
+			logger.Int("skipped", res.Skipped),
+		)
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-
-	resp, err := p.client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+	return res
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("anekdot.ru returned status %d", resp.StatusCode)
-	}
+func (p *Parser) parseSource(ctx context.Context, src Source) Result {
+	res := Result{Source: src.Name()}
 
-	body, err := io.ReadAll(resp.Body)
+	jokes, err := src.Fetch(ctx)
+	res.Fetched = len(jokes)
 	if err != nil {
-		return err
+		res.Err = fmt.Errorf("%s parsing failed: %w", src.Name(), err)
 	}
 
-	anekdotPattern := regexp.MustCompile(`<div class="text">([\s\S]*?)</div>`)
-	matches := anekdotPattern.FindAllStringSubmatch(string(body), -1)
-
-	limit := p.cfg.Sources.Anekdot.Limit
-	count := 0
-
-	for _, match := range matches {
-		if count >= limit {
-			break
+	for _, joke := range jokes {
+		// Jokes are filtered by the kind of site they came from, so two
+		// instances of the same type share a source.
+		if joke.Source == "" {
+			joke.Source = models.JokeSource(src.Config().Type)
 		}
-
-		content := cleanHTML(match[1])
-		content = strings.TrimSpace(content)
-
-		if len(content) < 10 || len(content) > 3000 {
-			continue
+		if joke.Hash == "" {
+			joke.Hash = generateHash(joke.Content)
 		}
-
-		joke := &queue.JokeMessage{
-			Content:   content,
-			Source:    models.SourceAnekdot,
-			SourceURL: "https://anekdot.ru",
-			Hash:      generateHash(content),
+		if p.seen != nil && p.seen.seen(joke.Hash) {
+			metrics.DuplicateDropped(metrics.LayerParser)
+			res.Skipped++
+			continue
 		}
 
 		if err := p.q.PublishJoke(ctx, joke); err != nil {
@@ -231,24 +180,17 @@ func (p *Parser) parseAnekdot(ctx context.Context) error {
 			)
 			continue
 		}
-		count++
+		if p.seen != nil {
+			p.seen.add(joke.Hash)
+		}
+		res.Published++
+		logger.Debug("Published joke to queue", logger.String("source", string(joke.Source)), logger.String("hash", joke.Hash))
 	}
 
-	return nil
+	return res
 }
 
 func generateHash(content string) string {
 	hash := sha256.Sum256([]byte(content))
 	return hex.EncodeToString(hash[:])
 }
-
-func cleanHTML(text string) string {
-	re := regexp.MustCompile(`<[^>]*>`)
-	text = re.ReplaceAllString(text, "")
-	text = strings.ReplaceAll(text, "&nbsp;", " ")
-	text = strings.ReplaceAll(text, "&quot;", "\"")
-	text = strings.ReplaceAll(text, "&amp;", "&")
-	text = strings.ReplaceAll(text, "&lt;", "<")
-	text = strings.ReplaceAll(text, "&gt;", ">")
-	return text
-}
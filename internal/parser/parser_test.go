@@ -1,11 +1,24 @@
 package parser
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
 	"testing"
 
+	"anek-bot/internal/config"
 	"anek-bot/internal/models"
+	"anek-bot/internal/queue"
+	"anek-bot/pkg/logger"
 )
 
+func TestMain(m *testing.M) {
+	logger.Init("error", io.Discard)
+	os.Exit(m.Run())
+}
+
 func TestGenerateHash(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -28,64 +41,6 @@ func TestGenerateHash(t *testing.T) {
 	}
 }
 
-func TestCleanHTML(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "simple tags",
-			input:    "<p>Hello</p>",
-			expected: "Hello",
-		},
-		{
-			name:     "complex tags",
-			input:    "<div class=\"text\"><b>Bold</b> and <i>italic</i></div>",
-			expected: "Bold and italic",
-		},
-		{
-			name:     "nbsp entity",
-			input:    "Hello&nbsp;World",
-			expected: "Hello World",
-		},
-		{
-			name:     "quot entity",
-			input:    "&quot;quoted&quot;",
-			expected: "\"quoted\"",
-		},
-		{
-			name:     "amp entity",
-			input:    "A &amp; B",
-			expected: "A & B",
-		},
-		{
-			name:     "lt gt entities",
-			input:    "&lt;div&gt;",
-			expected: "<div>",
-		},
-		{
-			name:     "no tags",
-			input:    "Just plain text",
-			expected: "Just plain text",
-		},
-		{
-			name:     "mixed content",
-			input:    "<div class=\"text\">Привет &amp; мир!</div>",
-			expected: "Привет & мир!",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := cleanHTML(tt.input)
-			if got != tt.expected {
-				t.Errorf("cleanHTML() = %q, want %q", got, tt.expected)
-			}
-		})
-	}
-}
-
 func TestJokeSource(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -104,3 +59,129 @@ func TestJokeSource(t *testing.T) {
 		})
 	}
 }
+
+type fakeSource struct {
+	name  string
+	jokes []*queue.JokeMessage
+	err   error
+}
+
+func (f *fakeSource) Name() string { return f.name }
+
+func (f *fakeSource) Config() config.SourceConfig {
+	return config.SourceConfig{Name: f.name, Type: "fake", Enabled: true}
+}
+
+func (f *fakeSource) Fetch(ctx context.Context) ([]*queue.JokeMessage, error) {
+	return f.jokes, f.err
+}
+
+type recordingQueue struct {
+	jokes []*queue.JokeMessage
+}
+
+func (r *recordingQueue) PublishJoke(ctx context.Context, joke *queue.JokeMessage) error {
+	r.jokes = append(r.jokes, joke)
+	return nil
+}
+
+func TestParseAllReportsSourcesIndependently(t *testing.T) {
+	broken := &fakeSource{name: "broken", err: errors.New("site is down")}
+	working := &fakeSource{name: "working", jokes: []*queue.JokeMessage{
+		{Content: "first joke"},
+		{Content: "second joke", Source: "custom"},
+	}}
+
+	q := &recordingQueue{}
+	p, err := New(config.ParserConfig{Enabled: true}, q, WithSources(broken, working))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	results := p.ParseAll(context.Background())
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	if results[0].Source != "broken" || results[0].Err == nil {
+		t.Errorf("Expected error result for broken source, got %+v", results[0])
+	}
+	if results[1].Source != "working" || results[1].Err != nil || results[1].Published != 2 {
+		t.Errorf("Unexpected result for working source: %+v", results[1])
+	}
+
+	if len(q.jokes) != 2 {
+		t.Fatalf("Expected 2 published jokes, got %d", len(q.jokes))
+	}
+	if q.jokes[0].Source != "fake" {
+		t.Errorf("Source = %v, want the source type fake", q.jokes[0].Source)
+	}
+	if q.jokes[1].Source != "custom" {
+		t.Errorf("Source = %v, want custom", q.jokes[1].Source)
+	}
+	if q.jokes[0].Hash != generateHash("first joke") {
+		t.Errorf("Hash was not filled in")
+	}
+}
+
+func TestReparse(t *testing.T) {
+	first := &fakeSource{name: "first", jokes: []*queue.JokeMessage{{Content: "one"}}}
+	second := &fakeSource{name: "second", jokes: []*queue.JokeMessage{{Content: "two"}, {Content: "three"}}}
+
+	q := &recordingQueue{}
+	p, err := New(config.ParserConfig{Enabled: true}, q, WithSources(first, second))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	results, err := p.Reparse(context.Background(), "second")
+	if err != nil {
+		t.Fatalf("Reparse() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Source != "second" || results[0].Published != 2 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+
+	results, err = p.Reparse(context.Background(), "")
+	if err != nil || len(results) != 2 {
+		t.Errorf("Reparse(\"\") = %+v, %v, want both sources", results, err)
+	}
+
+	if _, err := p.Reparse(context.Background(), "missing"); !errors.Is(err, ErrUnknownSource) {
+		t.Errorf("Expected ErrUnknownSource, got %v", err)
+	}
+}
+
+func TestNewSourceUnknownType(t *testing.T) {
+	_, err := NewSource(config.SourceConfig{Name: "mystery", Type: "mystery"}, nil)
+	if !errors.Is(err, ErrUnknownSourceType) {
+		t.Errorf("Expected ErrUnknownSourceType, got %v", err)
+	}
+}
+
+func TestRegisterAndNewSource(t *testing.T) {
+	Register("fake-test", func(cfg config.SourceConfig, client *http.Client) (Source, error) {
+		return &fakeSource{name: cfg.SourceName()}, nil
+	})
+
+	src, err := NewSource(config.SourceConfig{Type: "fake-test"}, nil)
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+	if src.Name() != "fake-test" {
+		t.Errorf("Name() = %q, want fake-test", src.Name())
+	}
+
+	p, err := New(config.ParserConfig{
+		Sources: []config.SourceConfig{
+			{Name: "enabled", Type: "fake-test", Enabled: true},
+			{Name: "disabled", Type: "fake-test", Enabled: false},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if len(p.Sources()) != 1 || p.Sources()[0].Name() != "enabled" {
+		t.Errorf("Expected only the enabled source, got %d sources", len(p.Sources()))
+	}
+}
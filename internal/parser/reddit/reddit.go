@@ -0,0 +1,143 @@
+package reddit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"anek-bot/internal/config"
+	"anek-bot/internal/parser"
+	"anek-bot/internal/queue"
+	"anek-bot/pkg/logger"
+)
+
+const (
+	Type           = "reddit"
+	defaultBaseURL = "https://www.reddit.com"
+	defaultLimit   = 25
+	maxContentLen  = 3000
+)
+
+func init() {
+	parser.Register(Type, New)
+}
+
+type RedditPost struct {
+	Data struct {
+		Children []struct {
+			Data struct {
+				Title     string `json:"title"`
+				Selftext  string `json:"selftext"`
+				Permalink string `json:"permalink"`
+				URL       string `json:"url"`
+				Over18    bool   `json:"over_18"`
+			} `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
+type Source struct {
+	cfg    config.SourceConfig
+	client *http.Client
+}
+
+func New(cfg config.SourceConfig, client *http.Client) (parser.Source, error) {
+	if len(cfg.Subreddits) == 0 {
+		return nil, errors.New("at least one subreddit is required")
+	}
+	if cfg.URL == "" {
+		cfg.URL = defaultBaseURL
+	}
+	if cfg.Limit <= 0 {
+		cfg.Limit = defaultLimit
+	}
+
+	return &Source{cfg: cfg, client: client}, nil
+}
+
+func (s *Source) Name() string {
+	return s.cfg.SourceName()
+}
+
+func (s *Source) Config() config.SourceConfig {
+	return s.cfg
+}
+
+func (s *Source) Fetch(ctx context.Context) ([]*queue.JokeMessage, error) {
+	var (
+		jokes []*queue.JokeMessage
+		errs  []error
+	)
+
+	for _, subreddit := range s.cfg.Subreddits {
+		logger.Info("Parsing subreddit", logger.String("subreddit", subreddit))
+
+		posts, err := s.fetchSubreddit(ctx, subreddit)
+		if err != nil {
+			logger.Error("Failed to fetch subreddit", logger.String("subreddit", subreddit), logger.Err(err))
+			errs = append(errs, fmt.Errorf("subreddit %s: %w", subreddit, err))
+			continue
+		}
+
+		jokes = append(jokes, s.jokesFromPosts(posts)...)
+	}
+
+	return jokes, errors.Join(errs...)
+}
+
+func (s *Source) fetchSubreddit(ctx context.Context, subreddit string) (*RedditPost, error) {
+	url := fmt.Sprintf("%s/r/%s/hot.json?limit=%d", strings.TrimRight(s.cfg.URL, "/"), subreddit, s.cfg.Limit)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "anek-bot/1.0")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("reddit returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts RedditPost
+	if err := json.Unmarshal(body, &posts); err != nil {
+		return nil, err
+	}
+
+	return &posts, nil
+}
+
+func (s *Source) jokesFromPosts(posts *RedditPost) []*queue.JokeMessage {
+	var jokes []*queue.JokeMessage
+	for _, child := range posts.Data.Children {
+		post := child.Data
+		content := post.Selftext
+		if content == "" {
+			continue
+		}
+		if len(content) > maxContentLen {
+			content = content[:maxContentLen]
+		}
+
+		jokes = append(jokes, &queue.JokeMessage{
+			Content:   content,
+			SourceURL: "https://reddit.com" + post.Permalink,
+			NSFW:      post.Over18,
+		})
+	}
+	return jokes
+}
@@ -0,0 +1,109 @@
+package reddit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"anek-bot/internal/config"
+	"anek-bot/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error", io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestRedditPostParsing(t *testing.T) {
+	jsonData := `{
+		"data": {
+			"children": [
+				{
+					"data": {
+						"title": "Why did the chicken?",
+						"selftext": "To get to the other side!",
+						"permalink": "/r/Jokes/comments/abc123",
+						"url": "https://reddit.com"
+					}
+				},
+				{
+					"data": {
+						"title": "Funny joke",
+						"selftext": "This is a test joke content",
+						"permalink": "/r/Jokes/comments/def456",
+						"url": "https://reddit.com"
+					}
+				}
+			]
+		}
+	}`
+
+	var posts RedditPost
+	err := json.Unmarshal([]byte(jsonData), &posts)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	if len(posts.Data.Children) != 2 {
+		t.Errorf("Expected 2 children, got %d", len(posts.Data.Children))
+	}
+
+	if posts.Data.Children[0].Data.Selftext != "To get to the other side!" {
+		t.Errorf("Expected 'To get to the other side!', got '%s'", posts.Data.Children[0].Data.Selftext)
+	}
+
+	if posts.Data.Children[0].Data.Permalink != "/r/Jokes/comments/abc123" {
+		t.Errorf("Expected '/r/Jokes/comments/abc123', got '%s'", posts.Data.Children[0].Data.Permalink)
+	}
+}
+
+func TestFetchSubreddits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/r/broken/hot.json" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"children":[
+			{"data":{"selftext":"To get to the other side!","permalink":"/r/Jokes/comments/abc123"}},
+			{"data":{"selftext":"","permalink":"/r/Jokes/comments/empty"}},
+			{"data":{"selftext":"Spicy one","permalink":"/r/Jokes/comments/nsfw","over_18":true}}
+		]}}`))
+	}))
+	defer srv.Close()
+
+	src, err := New(config.SourceConfig{
+		Type:       Type,
+		URL:        srv.URL,
+		Subreddits: []string{"Jokes", "broken"},
+	}, srv.Client())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	jokes, err := src.Fetch(context.Background())
+	if err == nil {
+		t.Error("Expected error for broken subreddit")
+	}
+	if len(jokes) != 2 {
+		t.Fatalf("Expected 2 jokes, got %d", len(jokes))
+	}
+	if jokes[0].SourceURL != "https://reddit.com/r/Jokes/comments/abc123" {
+		t.Errorf("SourceURL = %q", jokes[0].SourceURL)
+	}
+	if jokes[0].NSFW || !jokes[1].NSFW {
+		t.Errorf("NSFW = %v, %v, want false, true", jokes[0].NSFW, jokes[1].NSFW)
+	}
+	if src.Name() != "reddit" {
+		t.Errorf("Name() = %q, want reddit", src.Name())
+	}
+}
+
+func TestNewRequiresSubreddits(t *testing.T) {
+	if _, err := New(config.SourceConfig{Type: Type}, http.DefaultClient); err == nil {
+		t.Error("Expected error when no subreddits are configured")
+	}
+}
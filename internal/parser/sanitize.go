@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var blockElements = map[atom.Atom]bool{
+	atom.P:          true,
+	atom.Div:        true,
+	atom.Li:         true,
+	atom.Blockquote: true,
+	atom.Pre:        true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Tr:         true,
+}
+
+var droppedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Template: true,
+	atom.Head:     true,
+}
+
+// HTMLToText strips markup from an HTML fragment and returns its plain text.
+// Entities are decoded, scripts and styles dropped, and <br> and block
+// elements become line breaks.
+func HTMLToText(fragment string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, n := range nodes {
+		writeText(&sb, n)
+	}
+	return normalizeText(sb.String())
+}
+
+// TextContent returns the plain text of an already parsed node, using the
+// same rules as HTMLToText.
+func TextContent(n *html.Node) string {
+	var sb strings.Builder
+	writeText(&sb, n)
+	return normalizeText(sb.String())
+}
+
+func writeText(sb *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		if droppedElements[n.DataAtom] {
+			return
+		}
+		if n.DataAtom == atom.Br {
+			sb.WriteByte('\n')
+			return
+		}
+	case html.CommentNode:
+		return
+	}
+
+	block := n.Type == html.ElementNode && blockElements[n.DataAtom]
+	if block {
+		sb.WriteByte('\n')
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeText(sb, c)
+	}
+	if block {
+		sb.WriteByte('\n')
+	}
+}
+
+// normalizeText collapses runs of whitespace, trims lines and keeps at most
+// one blank line between paragraphs.
+func normalizeText(text string) string {
+	lines := strings.Split(text, "\n")
+
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			if len(out) > 0 {
+				blank = true
+			}
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,31 @@
+package parser
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain text", "Just plain text", "Just plain text"},
+		{"inline tags", `<b>Bold</b> and <i>italic</i>`, "Bold and italic"},
+		{"entities", "&quot;quoted&quot; &amp; &lt;tag&gt;&nbsp;end", `"quoted" & <tag> end`},
+		{"line breaks", "first<br>second<br/><br/>third", "first\nsecond\n\nthird"},
+		{"paragraphs", "<p>one</p><p>two</p>", "one\n\ntwo"},
+		{"script and style dropped", `<style>p{}</style>ok<script>alert("x")</script>`, "ok"},
+		{"comments dropped", "a<!-- hidden -->b", "ab"},
+		{"whitespace collapsed", "  lots \t of\n   space  ", "lots of\nspace"},
+		{"unicode", `<div class="text">Привет &amp; мир!</div>`, "Привет & мир!"},
+		{"unclosed tags", "<p>broken <b>markup", "broken markup"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HTMLToText(tt.input)
+			if got != tt.expected {
+				t.Errorf("HTMLToText() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"anek-bot/internal/config"
+	"anek-bot/internal/parser"
+	"anek-bot/internal/queue"
+	"anek-bot/pkg/logger"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	Type            = "html"
+	defaultLimit    = 20
+	defaultMaxPages = 1
+	minContentLen   = 10
+	maxContentLen   = 3000
+	maxPageSize     = 10 << 20
+)
+
+func init() {
+	parser.Register(Type, New)
+}
+
+type selectors struct {
+	item cascadia.Selector
+	text cascadia.Selector
+	link cascadia.Selector
+	next cascadia.Selector
+}
+
+type Source struct {
+	cfg       config.SourceConfig
+	client    *http.Client
+	selectors selectors
+	userAgent string
+}
+
+type Option func(*Source)
+
+func WithUserAgent(userAgent string) Option {
+	return func(s *Source) {
+		s.userAgent = userAgent
+	}
+}
+
+func New(cfg config.SourceConfig, client *http.Client) (parser.Source, error) {
+	return NewSource(cfg, client)
+}
+
+func NewSource(cfg config.SourceConfig, client *http.Client, opts ...Option) (*Source, error) {
+	if len(cfg.AllURLs()) == 0 {
+		return nil, errors.New("at least one url is required")
+	}
+	if cfg.Selectors.Item == "" {
+		return nil, errors.New("item selector is required")
+	}
+	if cfg.Limit <= 0 {
+		cfg.Limit = defaultLimit
+	}
+	if cfg.MaxPages <= 0 {
+		cfg.MaxPages = defaultMaxPages
+	}
+	if cfg.Encoding != "" {
+		if enc, _ := charset.Lookup(cfg.Encoding); enc == nil {
+			return nil, fmt.Errorf("unknown encoding %q", cfg.Encoding)
+		}
+	}
+
+	s := &Source{
+		cfg:       cfg,
+		client:    client,
+		userAgent: "anek-bot/1.0",
+	}
+
+	var err error
+	if s.selectors.item, err = compile("item", cfg.Selectors.Item); err != nil {
+		return nil, err
+	}
+	if s.selectors.text, err = compile("text", cfg.Selectors.Text); err != nil {
+		return nil, err
+	}
+	if s.selectors.link, err = compile("link", cfg.Selectors.Link); err != nil {
+		return nil, err
+	}
+	if s.selectors.next, err = compile("next", cfg.Selectors.Next); err != nil {
+		return nil, err
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+func compile(name, selector string) (cascadia.Selector, error) {
+	if selector == "" {
+		return nil, nil
+	}
+	sel, err := cascadia.Compile(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s selector %q: %w", name, selector, err)
+	}
+	return sel, nil
+}
+
+func (s *Source) Name() string {
+	return s.cfg.SourceName()
+}
+
+func (s *Source) Config() config.SourceConfig {
+	return s.cfg
+}
+
+func (s *Source) Fetch(ctx context.Context) ([]*queue.JokeMessage, error) {
+	var (
+		jokes []*queue.JokeMessage
+		errs  []error
+	)
+
+	for _, startURL := range s.cfg.AllURLs() {
+		found, err := s.crawl(ctx, startURL)
+		jokes = append(jokes, found...)
+		if err != nil {
+			logger.Error("Failed to scrape page", logger.String("url", startURL), logger.Err(err))
+			errs = append(errs, fmt.Errorf("page %s: %w", startURL, err))
+		}
+	}
+
+	return jokes, errors.Join(errs...)
+}
+
+func (s *Source) crawl(ctx context.Context, startURL string) ([]*queue.JokeMessage, error) {
+	var jokes []*queue.JokeMessage
+	visited := make(map[string]bool)
+
+	pageURL := startURL
+	for page := 0; page < s.cfg.MaxPages && pageURL != "" && !visited[pageURL]; page++ {
+		visited[pageURL] = true
+
+		doc, base, err := s.fetchDocument(ctx, pageURL)
+		if err != nil {
+			return jokes, err
+		}
+
+		for _, joke := range s.extract(doc, base) {
+			if len(jokes) >= s.cfg.Limit {
+				return jokes, nil
+			}
+			jokes = append(jokes, joke)
+		}
+
+		pageURL = s.nextPage(doc, base)
+	}
+
+	return jokes, nil
+}
+
+func (s *Source) fetchDocument(ctx context.Context, pageURL string) (*html.Node, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("site returned status %d", resp.StatusCode)
+	}
+
+	body, err := s.decode(io.LimitReader(resp.Body, maxPageSize), resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode page: %w", err)
+	}
+
+	doc, err := html.Parse(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse page: %w", err)
+	}
+
+	return doc, resp.Request.URL, nil
+}
+
+func (s *Source) decode(r io.Reader, contentType string) (io.Reader, error) {
+	if s.cfg.Encoding != "" {
+		return charset.NewReaderLabel(s.cfg.Encoding, r)
+	}
+	return charset.NewReader(r, contentType)
+}
+
+func (s *Source) extract(doc *html.Node, base *url.URL) []*queue.JokeMessage {
+	var jokes []*queue.JokeMessage
+	for _, item := range s.selectors.item.MatchAll(doc) {
+		textNode := item
+		if s.selectors.text != nil {
+			if textNode = s.selectors.text.MatchFirst(item); textNode == nil {
+				continue
+			}
+		}
+
+		content := parser.TextContent(textNode)
+		if len(content) < minContentLen || len(content) > maxContentLen {
+			continue
+		}
+
+		link := base.String()
+		if s.selectors.link != nil {
+			if href := resolveHref(s.selectors.link.MatchFirst(item), base); href != "" {
+				link = href
+			}
+		}
+
+		jokes = append(jokes, &queue.JokeMessage{
+			Content:   content,
+			SourceURL: link,
+		})
+	}
+	return jokes
+}
+
+func (s *Source) nextPage(doc *html.Node, base *url.URL) string {
+	if s.selectors.next == nil {
+		return ""
+	}
+	return resolveHref(s.selectors.next.MatchFirst(doc), base)
+}
+
+func resolveHref(n *html.Node, base *url.URL) string {
+	if n == nil {
+		return ""
+	}
+	for _, attr := range n.Attr {
+		if attr.Key != "href" {
+			continue
+		}
+		ref, err := url.Parse(strings.TrimSpace(attr.Val))
+		if err != nil {
+			return ""
+		}
+		return base.ResolveReference(ref).String()
+	}
+	return ""
+}
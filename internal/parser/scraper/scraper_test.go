@@ -0,0 +1,168 @@
+package scraper
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"anek-bot/internal/config"
+	"anek-bot/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error", io.Discard)
+	os.Exit(m.Run())
+}
+
+func newFixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func quoteConfig(url string) config.SourceConfig {
+	return config.SourceConfig{
+		Name: "bash-mirror",
+		Type: Type,
+		URL:  url,
+		Selectors: config.SelectorsConfig{
+			Item: "div.quote",
+			Text: "div.body",
+			Link: "a.permalink",
+			Next: "a.next",
+		},
+	}
+}
+
+func TestFetchSinglePage(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	src, err := NewSource(quoteConfig(srv.URL+"/page1.html"), srv.Client())
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+
+	jokes, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(jokes) != 2 {
+		t.Fatalf("Expected 2 jokes, got %d", len(jokes))
+	}
+
+	if jokes[0].Content != "<Guest> how do I exit vim?\n<Op> you don't" {
+		t.Errorf("Content = %q", jokes[0].Content)
+	}
+	if jokes[0].SourceURL != srv.URL+"/quote/101" {
+		t.Errorf("SourceURL = %q", jokes[0].SourceURL)
+	}
+	if jokes[1].SourceURL != srv.URL+"/page1.html" {
+		t.Errorf("Expected page URL as fallback link, got %q", jokes[1].SourceURL)
+	}
+}
+
+func TestFetchPagination(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	cfg := quoteConfig(srv.URL + "/page1.html")
+	cfg.MaxPages = 5
+
+	src, err := NewSource(cfg, srv.Client())
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+
+	jokes, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(jokes) != 3 {
+		t.Fatalf("Expected 3 jokes across two pages, got %d", len(jokes))
+	}
+	if jokes[2].SourceURL != "https://mirror.example.com/quote/201" {
+		t.Errorf("SourceURL = %q", jokes[2].SourceURL)
+	}
+}
+
+func TestFetchLimit(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	cfg := quoteConfig(srv.URL + "/page1.html")
+	cfg.MaxPages = 5
+	cfg.Limit = 1
+
+	src, err := NewSource(cfg, srv.Client())
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+
+	jokes, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(jokes) != 1 {
+		t.Errorf("Expected 1 joke, got %d", len(jokes))
+	}
+}
+
+func TestFetchEncoding(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	src, err := NewSource(config.SourceConfig{
+		Type:      Type,
+		URL:       srv.URL + "/cp1251.html",
+		Encoding:  "windows-1251",
+		Selectors: config.SelectorsConfig{Item: ".topicbox", Text: ".text"},
+	}, srv.Client())
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+
+	jokes, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(jokes) != 1 {
+		t.Fatalf("Expected 1 joke, got %d", len(jokes))
+	}
+	if jokes[0].Content != "Штирлиц шёл по лесу и увидел голубые ели." {
+		t.Errorf("Content = %q", jokes[0].Content)
+	}
+}
+
+func TestFetchErrorStatus(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	src, err := NewSource(quoteConfig(srv.URL+"/missing.html"), srv.Client())
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+
+	if _, err := src.Fetch(context.Background()); err == nil {
+		t.Error("Expected error for missing page")
+	}
+}
+
+func TestNewSourceValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.SourceConfig
+	}{
+		{"no urls", config.SourceConfig{Selectors: config.SelectorsConfig{Item: "div"}}},
+		{"no item selector", config.SourceConfig{URL: "https://example.com"}},
+		{"bad selector", config.SourceConfig{URL: "https://example.com", Selectors: config.SelectorsConfig{Item: "div[["}}},
+		{"bad encoding", config.SourceConfig{URL: "https://example.com", Encoding: "klingon", Selectors: config.SelectorsConfig{Item: "div"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSource(tt.cfg, http.DefaultClient); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
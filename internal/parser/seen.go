@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// seenCache remembers the hashes of recently published jokes, so sources
+// that return the same jokes on every run do not flood the queue. The
+// least recently seen hash is dropped when it is full.
+type seenCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type seenEntry struct {
+	hash string
+	at   time.Time
+}
+
+func newSeenCache(size int, ttl time.Duration) *seenCache {
+	return &seenCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// seen reports whether hash was added within the TTL.
+func (c *seenCache) seen(hash string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[hash]
+	if !ok {
+		return false
+	}
+	if c.ttl > 0 && c.now().Sub(el.Value.(*seenEntry).at) > c.ttl {
+		c.order.Remove(el)
+		delete(c.entries, hash)
+		return false
+	}
+	c.order.MoveToFront(el)
+	return true
+}
+
+func (c *seenCache) add(hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[hash]; ok {
+		el.Value.(*seenEntry).at = c.now()
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[hash] = c.order.PushFront(&seenEntry{hash: hash, at: c.now()})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*seenEntry).hash)
+	}
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"anek-bot/internal/config"
+	"anek-bot/internal/queue"
+)
+
+func TestSeenCache(t *testing.T) {
+	now := time.Now()
+	c := newSeenCache(2, time.Hour)
+	c.now = func() time.Time { return now }
+
+	c.add("a")
+	c.add("b")
+	if !c.seen("a") || !c.seen("b") || c.seen("c") {
+		t.Fatal("seen() does not match the added hashes")
+	}
+
+	// "a" was looked at last, so "b" is the one dropped for "c".
+	c.seen("a")
+	c.add("c")
+	if !c.seen("a") || c.seen("b") || !c.seen("c") {
+		t.Error("seen() after eviction, want a and c kept")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if c.seen("a") {
+		t.Error("seen() is true after the TTL")
+	}
+	if c.order.Len() != 1 || len(c.entries) != 1 {
+		t.Errorf("expired hash is still cached: %d entries", len(c.entries))
+	}
+}
+
+func TestParseSkipsSeenJokes(t *testing.T) {
+	src := &fakeSource{name: "repeating", jokes: []*queue.JokeMessage{{Content: "one"}, {Content: "two"}}}
+	q := &recordingQueue{}
+	p, err := New(config.ParserConfig{Enabled: true, SeenCache: 10, SeenTTL: time.Hour}, q, WithSources(src))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	first := p.ParseAll(context.Background())
+	if first[0].Published != 2 || first[0].Skipped != 0 {
+		t.Errorf("first run = %+v, want 2 published", first[0])
+	}
+
+	src.jokes = append(src.jokes, &queue.JokeMessage{Content: "three"})
+	second := p.ParseAll(context.Background())
+	if second[0].Published != 1 || second[0].Skipped != 2 {
+		t.Errorf("second run = %+v, want 1 published and 2 skipped", second[0])
+	}
+	if len(q.jokes) != 3 {
+		t.Errorf("published %d jokes, want 3", len(q.jokes))
+	}
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"anek-bot/internal/config"
+	"anek-bot/internal/queue"
+)
+
+var (
+	ErrUnknownSourceType = errors.New("unknown source type")
+	ErrUnknownSource     = errors.New("unknown source")
+)
+
+// Source fetches jokes from a single configured site. Hash and Source on the
+// returned messages may be left empty, the parser fills them in.
+type Source interface {
+	Name() string
+	Config() config.SourceConfig
+	Fetch(ctx context.Context) ([]*queue.JokeMessage, error)
+}
+
+type Factory func(cfg config.SourceConfig, client *http.Client) (Source, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a source type available to the parser. It is meant to be
+// called from the init function of the package implementing the source.
+func Register(sourceType string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("parser: Register factory is nil")
+	}
+	if _, dup := registry[sourceType]; dup {
+		panic("parser: Register called twice for source type " + sourceType)
+	}
+	registry[sourceType] = factory
+}
+
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func NewSource(cfg config.SourceConfig, client *http.Client) (Source, error) {
+	registryMu.RLock()
+	factory, ok := registry[cfg.Type]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSourceType, cfg.Type)
+	}
+
+	src, err := factory(cfg, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create source %s: %w", cfg.SourceName(), err)
+	}
+	return src, nil
+}
@@ -0,0 +1,282 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"anek-bot/internal/config"
+	"anek-bot/pkg/logger"
+
+	"github.com/nats-io/nats.go"
+)
+
+// DeadLetterPrefix is put in front of the subject of a message that could
+// not be processed, which is then kept on the dead-letter stream for an
+// admin to look at, replay or purge.
+const DeadLetterPrefix = "deadletter."
+
+// deadLetterStreamSuffix names the dead-letter stream after the main one.
+// Dead letters have a stream of their own so that they are kept under
+// limits retention, with their own max age, whatever the main stream uses:
+// under interest retention they would be dropped at once, as nothing
+// consumes them.
+const deadLetterStreamSuffix = "_DEADLETTERS"
+
+const (
+	deadLetterErrorHeader      = "Anek-Error"
+	deadLetterDeliveriesHeader = "Anek-Deliveries"
+
+	// Redeliveries back off from redeliveryBaseDelay, doubling up to
+	// redeliveryMaxDelay.
+	redeliveryBaseDelay = time.Second
+	redeliveryMaxDelay  = 5 * time.Minute
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+func deadLetterStreamName(cfg config.NATSConfig) string {
+	return cfg.StreamName + deadLetterStreamSuffix
+}
+
+// DeadLetter is a message that failed on every delivery, or could not be
+// decoded at all.
+type DeadLetter struct {
+	Seq uint64
+	// Subject is where the message was published, and where a replay
+	// puts it again.
+	Subject    string
+	Data       []byte
+	Error      string
+	Deliveries int
+	FailedAt   time.Time
+}
+
+// DeadLetterStore is the admin side of the dead letters. Seq numbers come
+// from DeadLetters.
+type DeadLetterStore interface {
+	// DeadLetters returns the oldest dead letters, at most limit, and how
+	// many there are in total.
+	DeadLetters(ctx context.Context, limit int) ([]DeadLetter, int, error)
+	// ReplayDeadLetter publishes the message again and drops the dead
+	// letter.
+	ReplayDeadLetter(ctx context.Context, seq uint64) error
+	DeleteDeadLetter(ctx context.Context, seq uint64) error
+	// PurgeDeadLetters drops every dead letter and returns how many there
+	// were.
+	PurgeDeadLetters(ctx context.Context) (int, error)
+}
+
+// poisonError marks a message that can never be processed, so it is dead
+// lettered without redeliveries.
+type poisonError struct {
+	err error
+}
+
+func (e *poisonError) Error() string { return e.err.Error() }
+func (e *poisonError) Unwrap() error { return e.err }
+
+func poison(err error) error {
+	return &poisonError{err: err}
+}
+
+func isPoison(err error) bool {
+	var p *poisonError
+	return errors.As(err, &p)
+}
+
+// redeliveryDelay is how long a message waits after its nth failed
+// delivery.
+func redeliveryDelay(deliveries int) time.Duration {
+	delay := redeliveryBaseDelay
+	for i := 1; i < deliveries && delay < redeliveryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, redeliveryMaxDelay)
+}
+
+// settle acks msg, or after a failure redelivers it with backoff. Poison
+// messages and messages on their last delivery go to the dead letters.
+// A RetryError only postpones the message, so throttled messages are
+// never dead-lettered. JetStream counts their deliveries too though, so a
+// message that was throttled a lot has fewer retries left if it then
+// starts failing.
+func (n *NATS) settle(msg *nats.Msg, err error) {
+	if err == nil {
+		msg.Ack()
+		return
+	}
+
+	var retry *RetryError
+	if errors.As(err, &retry) && !isPoison(err) {
+		logger.Debug("Message postponed",
+			logger.String("subject", msg.Subject),
+			logger.Duration("delay", retry.Delay),
+		)
+		msg.NakWithDelay(retry.Delay)
+		return
+	}
+
+	deliveries := 1
+	if meta, metaErr := msg.Metadata(); metaErr == nil {
+		deliveries = int(meta.NumDelivered)
+	}
+	lastDelivery := n.cfg.MaxDeliver > 0 && deliveries >= n.cfg.MaxDeliver
+
+	if !isPoison(err) && !lastDelivery {
+		delay := redeliveryDelay(deliveries)
+		logger.Error("Failed to process message",
+			logger.Err(err),
+			logger.String("subject", msg.Subject),
+			logger.Int("deliveries", deliveries),
+			logger.Duration("retry_in", delay),
+		)
+		msg.NakWithDelay(delay)
+		return
+	}
+
+	if dlErr := n.deadLetter(msg.Subject, msg.Data, err, deliveries); dlErr != nil {
+		logger.Error("Failed to dead-letter message", logger.Err(dlErr), logger.String("subject", msg.Subject))
+		msg.NakWithDelay(redeliveryDelay(deliveries))
+		return
+	}
+	logger.Warn("Message dead-lettered",
+		logger.Err(err),
+		logger.String("subject", msg.Subject),
+		logger.Int("deliveries", deliveries),
+	)
+	msg.Term()
+}
+
+func (n *NATS) deadLetter(subject string, data []byte, cause error, deliveries int) error {
+	msg := nats.NewMsg(DeadLetterPrefix + subject)
+	msg.Data = data
+	msg.Header.Set(deadLetterErrorHeader, cause.Error())
+	msg.Header.Set(deadLetterDeliveriesHeader, strconv.Itoa(deliveries))
+
+	if _, err := n.jetstream.PublishMsg(msg); err != nil {
+		return fmt.Errorf("failed to publish dead letter: %w", err)
+	}
+	return nil
+}
+
+func (n *NATS) DeadLetters(ctx context.Context, limit int) ([]DeadLetter, int, error) {
+	total, err := n.deadLetterCount()
+	if err != nil || total == 0 {
+		return nil, total, err
+	}
+
+	sub, err := n.jetstream.SubscribeSync(DeadLetterPrefix+">",
+		nats.BindStream(deadLetterStreamName(n.cfg)),
+		nats.OrderedConsumer(),
+		nats.DeliverAll(),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read dead letters: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	var letters []DeadLetter
+	for len(letters) < limit {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read dead letters: %w", err)
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read dead letter metadata: %w", err)
+		}
+
+		letters = append(letters, deadLetterFrom(meta.Sequence.Stream, msg.Subject, msg.Header, msg.Data, meta.Timestamp))
+		if meta.NumPending == 0 {
+			break
+		}
+	}
+	return letters, total, nil
+}
+
+func (n *NATS) ReplayDeadLetter(ctx context.Context, seq uint64) error {
+	raw, err := n.getDeadLetter(ctx, seq)
+	if err != nil {
+		return err
+	}
+
+	subject := strings.TrimPrefix(raw.Subject, DeadLetterPrefix)
+	if _, err := n.jetstream.Publish(subject, raw.Data, nats.Context(ctx)); err != nil {
+		return fmt.Errorf("failed to replay dead letter %d: %w", seq, err)
+	}
+	if err := n.jetstream.DeleteMsg(deadLetterStreamName(n.cfg), seq, nats.Context(ctx)); err != nil {
+		return fmt.Errorf("failed to delete replayed dead letter %d: %w", seq, err)
+	}
+	return nil
+}
+
+func (n *NATS) DeleteDeadLetter(ctx context.Context, seq uint64) error {
+	if _, err := n.getDeadLetter(ctx, seq); err != nil {
+		return err
+	}
+	if err := n.jetstream.DeleteMsg(deadLetterStreamName(n.cfg), seq, nats.Context(ctx)); err != nil {
+		return fmt.Errorf("failed to delete dead letter %d: %w", seq, err)
+	}
+	return nil
+}
+
+func (n *NATS) PurgeDeadLetters(ctx context.Context) (int, error) {
+	total, err := n.deadLetterCount()
+	if err != nil || total == 0 {
+		return 0, err
+	}
+
+	err = n.jetstream.PurgeStream(deadLetterStreamName(n.cfg),
+		&nats.StreamPurgeRequest{Subject: DeadLetterPrefix + ">"},
+		nats.Context(ctx),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dead letters: %w", err)
+	}
+	return total, nil
+}
+
+func (n *NATS) deadLetterCount() (int, error) {
+	info, err := n.jetstream.StreamInfo(deadLetterStreamName(n.cfg), &nats.StreamInfoRequest{SubjectsFilter: DeadLetterPrefix + ">"})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+
+	total := 0
+	for _, count := range info.State.Subjects {
+		total += int(count)
+	}
+	return total, nil
+}
+
+// getDeadLetter loads the message at seq, making sure it is a dead letter
+// and not some other message on the stream.
+func (n *NATS) getDeadLetter(ctx context.Context, seq uint64) (*nats.RawStreamMsg, error) {
+	raw, err := n.jetstream.GetMsg(deadLetterStreamName(n.cfg), seq, nats.Context(ctx))
+	if errors.Is(err, nats.ErrMsgNotFound) {
+		return nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letter %d: %w", seq, err)
+	}
+	if !strings.HasPrefix(raw.Subject, DeadLetterPrefix) {
+		return nil, ErrDeadLetterNotFound
+	}
+	return raw, nil
+}
+
+func deadLetterFrom(seq uint64, subject string, header nats.Header, data []byte, at time.Time) DeadLetter {
+	deliveries, _ := strconv.Atoi(header.Get(deadLetterDeliveriesHeader))
+	return DeadLetter{
+		Seq:        seq,
+		Subject:    strings.TrimPrefix(subject, DeadLetterPrefix),
+		Data:       data,
+		Error:      header.Get(deadLetterErrorHeader),
+		Deliveries: deliveries,
+		FailedAt:   at,
+	}
+}
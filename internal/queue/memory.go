@@ -0,0 +1,349 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"anek-bot/internal/metrics"
+	"anek-bot/pkg/logger"
+)
+
+const (
+	// MemoryStreamName is the stream name Memory reports in its Status.
+	MemoryStreamName = "memory"
+	// memoryMaxDeliver bounds the failed deliveries of a message, like
+	// max_deliver on NATS.
+	memoryMaxDeliver = 10
+	// memoryDuplicateWindow matches the default duplicate_window of the
+	// stream: a message published again with the same ID within it is
+	// dropped.
+	memoryDuplicateWindow = time.Hour
+)
+
+// Memory is a Queue that keeps both streams in process, for running the
+// bot without NATS in development and tests. Messages are lost on exit.
+// Like JetStream, it redelivers messages the handler fails on with
+// backoff, dead-letters them when they run out of deliveries, drops
+// duplicates by message ID and hands each message to one consumer.
+type Memory struct {
+	jokes    *memoryStream
+	telegram *memoryStream
+	dead     *memoryDeadLetters
+}
+
+func NewMemory() *Memory {
+	dead := &memoryDeadLetters{}
+	return &Memory{
+		jokes:    newMemoryStream(JokeSubject, JokeConsumerGroup, dead),
+		telegram: newMemoryStream(TelegramSubject, TelegramConsumerGroup, dead),
+		dead:     dead,
+	}
+}
+
+func (m *Memory) Close() {}
+
+func (m *Memory) Status() (*Status, error) {
+	status := &Status{Stream: MemoryStreamName}
+	for _, s := range []*memoryStream{m.jokes, m.telegram} {
+		consumer, bytes := s.status()
+		status.Messages += consumer.Pending + uint64(consumer.AckPending)
+		status.Bytes += bytes
+		status.Consumers = append(status.Consumers, consumer)
+	}
+	return status, nil
+}
+
+func (m *Memory) PublishJoke(ctx context.Context, joke *JokeMessage) error {
+	data, err := json.Marshal(joke)
+	if err != nil {
+		return fmt.Errorf("failed to marshal joke: %w", err)
+	}
+	if !m.jokes.publish(joke.Hash, data) {
+		metrics.DuplicateDropped(metrics.LayerQueue)
+	}
+	return nil
+}
+
+func (m *Memory) PublishTelegramMessage(ctx context.Context, msg *TelegramMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telegram message: %w", err)
+	}
+	m.telegram.publish(msg.msgID(), data)
+	return nil
+}
+
+func (m *Memory) ConsumeJokes(ctx context.Context, handler func(*JokeMessage) error) error {
+	return m.jokes.consume(ctx, func(data []byte) error {
+		var joke JokeMessage
+		if err := json.Unmarshal(data, &joke); err != nil {
+			return poison(fmt.Errorf("failed to unmarshal joke message: %w", err))
+		}
+		return handler(&joke)
+	})
+}
+
+func (m *Memory) ConsumeTelegramMessages(ctx context.Context, handler func(*TelegramMessage) error) error {
+	return m.telegram.consume(ctx, func(data []byte) error {
+		var msg TelegramMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return poison(fmt.Errorf("failed to unmarshal telegram message: %w", err))
+		}
+		return handler(&msg)
+	})
+}
+
+// memoryStream is one subject with one consumer group. Messages wait in
+// order until they are due, and the consumers take turns on them.
+type memoryStream struct {
+	subject    string
+	name       string
+	maxDeliver int
+	dead       *memoryDeadLetters
+
+	mu          sync.Mutex
+	pending     []*memoryMessage
+	inFlight    int
+	redelivered int
+	seen        map[string]time.Time
+	// wake is signalled whenever a message is added.
+	wake chan struct{}
+}
+
+type memoryMessage struct {
+	data       []byte
+	due        time.Time
+	deliveries int
+	// failures counts the deliveries that failed, leaving out the ones
+	// only postponed by a RetryError.
+	failures int
+}
+
+func newMemoryStream(subject, name string, dead *memoryDeadLetters) *memoryStream {
+	return &memoryStream{
+		subject:    subject,
+		name:       name,
+		maxDeliver: memoryMaxDeliver,
+		dead:       dead,
+		seen:       make(map[string]time.Time),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// publish adds a message, or reports false when its id was seen within the
+// duplicate window.
+func (s *memoryStream) publish(id string, data []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id != "" {
+		now := time.Now()
+		for seenID, at := range s.seen {
+			if now.Sub(at) > memoryDuplicateWindow {
+				delete(s.seen, seenID)
+			}
+		}
+		if _, ok := s.seen[id]; ok {
+			return false
+		}
+		s.seen[id] = now
+	}
+
+	s.push(&memoryMessage{data: data})
+	return true
+}
+
+// push adds msg and wakes a consumer. Must be called with mu held.
+func (s *memoryStream) push(msg *memoryMessage) {
+	s.pending = append(s.pending, msg)
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *memoryStream) consume(ctx context.Context, handle func([]byte) error) error {
+	for {
+		msg, wait := s.next(time.Now())
+		if msg == nil {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-s.wake:
+			case <-timer.C:
+			}
+			timer.Stop()
+			continue
+		}
+
+		err := handle(msg.data)
+		s.done(msg, err)
+	}
+}
+
+// next takes the first message that is due, or returns how long until one
+// will be.
+func (s *memoryStream) next(now time.Time) (*memoryMessage, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wait := time.Minute
+	for i, msg := range s.pending {
+		if delay := msg.due.Sub(now); delay > 0 {
+			wait = min(wait, delay)
+			continue
+		}
+		s.pending = append(s.pending[:i], s.pending[i+1:]...)
+		s.inFlight++
+		msg.deliveries++
+		return msg, 0
+	}
+	return nil, wait
+}
+
+// done acks msg, or puts it back with backoff when the handler failed.
+// Poison messages and messages out of retries are dead-lettered; a
+// RetryError only postpones the message and never counts as a failure.
+func (s *memoryStream) done(msg *memoryMessage, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inFlight--
+	if err == nil {
+		return
+	}
+
+	var retry *RetryError
+	if errors.As(err, &retry) && !isPoison(err) {
+		s.redelivered++
+		msg.due = time.Now().Add(retry.Delay)
+		s.push(msg)
+		return
+	}
+
+	msg.failures++
+	if isPoison(err) || msg.failures >= s.maxDeliver {
+		logger.Warn("Message dead-lettered",
+			logger.Err(err),
+			logger.String("subject", s.subject),
+			logger.Int("deliveries", msg.deliveries),
+		)
+		s.dead.add(s.subject, msg.data, err, msg.deliveries)
+		return
+	}
+
+	delay := redeliveryDelay(msg.failures)
+	logger.Error("Failed to process message",
+		logger.Err(err),
+		logger.String("subject", s.subject),
+		logger.Int("deliveries", msg.deliveries),
+	)
+
+	s.redelivered++
+	msg.due = time.Now().Add(delay)
+	s.push(msg)
+}
+
+func (s *memoryStream) status() (ConsumerStatus, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bytes uint64
+	for _, msg := range s.pending {
+		bytes += uint64(len(msg.data))
+	}
+	return ConsumerStatus{
+		Name:        s.name,
+		Pending:     uint64(len(s.pending)),
+		AckPending:  s.inFlight,
+		Redelivered: s.redelivered,
+	}, bytes
+}
+
+func (m *Memory) DeadLetters(ctx context.Context, limit int) ([]DeadLetter, int, error) {
+	return m.dead.list(limit)
+}
+
+func (m *Memory) ReplayDeadLetter(ctx context.Context, seq uint64) error {
+	letter, ok := m.dead.remove(seq)
+	if !ok {
+		return ErrDeadLetterNotFound
+	}
+
+	stream := m.jokes
+	if letter.Subject == TelegramSubject {
+		stream = m.telegram
+	}
+	stream.publish("", letter.Data)
+	return nil
+}
+
+func (m *Memory) DeleteDeadLetter(ctx context.Context, seq uint64) error {
+	if _, ok := m.dead.remove(seq); !ok {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+func (m *Memory) PurgeDeadLetters(ctx context.Context) (int, error) {
+	return m.dead.purge(), nil
+}
+
+// memoryDeadLetters keeps the dead letters of both streams, oldest first.
+type memoryDeadLetters struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+	lastSeq uint64
+}
+
+func (d *memoryDeadLetters) add(subject string, data []byte, cause error, deliveries int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lastSeq++
+	d.letters = append(d.letters, DeadLetter{
+		Seq:        d.lastSeq,
+		Subject:    subject,
+		Data:       data,
+		Error:      cause.Error(),
+		Deliveries: deliveries,
+		FailedAt:   time.Now(),
+	})
+}
+
+func (d *memoryDeadLetters) list(limit int) ([]DeadLetter, int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := min(limit, len(d.letters))
+	return slices.Clone(d.letters[:n]), len(d.letters), nil
+}
+
+func (d *memoryDeadLetters) remove(seq uint64) (DeadLetter, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, letter := range d.letters {
+		if letter.Seq == seq {
+			d.letters = slices.Delete(d.letters, i, i+1)
+			return letter, true
+		}
+	}
+	return DeadLetter{}, false
+}
+
+func (d *memoryDeadLetters) purge() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := len(d.letters)
+	d.letters = nil
+	return n
+}
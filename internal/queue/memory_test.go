@@ -0,0 +1,263 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"anek-bot/internal/metrics"
+	"anek-bot/internal/models"
+	"anek-bot/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("error", io.Discard)
+	os.Exit(m.Run())
+}
+
+// consumeN runs a telegram consumer until it has handled n messages and
+// returns their texts in order.
+func consumeN(t *testing.T, q *Memory, n int, handler func(*TelegramMessage) error) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var texts []string
+	err := q.ConsumeTelegramMessages(ctx, func(msg *TelegramMessage) error {
+		if err := handler(msg); err != nil {
+			return err
+		}
+		texts = append(texts, msg.Text)
+		if len(texts) == n {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ConsumeTelegramMessages() = %v, want context.Canceled", err)
+	}
+	return texts
+}
+
+func TestMemoryTelegramOrder(t *testing.T) {
+	q := NewMemory()
+	ctx := context.Background()
+	for _, text := range []string{"one", "two", "three"} {
+		if err := q.PublishTelegramMessage(ctx, &TelegramMessage{ChatID: 1, Text: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := consumeN(t, q, 3, func(*TelegramMessage) error { return nil })
+	if len(got) != 3 || got[0] != "one" || got[1] != "two" || got[2] != "three" {
+		t.Errorf("consumed %v, want [one two three]", got)
+	}
+}
+
+func TestMemoryDeduplicatesByID(t *testing.T) {
+	q := NewMemory()
+	ctx := context.Background()
+	q.PublishTelegramMessage(ctx, &TelegramMessage{ID: "a", Text: "first"})
+	q.PublishTelegramMessage(ctx, &TelegramMessage{ID: "a", Text: "again"})
+	q.PublishTelegramMessage(ctx, &TelegramMessage{ID: "a", Text: "part", Part: 1})
+	q.PublishTelegramMessage(ctx, &TelegramMessage{Text: "no id"})
+	q.PublishTelegramMessage(ctx, &TelegramMessage{Text: "no id"})
+
+	status, err := q.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Consumers[1].Name != TelegramConsumerGroup || status.Consumers[1].Pending != 4 {
+		t.Errorf("telegram consumer status = %+v, want 4 pending", status.Consumers[1])
+	}
+}
+
+func TestMemoryRetryAfter(t *testing.T) {
+	q := NewMemory()
+	q.PublishTelegramMessage(context.Background(), &TelegramMessage{Text: "later"})
+
+	start := time.Now()
+	tries := 0
+	consumeN(t, q, 1, func(*TelegramMessage) error {
+		tries++
+		if tries == 1 {
+			return RetryAfter(errors.New("throttled"), 50*time.Millisecond)
+		}
+		return nil
+	})
+
+	if tries != 2 {
+		t.Errorf("handled %d times, want 2", tries)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("redelivered after %v, want at least the retry delay", elapsed)
+	}
+	status, _ := q.Status()
+	if got := status.Consumers[1]; got.Redelivered != 1 || got.Pending != 0 || got.AckPending != 0 {
+		t.Errorf("status after retry = %+v", got)
+	}
+}
+
+func TestMemoryThrottlingIsNotFailing(t *testing.T) {
+	q := NewMemory()
+	q.PublishTelegramMessage(context.Background(), &TelegramMessage{Text: "busy chat"})
+
+	tries := 0
+	got := consumeN(t, q, 1, func(*TelegramMessage) error {
+		tries++
+		if tries <= memoryMaxDeliver+1 {
+			return RetryAfter(errors.New("rate limited"), time.Millisecond)
+		}
+		return nil
+	})
+
+	if len(got) != 1 || tries != memoryMaxDeliver+2 {
+		t.Errorf("consumed %v after %d tries, want it delivered after %d", got, tries, memoryMaxDeliver+2)
+	}
+	if _, total, _ := q.DeadLetters(context.Background(), 10); total != 0 {
+		t.Errorf("%d dead letters, want throttled messages kept", total)
+	}
+}
+
+func TestMemoryJokes(t *testing.T) {
+	q := NewMemory()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	want := &JokeMessage{Content: "joke", Source: models.SourceAnekdot, Hash: "h"}
+	if err := q.PublishJoke(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+
+	var got *JokeMessage
+	err := q.ConsumeJokes(ctx, func(joke *JokeMessage) error {
+		got = joke
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ConsumeJokes() = %v", err)
+	}
+	if got == nil || *got != *want {
+		t.Errorf("consumed %+v, want %+v", got, want)
+	}
+}
+
+func TestRedeliveryDelay(t *testing.T) {
+	tests := []struct {
+		deliveries int
+		want       time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{9, 256 * time.Second},
+		{10, redeliveryMaxDelay},
+		{100, redeliveryMaxDelay},
+	}
+
+	for _, tt := range tests {
+		if got := redeliveryDelay(tt.deliveries); got != tt.want {
+			t.Errorf("redeliveryDelay(%d) = %v, want %v", tt.deliveries, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryDeadLetters(t *testing.T) {
+	q := NewMemory()
+	q.telegram.maxDeliver = 2
+	ctx := context.Background()
+
+	q.PublishTelegramMessage(ctx, &TelegramMessage{Text: "fails"})
+	q.telegram.publish("", []byte("not json"))
+	q.PublishTelegramMessage(ctx, &TelegramMessage{Text: "works"})
+
+	// Consume until both bad messages are dead letters.
+	consumeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var handled []string
+	q.ConsumeTelegramMessages(consumeCtx, func(msg *TelegramMessage) error {
+		handled = append(handled, msg.Text)
+		if msg.Text == "fails" {
+			if len(handled) > 2 {
+				defer cancel()
+			}
+			return errors.New("chat is gone")
+		}
+		return nil
+	})
+	if consumeCtx.Err() == context.DeadlineExceeded {
+		t.Fatalf("handled %v before timing out", handled)
+	}
+
+	letters, total, err := q.DeadLetters(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(letters) != 2 {
+		t.Fatalf("DeadLetters() = %+v, %d", letters, total)
+	}
+	if letters[0].Deliveries != 1 || !strings.Contains(letters[0].Error, "unmarshal") {
+		t.Errorf("undecodable message dead letter = %+v, want 1 delivery", letters[0])
+	}
+	if letters[1].Deliveries != 2 || letters[1].Error != "chat is gone" || letters[1].Subject != TelegramSubject {
+		t.Errorf("failing message dead letter = %+v, want 2 deliveries", letters[1])
+	}
+
+	if err := q.DeleteDeadLetter(ctx, letters[0].Seq); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.DeleteDeadLetter(ctx, letters[0].Seq); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("second DeleteDeadLetter() = %v, want ErrDeadLetterNotFound", err)
+	}
+
+	if err := q.ReplayDeadLetter(ctx, letters[1].Seq); err != nil {
+		t.Fatal(err)
+	}
+	got := consumeN(t, q, 1, func(*TelegramMessage) error { return nil })
+	if len(got) != 1 || got[0] != "fails" {
+		t.Errorf("consumed %v after replay, want [fails]", got)
+	}
+
+	q.dead.add(JokeSubject, []byte("{}"), errors.New("boom"), 1)
+	if n, err := q.PurgeDeadLetters(ctx); n != 1 || err != nil {
+		t.Errorf("PurgeDeadLetters() = %d, %v, want 1", n, err)
+	}
+	if _, total, _ := q.DeadLetters(ctx, 10); total != 0 {
+		t.Errorf("%d dead letters left after purge", total)
+	}
+}
+
+func TestMemoryDropsRepeatedJokes(t *testing.T) {
+	q := NewMemory()
+	ctx := context.Background()
+	before := queueDuplicates()
+
+	for _, joke := range []*JokeMessage{{Content: "a", Hash: "ha"}, {Content: "a", Hash: "ha"}, {Content: "b", Hash: "hb"}} {
+		if err := q.PublishJoke(ctx, joke); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status, _ := q.Status()
+	if status.Consumers[0].Pending != 2 {
+		t.Errorf("%d jokes pending, want 2", status.Consumers[0].Pending)
+	}
+	if got := queueDuplicates() - before; got != 1 {
+		t.Errorf("queue dropped %d duplicates, want 1", got)
+	}
+}
+
+func queueDuplicates() int64 {
+	for _, c := range metrics.DuplicatesDropped() {
+		if c.Layer == metrics.LayerQueue {
+			return c.Count
+		}
+	}
+	return 0
+}
@@ -0,0 +1,241 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"anek-bot/internal/config"
+	"anek-bot/pkg/logger"
+
+	"github.com/nats-io/nats.go"
+)
+
+// defaultAckWait is what JetStream uses when a consumer sets no ack wait.
+const defaultAckWait = 30 * time.Second
+
+// ErrIncompatibleConfig is returned when the stream or a consumer already
+// exists with settings JetStream cannot change in place. They have to be
+// fixed by hand, or the stream recreated.
+var ErrIncompatibleConfig = errors.New("incompatible JetStream configuration")
+
+// consumerSubjects maps every durable consumer the bot uses to its subject.
+var consumerSubjects = []struct {
+	durable string
+	subject string
+}{
+	{JokeConsumerGroup, JokeSubject},
+	{TelegramConsumerGroup, TelegramSubject},
+}
+
+// provision creates the stream and the durable consumers, or brings them
+// in line with the config. Running it again with the same config changes
+// nothing.
+func (n *NATS) provision() error {
+	want, err := streamConfig(n.cfg)
+	if err != nil {
+		return err
+	}
+	if err := n.ensureStream(want); err != nil {
+		return err
+	}
+	if err := n.ensureStream(deadLetterStreamConfig(n.cfg)); err != nil {
+		return err
+	}
+
+	for _, c := range consumerSubjects {
+		if err := n.ensureConsumer(consumerConfig(n.cfg, c.durable, c.subject)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *NATS) ensureStream(want *nats.StreamConfig) error {
+	info, err := n.jetstream.StreamInfo(want.Name)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		if _, err := n.jetstream.AddStream(want); err != nil {
+			return fmt.Errorf("failed to create stream %s: %w", want.Name, err)
+		}
+		logger.Info("Created JetStream stream", logger.String("stream", want.Name))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get stream info: %w", err)
+	}
+
+	updated, changed, err := mergeStreamConfig(info.Config, want)
+	if err != nil || !changed {
+		return err
+	}
+	if _, err := n.jetstream.UpdateStream(updated); err != nil {
+		return fmt.Errorf("failed to update stream %s: %w", want.Name, err)
+	}
+	logger.Info("Updated JetStream stream", logger.String("stream", want.Name))
+	return nil
+}
+
+func (n *NATS) ensureConsumer(want *nats.ConsumerConfig) error {
+	info, err := n.jetstream.ConsumerInfo(n.cfg.StreamName, want.Durable)
+	if errors.Is(err, nats.ErrConsumerNotFound) {
+		if _, err := n.jetstream.AddConsumer(n.cfg.StreamName, want); err != nil {
+			return fmt.Errorf("failed to create consumer %s: %w", want.Durable, err)
+		}
+		logger.Info("Created JetStream consumer", logger.String("consumer", want.Durable))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get consumer info for %s: %w", want.Durable, err)
+	}
+
+	updated, changed, err := mergeConsumerConfig(info.Config, want)
+	if err != nil || !changed {
+		return err
+	}
+	if _, err := n.jetstream.UpdateConsumer(n.cfg.StreamName, updated); err != nil {
+		return fmt.Errorf("failed to update consumer %s: %w", want.Durable, err)
+	}
+	logger.Info("Updated JetStream consumer", logger.String("consumer", want.Durable))
+	return nil
+}
+
+// streamConfig is the stream the config asks for. Zero limits mean no
+// limit.
+func streamConfig(cfg config.NATSConfig) (*nats.StreamConfig, error) {
+	var retention nats.RetentionPolicy
+	switch strings.ToLower(cfg.Retention) {
+	case "", "limits":
+		retention = nats.LimitsPolicy
+	case "interest":
+		retention = nats.InterestPolicy
+	case "workqueue":
+		retention = nats.WorkQueuePolicy
+	default:
+		return nil, fmt.Errorf("unknown stream retention %q, want limits, interest or workqueue", cfg.Retention)
+	}
+
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = -1
+	}
+	if cfg.MaxAge > 0 && cfg.DuplicateWindow > cfg.MaxAge {
+		return nil, fmt.Errorf("duplicate window %v is longer than the stream max age %v", cfg.DuplicateWindow, cfg.MaxAge)
+	}
+
+	return &nats.StreamConfig{
+		Name:       cfg.StreamName,
+		Subjects:   []string{JokeSubject, TelegramSubject},
+		Retention:  retention,
+		MaxAge:     cfg.MaxAge,
+		MaxBytes:   maxBytes,
+		Replicas:   max(cfg.Replicas, 1),
+		Storage:    nats.FileStorage,
+		Duplicates: cfg.DuplicateWindow,
+	}, nil
+}
+
+// deadLetterStreamConfig is the stream dead letters are kept on until an
+// admin handles them or DeadLetterMaxAge passes.
+func deadLetterStreamConfig(cfg config.NATSConfig) *nats.StreamConfig {
+	return &nats.StreamConfig{
+		Name:      deadLetterStreamName(cfg),
+		Subjects:  []string{DeadLetterPrefix + ">"},
+		Retention: nats.LimitsPolicy,
+		MaxAge:    cfg.DeadLetterMaxAge,
+		MaxBytes:  -1,
+		Replicas:  max(cfg.Replicas, 1),
+		Storage:   nats.FileStorage,
+	}
+}
+
+// consumerConfig leaves deliveries unbounded on the server: settle applies
+// max_deliver itself, so that throttled messages, which are redelivered
+// for as long as it takes, are not dropped by JetStream.
+func consumerConfig(cfg config.NATSConfig, durable, subject string) *nats.ConsumerConfig {
+	ackWait := cfg.AckWait
+	if ackWait <= 0 {
+		ackWait = defaultAckWait
+	}
+
+	return &nats.ConsumerConfig{
+		Durable:       durable,
+		FilterSubject: subject,
+		DeliverPolicy: nats.DeliverAllPolicy,
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       ackWait,
+		MaxDeliver:    -1,
+	}
+}
+
+// mergeStreamConfig applies want to the existing stream config. Settings
+// the bot does not manage are kept, and so are subjects added by hand.
+func mergeStreamConfig(have nats.StreamConfig, want *nats.StreamConfig) (*nats.StreamConfig, bool, error) {
+	if have.Retention != want.Retention {
+		return nil, false, fmt.Errorf("%w: stream %s has %s retention, config wants %s",
+			ErrIncompatibleConfig, have.Name, have.Retention, want.Retention)
+	}
+	if have.Storage != want.Storage {
+		return nil, false, fmt.Errorf("%w: stream %s uses %s storage, want %s",
+			ErrIncompatibleConfig, have.Name, have.Storage, want.Storage)
+	}
+
+	merged := have
+	merged.Subjects = slices.Clone(have.Subjects)
+	for _, subject := range want.Subjects {
+		if !slices.Contains(merged.Subjects, subject) {
+			merged.Subjects = append(merged.Subjects, subject)
+		}
+	}
+	merged.MaxAge = want.MaxAge
+	merged.MaxBytes = want.MaxBytes
+	merged.Replicas = want.Replicas
+	if want.Duplicates > 0 {
+		merged.Duplicates = want.Duplicates
+	}
+
+	changed := len(merged.Subjects) != len(have.Subjects) ||
+		merged.MaxAge != have.MaxAge ||
+		merged.MaxBytes != have.MaxBytes ||
+		merged.Replicas != have.Replicas ||
+		merged.Duplicates != have.Duplicates
+	return &merged, changed, nil
+}
+
+// mergeConsumerConfig applies want to the existing consumer config.
+func mergeConsumerConfig(have nats.ConsumerConfig, want *nats.ConsumerConfig) (*nats.ConsumerConfig, bool, error) {
+	if have.DeliverSubject != "" {
+		return nil, false, fmt.Errorf("%w: consumer %s is a push consumer, the bot pulls",
+			ErrIncompatibleConfig, want.Durable)
+	}
+	if have.AckPolicy != want.AckPolicy {
+		return nil, false, fmt.Errorf("%w: consumer %s has ack policy %s, want %s",
+			ErrIncompatibleConfig, want.Durable, have.AckPolicy, want.AckPolicy)
+	}
+	if have.DeliverPolicy != want.DeliverPolicy {
+		return nil, false, fmt.Errorf("%w: consumer %s has deliver policy %v, want %v",
+			ErrIncompatibleConfig, want.Durable, have.DeliverPolicy, want.DeliverPolicy)
+	}
+	if have.FilterSubject != want.FilterSubject || len(have.FilterSubjects) > 0 {
+		return nil, false, fmt.Errorf("%w: consumer %s reads %s, want %s",
+			ErrIncompatibleConfig, want.Durable, consumerFilter(have), want.FilterSubject)
+	}
+
+	merged := have
+	merged.AckWait = want.AckWait
+	merged.MaxDeliver = want.MaxDeliver
+
+	changed := merged.AckWait != have.AckWait || merged.MaxDeliver != have.MaxDeliver
+	return &merged, changed, nil
+}
+
+func consumerFilter(c nats.ConsumerConfig) string {
+	if len(c.FilterSubjects) > 0 {
+		return strings.Join(c.FilterSubjects, ", ")
+	}
+	if c.FilterSubject == "" {
+		return "every subject"
+	}
+	return c.FilterSubject
+}
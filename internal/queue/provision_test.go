@@ -0,0 +1,226 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"anek-bot/internal/config"
+
+	"github.com/nats-io/nats.go"
+)
+
+func testNATSConfig() config.NATSConfig {
+	return config.NATSConfig{
+		StreamName: "ANEK",
+		Retention:  "limits",
+		MaxAge:     168 * time.Hour,
+		Replicas:   1,
+		AckWait:    30 * time.Second,
+	}
+}
+
+func TestStreamConfig(t *testing.T) {
+	cfg := testNATSConfig()
+	got, err := streamConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Retention != nats.LimitsPolicy || got.MaxBytes != -1 || got.Replicas != 1 || len(got.Subjects) != 2 {
+		t.Errorf("streamConfig() = %+v", got)
+	}
+
+	cfg.Retention = "WorkQueue"
+	if got, err := streamConfig(cfg); err != nil || got.Retention != nats.WorkQueuePolicy {
+		t.Errorf("workqueue retention = %v, %v", got, err)
+	}
+
+	cfg.Retention = "forever"
+	if _, err := streamConfig(cfg); err == nil {
+		t.Error("Expected error for unknown retention")
+	}
+
+	cfg = testNATSConfig()
+	cfg.DuplicateWindow = time.Hour
+	if got, err := streamConfig(cfg); err != nil || got.Duplicates != time.Hour {
+		t.Errorf("duplicate window = %v, %v", got, err)
+	}
+	cfg.DuplicateWindow = cfg.MaxAge + time.Hour
+	if _, err := streamConfig(cfg); err == nil {
+		t.Error("Expected error for a duplicate window longer than max age")
+	}
+}
+
+func TestDeadLetterStreamConfig(t *testing.T) {
+	cfg := testNATSConfig()
+	cfg.Retention = "interest"
+	cfg.DeadLetterMaxAge = 720 * time.Hour
+
+	got := deadLetterStreamConfig(cfg)
+	if got.Name != "ANEK_DEADLETTERS" || got.Retention != nats.LimitsPolicy || got.MaxAge != 720*time.Hour {
+		t.Errorf("deadLetterStreamConfig() = %+v, want limits retention whatever the main stream uses", got)
+	}
+
+	main, err := streamConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, subject := range main.Subjects {
+		if subject == got.Subjects[0] {
+			t.Errorf("main stream also listens on %s", subject)
+		}
+	}
+}
+
+func TestMergeStreamConfig(t *testing.T) {
+	want, err := streamConfig(testNATSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		have        nats.StreamConfig
+		wantChanged bool
+		wantErr     bool
+	}{
+		{"same", *want, false, false},
+		{"created by nats-init", nats.StreamConfig{Name: "ANEK", Subjects: []string{JokeSubject, TelegramSubject}, MaxBytes: -1, Replicas: 1, Storage: nats.FileStorage}, true, false},
+		{"missing subjects", nats.StreamConfig{Name: "ANEK", Subjects: []string{JokeSubject}, MaxAge: want.MaxAge, MaxBytes: -1, Replicas: 1, Storage: nats.FileStorage}, true, false},
+		{"other retention", nats.StreamConfig{Name: "ANEK", Retention: nats.WorkQueuePolicy, Storage: nats.FileStorage}, false, true},
+		{"memory storage", nats.StreamConfig{Name: "ANEK", Storage: nats.MemoryStorage}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, changed, err := mergeStreamConfig(tt.have, want)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mergeStreamConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrIncompatibleConfig) {
+					t.Errorf("error %v is not ErrIncompatibleConfig", err)
+				}
+				return
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if merged.MaxAge != want.MaxAge || len(merged.Subjects) < 2 {
+				t.Errorf("merged = %+v", merged)
+			}
+		})
+	}
+}
+
+func TestMergeStreamConfigKeepsExtraSubjects(t *testing.T) {
+	want, _ := streamConfig(testNATSConfig())
+	have := *want
+	have.Subjects = []string{"audit.>", JokeSubject}
+	have.Duplicates = 2 * time.Minute
+
+	merged, changed, err := mergeStreamConfig(have, want)
+	if err != nil || !changed {
+		t.Fatalf("mergeStreamConfig() = %v, %v", changed, err)
+	}
+	if got := fmt.Sprint(merged.Subjects); got != "[audit.> jokes.new telegram.send]" {
+		t.Errorf("subjects = %v", got)
+	}
+	if merged.Duplicates != 2*time.Minute {
+		t.Errorf("Duplicates = %v, want the existing window kept when none is configured", merged.Duplicates)
+	}
+	if len(have.Subjects) != 2 {
+		t.Error("mergeStreamConfig() changed the existing config")
+	}
+}
+
+func TestMergeConsumerConfig(t *testing.T) {
+	want := consumerConfig(testNATSConfig(), TelegramConsumerGroup, TelegramSubject)
+
+	tests := []struct {
+		name        string
+		have        nats.ConsumerConfig
+		wantChanged bool
+		wantErr     bool
+	}{
+		{"same", *want, false, false},
+		{"other ack wait", nats.ConsumerConfig{Durable: TelegramConsumerGroup, FilterSubject: TelegramSubject, AckPolicy: nats.AckExplicitPolicy, AckWait: time.Minute, MaxDeliver: -1}, true, false},
+		{"bounded deliveries", nats.ConsumerConfig{Durable: TelegramConsumerGroup, FilterSubject: TelegramSubject, AckPolicy: nats.AckExplicitPolicy, AckWait: 30 * time.Second, MaxDeliver: 5}, true, false},
+		{"push consumer", nats.ConsumerConfig{Durable: TelegramConsumerGroup, DeliverSubject: "deliver.here", AckPolicy: nats.AckExplicitPolicy}, false, true},
+		{"ack none", nats.ConsumerConfig{Durable: TelegramConsumerGroup, FilterSubject: TelegramSubject, AckPolicy: nats.AckNonePolicy}, false, true},
+		{"new messages only", nats.ConsumerConfig{Durable: TelegramConsumerGroup, FilterSubject: TelegramSubject, AckPolicy: nats.AckExplicitPolicy, DeliverPolicy: nats.DeliverNewPolicy}, false, true},
+		{"other subject", nats.ConsumerConfig{Durable: TelegramConsumerGroup, FilterSubject: JokeSubject, AckPolicy: nats.AckExplicitPolicy}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, changed, err := mergeConsumerConfig(tt.have, want)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mergeConsumerConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrIncompatibleConfig) {
+					t.Errorf("error %v is not ErrIncompatibleConfig", err)
+				}
+				return
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if merged.AckWait != want.AckWait || merged.MaxDeliver != want.MaxDeliver {
+				t.Errorf("merged = %+v", merged)
+			}
+		})
+	}
+}
+
+// TestProvision runs against a real JetStream server:
+//
+//	TEST_NATS_URL=nats://localhost:4222 go test -run Provision ./internal/queue/
+//
+// It creates a throwaway stream that is deleted afterwards, so no other
+// stream on the server may listen on the bot's subjects.
+func TestProvision(t *testing.T) {
+	url := os.Getenv("TEST_NATS_URL")
+	if url == "" {
+		t.Skip("TEST_NATS_URL is not set")
+	}
+
+	cfg := testNATSConfig()
+	cfg.URL = url
+	cfg.StreamName = fmt.Sprintf("TEST_PROVISION_%d", os.Getpid())
+
+	n, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	defer n.Close()
+	defer n.jetstream.DeleteStream(cfg.StreamName)
+	defer n.jetstream.DeleteStream(deadLetterStreamName(cfg))
+
+	// A second start applies a changed ack wait in place.
+	cfg.AckWait = time.Minute
+	again, err := New(cfg)
+	if err != nil {
+		t.Fatalf("second New() = %v", err)
+	}
+	again.Close()
+
+	ci, err := n.jetstream.ConsumerInfo(cfg.StreamName, TelegramConsumerGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.Config.AckWait != time.Minute {
+		t.Errorf("AckWait = %v, want 1m", ci.Config.AckWait)
+	}
+	if _, err := n.jetstream.StreamInfo(deadLetterStreamName(cfg)); err != nil {
+		t.Errorf("dead-letter stream: %v", err)
+	}
+
+	cfg.Retention = "workqueue"
+	if _, err := New(cfg); !errors.Is(err, ErrIncompatibleConfig) {
+		t.Errorf("New() with other retention = %v, want ErrIncompatibleConfig", err)
+	}
+}
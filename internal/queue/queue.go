@@ -3,10 +3,13 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"anek-bot/internal/config"
+	"anek-bot/internal/metrics"
 	"anek-bot/internal/models"
 	"anek-bot/pkg/logger"
 
@@ -20,9 +23,65 @@ const (
 	TelegramConsumerGroup = "telegram-consumer"
 )
 
+// JokePublisher puts parsed jokes on the joke stream.
+type JokePublisher interface {
+	PublishJoke(ctx context.Context, joke *JokeMessage) error
+}
+
+// JokeConsumer hands jokes from the joke stream to handler until ctx is
+// done. A joke the handler fails on is redelivered.
+type JokeConsumer interface {
+	ConsumeJokes(ctx context.Context, handler func(*JokeMessage) error) error
+}
+
+// TelegramPublisher puts outgoing messages on the telegram stream.
+type TelegramPublisher interface {
+	PublishTelegramMessage(ctx context.Context, msg *TelegramMessage) error
+}
+
+// TelegramConsumer hands messages from the telegram stream to handler until
+// ctx is done. A message the handler fails on is redelivered until it runs
+// out of deliveries and becomes a dead letter. A RetryError postpones the
+// message by its delay instead, as often as it takes.
+type TelegramConsumer interface {
+	ConsumeTelegramMessages(ctx context.Context, handler func(*TelegramMessage) error) error
+}
+
+// Queue is a message queue carrying both streams: NATS in production or
+// Memory in a single process.
+type Queue interface {
+	JokePublisher
+	JokeConsumer
+	TelegramPublisher
+	TelegramConsumer
+	DeadLetterStore
+	Status() (*Status, error)
+	Close()
+}
+
+// RetryError asks the consumer to redeliver the message after Delay rather
+// than right away.
+type RetryError struct {
+	Delay time.Duration
+	Err   error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("retry in %s: %v", e.Delay, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter wraps err so that the message is redelivered after delay.
+func RetryAfter(err error, delay time.Duration) error {
+	return &RetryError{Delay: delay, Err: err}
+}
+
 type NATS struct {
 	conn      *nats.Conn
-	jetstream nats.JetStream
+	jetstream nats.JetStreamContext
 	cfg       config.NATSConfig
 }
 
@@ -44,6 +103,11 @@ func New(cfg config.NATSConfig) (*NATS, error) {
 		cfg:       cfg,
 	}
 
+	if err := n.provision(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
 	return n, nil
 }
 
@@ -53,24 +117,88 @@ func (n *NATS) Close() {
 	}
 }
 
+type Status struct {
+	Stream    string
+	Messages  uint64
+	Bytes     uint64
+	Consumers []ConsumerStatus
+}
+
+type ConsumerStatus struct {
+	Name        string
+	Pending     uint64
+	AckPending  int
+	Redelivered int
+}
+
+// Status reports the size of the stream and the backlog of the bot's
+// consumers. Consumers that were not created yet are left out.
+func (n *NATS) Status() (*Status, error) {
+	info, err := n.jetstream.StreamInfo(n.cfg.StreamName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stream info: %w", err)
+	}
+
+	status := &Status{
+		Stream:   info.Config.Name,
+		Messages: info.State.Msgs,
+		Bytes:    info.State.Bytes,
+	}
+
+	for _, name := range []string{JokeConsumerGroup, TelegramConsumerGroup} {
+		ci, err := n.jetstream.ConsumerInfo(n.cfg.StreamName, name)
+		if errors.Is(err, nats.ErrConsumerNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get consumer info for %s: %w", name, err)
+		}
+		status.Consumers = append(status.Consumers, ConsumerStatus{
+			Name:        name,
+			Pending:     ci.NumPending,
+			AckPending:  ci.NumAckPending,
+			Redelivered: ci.NumRedelivered,
+		})
+	}
+
+	return status, nil
+}
+
 type JokeMessage struct {
 	Content   string            `json:"content"`
 	Source    models.JokeSource `json:"source"`
 	SourceURL string            `json:"source_url"`
 	Hash      string            `json:"hash"`
+	NSFW      bool              `json:"nsfw,omitempty"`
 }
 
+// PublishJoke uses the joke hash as the message ID, so a joke the parser
+// fetches again within the stream's duplicate window is dropped there.
 func (n *NATS) PublishJoke(ctx context.Context, joke *JokeMessage) error {
 	data, err := json.Marshal(joke)
 	if err != nil {
 		return fmt.Errorf("failed to marshal joke: %w", err)
 	}
 
-	_, err = n.jetstream.Publish(JokeSubject, data, nats.Context(ctx))
+	opts := []nats.PubOpt{nats.Context(ctx)}
+	if joke.Hash != "" {
+		opts = append(opts, nats.MsgId(joke.Hash))
+	}
+
+	ack, err := n.jetstream.Publish(JokeSubject, data, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to publish joke: %w", err)
 	}
 
+	if ack.Duplicate {
+		metrics.DuplicateDropped(metrics.LayerQueue)
+		logger.Debug("Duplicate joke dropped by queue",
+			logger.String("source", string(joke.Source)),
+			logger.String("hash", joke.Hash),
+		)
+		return nil
+	}
+
 	logger.Debug("Joke published to queue",
 		logger.String("source", string(joke.Source)),
 		logger.String("hash", joke.Hash),
@@ -80,8 +208,51 @@ func (n *NATS) PublishJoke(ctx context.Context, joke *JokeMessage) error {
 }
 
 type TelegramMessage struct {
+	// ID, when set, is used as the JetStream message ID so that publishing
+	// the same message again within the stream's duplicate window is a no-op.
+	ID     string `json:"id,omitempty"`
 	ChatID int64  `json:"chat_id"`
 	Text   string `json:"text"`
+	// ParseMode is the Telegram parse mode of Text, empty for plain text.
+	ParseMode string `json:"parse_mode,omitempty"`
+	// More holds the parts that follow Text when the message is too long
+	// for one Telegram message. Buttons go under the last part.
+	More []string `json:"more,omitempty"`
+	// Part is the index of Text among the parts of a long message.
+	Part    int        `json:"part,omitempty"`
+	Buttons [][]Button `json:"buttons,omitempty"`
+	// BroadcastID links the message to the broadcast whose delivery state
+	// is updated once it is sent.
+	BroadcastID int64 `json:"broadcast_id,omitempty"`
+}
+
+// Next returns the message carrying the part after m, or nil when m is the
+// last part.
+func (m *TelegramMessage) Next() *TelegramMessage {
+	if len(m.More) == 0 {
+		return nil
+	}
+
+	next := *m
+	next.Text = m.More[0]
+	next.More = m.More[1:]
+	next.Part = m.Part + 1
+	return &next
+}
+
+// msgID is the JetStream message ID of m. Every part of a long message
+// gets its own, so queueing a part twice is still a no-op.
+func (m *TelegramMessage) msgID() string {
+	if m.ID == "" || m.Part == 0 {
+		return m.ID
+	}
+	return fmt.Sprintf("%s/%d", m.ID, m.Part)
+}
+
+type Button struct {
+	Text   string `json:"text"`
+	Unique string `json:"unique"`
+	Data   string `json:"data,omitempty"`
 }
 
 func (n *NATS) PublishTelegramMessage(ctx context.Context, msg *TelegramMessage) error {
@@ -90,7 +261,12 @@ func (n *NATS) PublishTelegramMessage(ctx context.Context, msg *TelegramMessage)
 		return fmt.Errorf("failed to marshal telegram message: %w", err)
 	}
 
-	_, err = n.jetstream.Publish(TelegramSubject, data, nats.Context(ctx))
+	opts := []nats.PubOpt{nats.Context(ctx)}
+	if id := msg.msgID(); id != "" {
+		opts = append(opts, nats.MsgId(id))
+	}
+
+	_, err = n.jetstream.Publish(TelegramSubject, data, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to publish telegram message: %w", err)
 	}
@@ -103,12 +279,31 @@ func (n *NATS) PublishTelegramMessage(ctx context.Context, msg *TelegramMessage)
 }
 
 func (n *NATS) ConsumeJokes(ctx context.Context, handler func(*JokeMessage) error) error {
-	sub, err := n.jetstream.PullSubscribe(
-		JokeSubject,
-		JokeConsumerGroup,
-	)
+	return n.consume(ctx, JokeSubject, JokeConsumerGroup, func(data []byte) error {
+		var joke JokeMessage
+		if err := json.Unmarshal(data, &joke); err != nil {
+			return poison(fmt.Errorf("failed to unmarshal joke message: %w", err))
+		}
+		return handler(&joke)
+	})
+}
+
+func (n *NATS) ConsumeTelegramMessages(ctx context.Context, handler func(*TelegramMessage) error) error {
+	return n.consume(ctx, TelegramSubject, TelegramConsumerGroup, func(data []byte) error {
+		var msg TelegramMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return poison(fmt.Errorf("failed to unmarshal telegram message: %w", err))
+		}
+		return handler(&msg)
+	})
+}
+
+// consume pulls messages for durable until ctx is done and settles each
+// with the outcome of handle.
+func (n *NATS) consume(ctx context.Context, subject, durable string, handle func([]byte) error) error {
+	sub, err := n.jetstream.PullSubscribe(subject, durable, nats.Bind(n.cfg.StreamName, durable))
 	if err != nil {
-		return fmt.Errorf("failed to subscribe to jokes: %w", err)
+		return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
 	}
 	defer sub.Unsubscribe()
 
@@ -119,82 +314,54 @@ func (n *NATS) ConsumeJokes(ctx context.Context, handler func(*JokeMessage) erro
 		default:
 			msgs, err := sub.Fetch(10, nats.MaxWait(500*time.Millisecond))
 			if err != nil {
-				if err == nats.ErrTimeout {
+				if errors.Is(err, nats.ErrTimeout) {
 					continue
 				}
-				logger.Error("Failed to fetch messages", logger.Err(err))
+				if errors.Is(err, nats.ErrConnectionClosed) || errors.Is(err, nats.ErrBadSubscription) {
+					return fmt.Errorf("failed to fetch messages: %w", err)
+				}
+				logger.Error("Failed to fetch messages", logger.Err(err), logger.String("subject", subject))
 				continue
 			}
 
-			logger.Info("Received messages", logger.Int("count", len(msgs)))
+			logger.Debug("Received messages", logger.String("subject", subject), logger.Int("count", len(msgs)))
 
+			settled, stop := n.keepInProgress(msgs)
 			for _, msg := range msgs {
-				var joke JokeMessage
-				if err := json.Unmarshal(msg.Data, &joke); err != nil {
-					logger.Error("Failed to unmarshal joke message",
-						logger.Err(err),
-					)
-					msg.Nak()
-					continue
-				}
-
-				if err := handler(&joke); err != nil {
-					logger.Error("Failed to process joke",
-						logger.Err(err),
-					)
-					msg.Nak()
-					continue
-				}
-
-				msg.Ack()
+				n.settle(msg, handle(msg.Data))
+				settled()
 			}
+			stop()
 		}
 	}
 }
 
-func (n *NATS) ConsumeTelegramMessages(ctx context.Context, handler func(*TelegramMessage) error) error {
-	sub, err := n.jetstream.PullSubscribe(
-		TelegramSubject,
-		TelegramConsumerGroup,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to subscribe to telegram: %w", err)
+// keepInProgress tells JetStream every half ack wait that the messages of a
+// batch not settled yet are still being worked on, so that a slow handler,
+// such as one sending the parts of a long message, does not get them
+// redelivered to another consumer. settled is called after each message.
+func (n *NATS) keepInProgress(msgs []*nats.Msg) (settled func(), stop func()) {
+	ackWait := n.cfg.AckWait
+	if ackWait <= 0 {
+		ackWait = defaultAckWait
 	}
-	defer sub.Unsubscribe()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			msgs, err := sub.Fetch(10, nats.MaxWait(500))
-			if err != nil {
-				if err == nats.ErrTimeout {
-					continue
-				}
-				return fmt.Errorf("failed to fetch messages: %w", err)
-			}
 
-			for _, msg := range msgs {
-				var telegramMsg TelegramMessage
-				if err := json.Unmarshal(msg.Data, &telegramMsg); err != nil {
-					logger.Error("Failed to unmarshal telegram message",
-						logger.Err(err),
-					)
-					msg.Nak()
-					continue
+	var next atomic.Int64
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(ackWait / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				for _, msg := range msgs[next.Load():] {
+					msg.InProgress()
 				}
-
-				if err := handler(&telegramMsg); err != nil {
-					logger.Error("Failed to send telegram message",
-						logger.Err(err),
-					)
-					msg.Nak()
-					continue
-				}
-
-				msg.Ack()
 			}
 		}
-	}
+	}()
+
+	return func() { next.Add(1) }, func() { close(done) }
 }
@@ -2,7 +2,10 @@ package queue
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"anek-bot/internal/models"
 )
@@ -80,3 +83,48 @@ func TestJokeSourceValues(t *testing.T) {
 		})
 	}
 }
+
+func TestRetryAfter(t *testing.T) {
+	cause := errors.New("chat throttled")
+	err := fmt.Errorf("handler: %w", RetryAfter(cause, 3*time.Second))
+
+	var retry *RetryError
+	if !errors.As(err, &retry) {
+		t.Fatal("RetryAfter() error is not a *RetryError")
+	}
+	if retry.Delay != 3*time.Second {
+		t.Errorf("Delay = %v, want 3s", retry.Delay)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("RetryAfter() does not wrap the cause")
+	}
+}
+
+func TestTelegramMessageNext(t *testing.T) {
+	msg := &TelegramMessage{
+		ID:      "broadcast:1:2",
+		ChatID:  2,
+		Text:    "one",
+		More:    []string{"two", "three"},
+		Buttons: [][]Button{{{Text: "ok", Unique: "ok"}}},
+	}
+
+	var texts, ids []string
+	for part := msg; part != nil; part = part.Next() {
+		texts = append(texts, part.Text)
+		ids = append(ids, part.msgID())
+		if part.ChatID != 2 || len(part.Buttons) != 1 {
+			t.Errorf("part %q lost its chat or buttons", part.Text)
+		}
+	}
+
+	if got := fmt.Sprint(texts); got != "[one two three]" {
+		t.Errorf("parts = %v", got)
+	}
+	if got := fmt.Sprint(ids); got != "[broadcast:1:2 broadcast:1:2/1 broadcast:1:2/2]" {
+		t.Errorf("ids = %v", got)
+	}
+	if next := (&TelegramMessage{Text: "only"}).Next(); next != nil {
+		t.Errorf("Next() of a single part = %+v, want nil", next)
+	}
+}
@@ -0,0 +1,31 @@
+-- +goose Up
+-- Add near-duplicate fingerprints to jokes
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS normalized_hash VARCHAR(64);
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS simhash BIGINT;
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS simhash_b0 INTEGER;
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS simhash_b1 INTEGER;
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS simhash_b2 INTEGER;
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS simhash_b3 INTEGER;
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS duplicate_of INTEGER REFERENCES jokes(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_jokes_normalized_hash ON jokes(normalized_hash);
+CREATE INDEX IF NOT EXISTS idx_jokes_simhash_b0 ON jokes(simhash_b0);
+CREATE INDEX IF NOT EXISTS idx_jokes_simhash_b1 ON jokes(simhash_b1);
+CREATE INDEX IF NOT EXISTS idx_jokes_simhash_b2 ON jokes(simhash_b2);
+CREATE INDEX IF NOT EXISTS idx_jokes_simhash_b3 ON jokes(simhash_b3);
+CREATE INDEX IF NOT EXISTS idx_jokes_duplicate_of ON jokes(duplicate_of);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_jokes_duplicate_of;
+DROP INDEX IF EXISTS idx_jokes_simhash_b3;
+DROP INDEX IF EXISTS idx_jokes_simhash_b2;
+DROP INDEX IF EXISTS idx_jokes_simhash_b1;
+DROP INDEX IF EXISTS idx_jokes_simhash_b0;
+DROP INDEX IF EXISTS idx_jokes_normalized_hash;
+ALTER TABLE jokes DROP COLUMN IF EXISTS duplicate_of;
+ALTER TABLE jokes DROP COLUMN IF EXISTS simhash_b3;
+ALTER TABLE jokes DROP COLUMN IF EXISTS simhash_b2;
+ALTER TABLE jokes DROP COLUMN IF EXISTS simhash_b1;
+ALTER TABLE jokes DROP COLUMN IF EXISTS simhash_b0;
+ALTER TABLE jokes DROP COLUMN IF EXISTS simhash;
+ALTER TABLE jokes DROP COLUMN IF EXISTS normalized_hash;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Track which jokes were delivered to which user
+CREATE TABLE IF NOT EXISTS user_seen_jokes (
+    telegram_id BIGINT NOT NULL,
+    joke_id INTEGER NOT NULL REFERENCES jokes(id) ON DELETE CASCADE,
+    seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (telegram_id, joke_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_seen_jokes_seen_at ON user_seen_jokes(telegram_id, seen_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_user_seen_jokes_seen_at;
+DROP TABLE IF EXISTS user_seen_jokes;
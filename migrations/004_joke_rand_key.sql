@@ -0,0 +1,11 @@
+-- +goose Up
+-- Random key for index-based random joke selection
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS rand_key DOUBLE PRECISION NOT NULL DEFAULT random();
+
+CREATE INDEX IF NOT EXISTS idx_jokes_rand_key ON jokes(rand_key) WHERE duplicate_of IS NULL;
+CREATE INDEX IF NOT EXISTS idx_jokes_source_rand_key ON jokes(source, rand_key) WHERE duplicate_of IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_jokes_source_rand_key;
+DROP INDEX IF EXISTS idx_jokes_rand_key;
+ALTER TABLE jokes DROP COLUMN IF EXISTS rand_key;
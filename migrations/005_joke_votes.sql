@@ -0,0 +1,26 @@
+-- +goose Up
+-- Store per-user votes and aggregate ratings on jokes
+CREATE TABLE IF NOT EXISTS joke_votes (
+    telegram_id BIGINT NOT NULL,
+    joke_id INTEGER NOT NULL REFERENCES jokes(id) ON DELETE CASCADE,
+    value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (telegram_id, joke_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_joke_votes_joke_id ON joke_votes(joke_id);
+
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS upvotes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS downvotes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS rating INTEGER GENERATED ALWAYS AS (upvotes - downvotes) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_jokes_rating ON jokes(rating DESC) WHERE duplicate_of IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_jokes_rating;
+ALTER TABLE jokes DROP COLUMN IF EXISTS rating;
+ALTER TABLE jokes DROP COLUMN IF EXISTS downvotes;
+ALTER TABLE jokes DROP COLUMN IF EXISTS upvotes;
+DROP INDEX IF EXISTS idx_joke_votes_joke_id;
+DROP TABLE IF EXISTS joke_votes;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Full-text search over joke content in Russian and English
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        to_tsvector('russian'::regconfig, coalesce(content, '')) ||
+        to_tsvector('english'::regconfig, coalesce(content, ''))
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_jokes_search_vector ON jokes USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_jokes_search_vector;
+ALTER TABLE jokes DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Record jokes shared through inline mode
+CREATE TABLE IF NOT EXISTS joke_shares (
+    id SERIAL PRIMARY KEY,
+    telegram_id BIGINT NOT NULL,
+    joke_id INTEGER NOT NULL REFERENCES jokes(id) ON DELETE CASCADE,
+    query TEXT,
+    shared_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_joke_shares_joke_id ON joke_shares(joke_id);
+CREATE INDEX IF NOT EXISTS idx_joke_shares_telegram_id ON joke_shares(telegram_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_joke_shares_telegram_id;
+DROP INDEX IF EXISTS idx_joke_shares_joke_id;
+DROP TABLE IF EXISTS joke_shares;
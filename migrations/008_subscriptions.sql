@@ -0,0 +1,18 @@
+-- +goose Up
+-- Daily joke subscriptions delivered at a local time of the user's choosing
+CREATE TABLE IF NOT EXISTS subscriptions (
+    telegram_id BIGINT PRIMARY KEY,
+    hour SMALLINT NOT NULL CHECK (hour BETWEEN 0 AND 23),
+    minute SMALLINT NOT NULL CHECK (minute BETWEEN 0 AND 59),
+    timezone TEXT NOT NULL,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_sent_at TIMESTAMPTZ,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_next_run_at ON subscriptions(next_run_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_subscriptions_next_run_at;
+DROP TABLE IF EXISTS subscriptions;
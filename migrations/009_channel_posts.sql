@@ -0,0 +1,19 @@
+-- +goose Up
+-- Channel autoposting: when each channel is due next and what it has received
+CREATE TABLE IF NOT EXISTS channel_schedules (
+    chat_id BIGINT PRIMARY KEY,
+    schedule TEXT NOT NULL,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_posted_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS channel_posts (
+    chat_id BIGINT NOT NULL,
+    joke_id INTEGER NOT NULL REFERENCES jokes(id) ON DELETE CASCADE,
+    posted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, joke_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS channel_posts;
+DROP TABLE IF EXISTS channel_schedules;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Per-chat settings for groups and private chats, and NSFW marking of jokes
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS nsfw BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS chats (
+    chat_id BIGINT PRIMARY KEY,
+    type VARCHAR(32) NOT NULL,
+    title TEXT,
+    sources TEXT[] NOT NULL DEFAULT '{}',
+    nsfw BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS chats;
+ALTER TABLE jokes DROP COLUMN IF EXISTS nsfw;
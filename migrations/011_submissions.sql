@@ -0,0 +1,25 @@
+-- +goose Up
+-- User joke submissions awaiting moderation, and attribution of approved ones
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS author TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS submissions (
+    id SERIAL PRIMARY KEY,
+    telegram_id BIGINT NOT NULL,
+    author TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected')),
+    joke_id INTEGER REFERENCES jokes(id) ON DELETE SET NULL,
+    reviewed_by BIGINT,
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_submissions_telegram_id ON submissions(telegram_id, status);
+CREATE INDEX IF NOT EXISTS idx_submissions_pending ON submissions(created_at) WHERE status = 'pending';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_submissions_pending;
+DROP INDEX IF EXISTS idx_submissions_telegram_id;
+DROP TABLE IF EXISTS submissions;
+ALTER TABLE jokes DROP COLUMN IF EXISTS author;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Roles granted at runtime on top of the configured admins, and banned users
+CREATE TABLE IF NOT EXISTS roles (
+    telegram_id BIGINT NOT NULL,
+    role VARCHAR(32) NOT NULL,
+    granted_by BIGINT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (telegram_id, role)
+);
+
+CREATE INDEX IF NOT EXISTS idx_roles_role ON roles(role);
+
+CREATE TABLE IF NOT EXISTS user_bans (
+    telegram_id BIGINT PRIMARY KEY,
+    reason TEXT NOT NULL DEFAULT '',
+    banned_by BIGINT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_username ON users(LOWER(username));
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_username;
+DROP TABLE IF EXISTS user_bans;
+DROP INDEX IF EXISTS idx_roles_role;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,36 @@
+-- +goose Up
+-- Admin broadcasts and their per-recipient delivery state, so that an
+-- interrupted broadcast resumes where it stopped
+CREATE TABLE IF NOT EXISTS broadcasts (
+    id SERIAL PRIMARY KEY,
+    admin_id BIGINT NOT NULL,
+    chat_id BIGINT NOT NULL,
+    text TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'running', 'finished', 'cancelled')),
+    total INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    reported_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_broadcasts_running ON broadcasts(id) WHERE status = 'running';
+
+CREATE TABLE IF NOT EXISTS broadcast_deliveries (
+    broadcast_id INTEGER NOT NULL REFERENCES broadcasts(id) ON DELETE CASCADE,
+    telegram_id BIGINT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'queued', 'sent', 'blocked', 'failed')),
+    error TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (broadcast_id, telegram_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_broadcast_deliveries_status ON broadcast_deliveries(broadcast_id, status);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_broadcast_deliveries_status;
+DROP TABLE IF EXISTS broadcast_deliveries;
+DROP INDEX IF EXISTS idx_broadcasts_running;
+DROP TABLE IF EXISTS broadcasts;
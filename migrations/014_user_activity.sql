@@ -0,0 +1,12 @@
+-- +goose Up
+-- Users the bot can no longer reach, because they blocked it or deleted
+-- their account
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS blocked_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_inactive ON users(blocked_at) WHERE NOT is_active;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_inactive;
+ALTER TABLE users DROP COLUMN IF EXISTS blocked_at;
+ALTER TABLE users DROP COLUMN IF EXISTS is_active;
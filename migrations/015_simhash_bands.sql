@@ -0,0 +1,41 @@
+-- +goose Up
+-- Split SimHash into eight 8-bit bands instead of four 16-bit ones, so that
+-- hashes up to 7 bits apart always share a band
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS simhash_b4 INTEGER;
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS simhash_b5 INTEGER;
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS simhash_b6 INTEGER;
+ALTER TABLE jokes ADD COLUMN IF NOT EXISTS simhash_b7 INTEGER;
+
+UPDATE jokes SET
+    simhash_b0 = simhash & 255,
+    simhash_b1 = (simhash >> 8) & 255,
+    simhash_b2 = (simhash >> 16) & 255,
+    simhash_b3 = (simhash >> 24) & 255,
+    simhash_b4 = (simhash >> 32) & 255,
+    simhash_b5 = (simhash >> 40) & 255,
+    simhash_b6 = (simhash >> 48) & 255,
+    simhash_b7 = (simhash >> 56) & 255
+WHERE simhash IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_jokes_simhash_b4 ON jokes(simhash_b4);
+CREATE INDEX IF NOT EXISTS idx_jokes_simhash_b5 ON jokes(simhash_b5);
+CREATE INDEX IF NOT EXISTS idx_jokes_simhash_b6 ON jokes(simhash_b6);
+CREATE INDEX IF NOT EXISTS idx_jokes_simhash_b7 ON jokes(simhash_b7);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_jokes_simhash_b7;
+DROP INDEX IF EXISTS idx_jokes_simhash_b6;
+DROP INDEX IF EXISTS idx_jokes_simhash_b5;
+DROP INDEX IF EXISTS idx_jokes_simhash_b4;
+
+UPDATE jokes SET
+    simhash_b0 = simhash & 65535,
+    simhash_b1 = (simhash >> 16) & 65535,
+    simhash_b2 = (simhash >> 32) & 65535,
+    simhash_b3 = (simhash >> 48) & 65535
+WHERE simhash IS NOT NULL;
+
+ALTER TABLE jokes DROP COLUMN IF EXISTS simhash_b7;
+ALTER TABLE jokes DROP COLUMN IF EXISTS simhash_b6;
+ALTER TABLE jokes DROP COLUMN IF EXISTS simhash_b5;
+ALTER TABLE jokes DROP COLUMN IF EXISTS simhash_b4;